@@ -29,7 +29,7 @@ import (
 type ApiMiddleware struct {
 	Name       string // 全局唯一
 	Desc       string
-	Params     []Param     // (可选)参数说明列表(应该只声明当前中间件用到的参数)，path参数类型的先后顺序与url中保持一致
+	Params     []Param     // (可选)参数说明列表(应该只声明当前中间件用到的参数)，path参数类型的先后顺序与url中保持一致，Config实现paramsConfig时按配置生成
 	Config     interface{} // 初始配置，若希望使用参数，则Config不能为nil，至少为对应类型的空值
 	Middleware interface{} // 处理函数，类型参考上面注释
 	id         string      // 允许不同id相同name的中间件注册，但在name末尾追加"(2)"
@@ -113,6 +113,10 @@ func (a *ApiMiddleware) init() *ApiMiddleware {
 		a.Config = nil
 	}
 
+	if pc, ok := a.Config.(paramsConfig); ok {
+		a.Params = pc.params()
+	}
+
 	a.dynamic = false
 	a.configJSON = ""
 	if a.Config != nil {
@@ -166,6 +170,29 @@ func (a *ApiMiddleware) regetFunc(configJSONBytes []byte) (MiddlewareFunc, error
 	return a.Middleware.(Middleware).getMiddlewareFunc(a.Config), err
 }
 
+// 按配置生成参数说明的中间件配置，如JWTAuthConfig读取URL参数时请求头不再必需
+type paramsConfig interface {
+	params() []Param
+}
+
+// 使用JSON字节流格式的配置时的参数说明，配置为空或无效时为Params
+func (a *ApiMiddleware) configParams(configJSONBytes []byte) []Param {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if _, ok := a.Config.(paramsConfig); ok && a.dynamic && len(configJSONBytes) > 0 {
+		config := utils.NewObjectPtr(a.Config)
+		if json.Unmarshal(configJSONBytes, config) == nil {
+			if reflect.TypeOf(a.Config).Kind() != reflect.Ptr {
+				config = reflect.ValueOf(config).Elem().Interface()
+			}
+			if pc, ok := config.(paramsConfig); ok {
+				return pc.params()
+			}
+		}
+	}
+	return a.Params
+}
+
 // 是否支持动态配置
 func (a *ApiMiddleware) getDynamic() bool {
 	a.lock.RLock()
@@ -416,7 +443,7 @@ var FilterTemplate = ApiMiddleware{
 	Middleware: func(next HandlerFunc) HandlerFunc {
 		return func(c *Context) error {
 			ext := path.Ext(c.request.URL.Path)
-			if len(ext) >= 4 && ext[:4] == TPL_EXT {
+			if len(ext) >= 4 && ext[:4] == TPL_EXT || ext == GOTPL_EXT {
 				return c.NoContent(http.StatusForbidden)
 			}
 			return next(c)
@@ -38,6 +38,7 @@ type (
 		binder         Binder
 		renderer       Renderer
 		memoryCache    *MemoryCache
+		fileSystem     http.FileSystem
 		ctxPool        sync.Pool
 		serving        bool
 		lock           sync.RWMutex
@@ -116,6 +117,7 @@ const (
 // Headers
 const (
 	HeaderAcceptEncoding                = "Accept-Encoding"
+	HeaderAcceptLanguage                = "Accept-Language"
 	HeaderAuthorization                 = "Authorization"
 	HeaderContentDisposition            = "Content-Disposition"
 	HeaderContentEncoding               = "Content-Encoding"
@@ -145,12 +147,14 @@ const (
 	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
 
 	// Security
-	HeaderStrictTransportSecurity = "Strict-Transport-Security"
-	HeaderXContentTypeOptions     = "X-Content-Type-Options"
-	HeaderXXSSProtection          = "X-XSS-Protection"
-	HeaderXFrameOptions           = "X-Frame-Options"
-	HeaderContentSecurityPolicy   = "Content-Security-Policy"
-	HeaderXCSRFToken              = "X-CSRF-Token"
+	HeaderStrictTransportSecurity         = "Strict-Transport-Security"
+	HeaderXContentTypeOptions             = "X-Content-Type-Options"
+	HeaderXXSSProtection                  = "X-XSS-Protection"
+	HeaderXFrameOptions                   = "X-Frame-Options"
+	HeaderContentSecurityPolicy           = "Content-Security-Policy"
+	HeaderContentSecurityPolicyReportOnly = "Content-Security-Policy-Report-Only"
+	HeaderReferrerPolicy                  = "Referrer-Policy"
+	HeaderXCSRFToken                      = "X-CSRF-Token"
 )
 
 var (
@@ -186,19 +190,22 @@ var (
 		return nil
 	})
 
-	// 失败状态默认的响应内容
+	// 失败状态默认的响应内容，状态码消息按请求的语言翻译
 	defaultFailureHandler = func(c *Context, code int, errStr string) error {
 		statusText := http.StatusText(code)
+		if key := statusTextKey(code); I18n.Has(c.Locale(), key) {
+			statusText = c.T(key)
+		}
 		if len(errStr) > 0 {
 			errStr = `<br><p><b style="color:red;">[ERROR]</b> <pre>` + errStr + `</pre></p>`
 		}
 		c.response.Header().Set(HeaderXContentTypeOptions, "nosniff")
-		return c.HTML(code, fmt.Sprintf("<html>\n"+
+		return c.HTML(code, fmt.Sprintf("<html lang=\"%s\">\n"+
 			"<head><title>%d %s</title></head>\n"+
 			"<body bgcolor=\"white\">\n"+
 			"<center><h1>%d %s</h1></center>\n"+
 			"<hr>\n<center>lessgo/%s</center>\n%s\n</body>\n</html>\n",
-			code, statusText, code, statusText, VERSION, errStr),
+			c.Locale(), code, statusText, code, statusText, VERSION, errStr),
 		)
 	}
 
@@ -222,6 +229,7 @@ func newApp() *App {
 		binder:         &binder{},
 		failureHandler: defaultFailureHandler,
 		panicStackFunc: defaultPanicStackFunc,
+		fileSystem:     OSFileSystem{},
 	}
 
 	this.ctxPool.New = func() interface{} {
@@ -298,6 +306,26 @@ func (this *App) MemoryCache() *MemoryCache {
 	return this.memoryCache
 }
 
+// 返回读取模板及静态文件的文件系统
+func (this *App) FileSystem() http.FileSystem {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.fileSystem
+}
+
+// 设置读取模板及静态文件的文件系统，并清空模板缓存与文件缓存
+func (this *App) SetFileSystem(fs http.FileSystem) {
+	this.lock.Lock()
+	this.fileSystem = fs
+	this.lock.Unlock()
+	if r, ok := this.renderer.(templateEngine); ok {
+		r.Reload()
+	}
+	if this.memoryCache != nil {
+		this.memoryCache.SetFileSystem(fs)
+	}
+}
+
 // 判断文件缓存是否开启
 func (this *App) CanMemoryCache() bool {
 	return this.memoryCache != nil && this.memoryCache.Enable()
@@ -456,6 +484,7 @@ func (this *App) run(address, tlsAddress, tlsCertfile, tlsKeyfile string, readTi
 // set files cache
 func (this *App) setMemoryCache(m *MemoryCache) {
 	m.SetEnable(!this.debug)
+	m.SetFileSystem(this.FileSystem())
 	this.memoryCache = m
 }
 
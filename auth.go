@@ -0,0 +1,275 @@
+package lessgo
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/jwt"
+)
+
+// 认证方式
+const (
+	AuthBasic  = "basic"
+	AuthAPIKey = "apikey"
+	AuthJWT    = "jwt"
+)
+
+// 当前请求的认证主体在Context中的键
+const principalContextKey = "_principal"
+
+type (
+	// 已认证的主体
+	Principal struct {
+		Name   string                 // 用户名、API key的所有者或JWT的sub
+		Scheme string                 // 认证方式，如AuthBasic、AuthAPIKey、AuthJWT
+		Claims map[string]interface{} // 附加信息，如JWT的全部声明
+	}
+
+	// 校验Basic认证的用户名及密码，认证失败时返回nil，err不为nil时响应500
+	BasicAuthVerifier func(c *Context, user, password string) (*Principal, error)
+	// 校验API key，认证失败时返回nil，err不为nil时响应500
+	APIKeyVerifier func(c *Context, key string) (*Principal, error)
+
+	// Basic认证中间件的配置
+	BasicAuthConfig struct {
+		Realm    string `json:"realm"`    // WWW-Authenticate质询中的realm
+		Verifier string `json:"verifier"` // 由RegisterBasicAuthVerifier注册的校验函数名
+	}
+	// API key认证中间件的配置
+	APIKeyAuthConfig struct {
+		Header   string `json:"header"`   // 读取API key的请求头，为空时不读取
+		Query    string `json:"query"`    // 请求头中不存在时读取的URL参数，为空时不读取
+		Verifier string `json:"verifier"` // 由RegisterAPIKeyVerifier注册的校验函数名
+	}
+	// JWT认证中间件的配置
+	JWTAuthConfig struct {
+		Realm      string `json:"realm"`      // WWW-Authenticate质询中的realm
+		KeySet     string `json:"keySet"`     // 由RegisterJWTKeySet注册的密钥集名称
+		Leeway     int    `json:"leeway"`     // 校验exp、nbf及iat时允许的时钟偏差(秒)
+		Issuer     string `json:"issuer"`     // 不为空时iss须与之相同
+		Audience   string `json:"audience"`   // 不为空时aud须包含之
+		Query      string `json:"query"`      // 请求头Authorization中不存在时读取的URL参数(如websocket)，为空时不读取
+		RequireExp bool   `json:"requireExp"` // 是否拒绝无exp(永不过期)的令牌
+	}
+)
+
+var (
+	basicAuthVerifiers = map[string]BasicAuthVerifier{}
+	apiKeyVerifiers    = map[string]APIKeyVerifier{}
+	jwtKeySets         = map[string]*jwt.KeySet{}
+	authLock           sync.RWMutex
+)
+
+// 注册Basic认证的校验函数，供BasicAuth中间件按配置的名称(默认"default")使用
+func RegisterBasicAuthVerifier(name string, v BasicAuthVerifier) {
+	authLock.Lock()
+	defer authLock.Unlock()
+	basicAuthVerifiers[name] = v
+}
+
+// 注册API key的校验函数，供APIKeyAuth中间件按配置的名称(默认"default")使用
+func RegisterAPIKeyVerifier(name string, v APIKeyVerifier) {
+	authLock.Lock()
+	defer authLock.Unlock()
+	apiKeyVerifiers[name] = v
+}
+
+// 注册JWT的密钥集，供JWTAuth中间件按配置的名称(默认"default")使用，
+// 运行时通过密钥集的Add、Remove轮换密钥
+func RegisterJWTKeySet(name string, keys *jwt.KeySet) {
+	authLock.Lock()
+	defer authLock.Unlock()
+	jwtKeySets[name] = keys
+}
+
+// 返回一个以固定用户名及密码校验的BasicAuthVerifier，密码以常量时间比较
+func StaticBasicAuth(users map[string]string) BasicAuthVerifier {
+	return func(c *Context, user, password string) (*Principal, error) {
+		want, ok := users[user]
+		if subtle.ConstantTimeCompare([]byte(password), []byte(want)) != 1 || !ok {
+			return nil, nil
+		}
+		return &Principal{Name: user, Scheme: AuthBasic}, nil
+	}
+}
+
+// 返回一个以固定API key(key为API key，value为所有者)校验的APIKeyVerifier
+func StaticAPIKeys(keys map[string]string) APIKeyVerifier {
+	return func(c *Context, key string) (*Principal, error) {
+		for k, owner := range keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				return &Principal{Name: owner, Scheme: AuthAPIKey}, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// 当前请求的认证主体，未认证时为nil
+func (c *Context) Principal() *Principal {
+	p, _ := c.Get(principalContextKey).(*Principal)
+	return p
+}
+
+// 设置当前请求的认证主体，供自定义的认证中间件使用
+func (c *Context) SetPrincipal(p *Principal) {
+	c.Set(principalContextKey, p)
+}
+
+var BasicAuth = ApiMiddleware{
+	Name: "HTTP Basic认证",
+	Desc: "以RegisterBasicAuthVerifier注册的函数校验用户名及密码，失败时响应401及WWW-Authenticate质询",
+	Params: []Param{
+		{Name: HeaderAuthorization, In: "header", Required: true, Model: "Basic base64(user:password)", Desc: "HTTP Basic认证"},
+	},
+	Config: BasicAuthConfig{
+		Realm:    "Restricted",
+		Verifier: "default",
+	},
+	Middleware: func(confObject interface{}) MiddlewareFunc {
+		conf := confObject.(BasicAuthConfig)
+		challenge := "Basic realm=" + strconv.Quote(conf.Realm) + `, charset="UTF-8"`
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) error {
+				authLock.RLock()
+				verify := basicAuthVerifiers[conf.Verifier]
+				authLock.RUnlock()
+				if verify == nil {
+					return c.Failure(http.StatusInternalServerError, fmt.Errorf("basic auth verifier %q is not registered", conf.Verifier))
+				}
+				if user, password, ok := c.request.BasicAuth(); ok {
+					p, err := verify(c, user, password)
+					if err != nil {
+						return c.Failure(http.StatusInternalServerError, err)
+					}
+					if p != nil {
+						c.SetPrincipal(p)
+						return next(c)
+					}
+				}
+				c.response.Header().Set(HeaderWWWAuthenticate, challenge)
+				return c.Failure(http.StatusUnauthorized, ErrUnauthorized)
+			}
+		}
+	},
+}.Reg()
+
+var APIKeyAuth = ApiMiddleware{
+	Name: "API key认证",
+	Desc: "读取请求头X-API-Key或URL参数api_key中的API key，以RegisterAPIKeyVerifier注册的函数校验",
+	Params: []Param{
+		{Name: "X-API-Key", In: "header", Model: "", Desc: "API key"},
+		{Name: "api_key", In: "query", Model: "", Desc: "API key，请求头X-API-Key不存在时使用"},
+	},
+	Config: APIKeyAuthConfig{
+		Header:   "X-API-Key",
+		Query:    "api_key",
+		Verifier: "default",
+	},
+	Middleware: func(confObject interface{}) MiddlewareFunc {
+		conf := confObject.(APIKeyAuthConfig)
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) error {
+				authLock.RLock()
+				verify := apiKeyVerifiers[conf.Verifier]
+				authLock.RUnlock()
+				if verify == nil {
+					return c.Failure(http.StatusInternalServerError, fmt.Errorf("api key verifier %q is not registered", conf.Verifier))
+				}
+				var key string
+				if len(conf.Header) > 0 {
+					key = c.HeaderParam(conf.Header)
+				}
+				if len(key) == 0 && len(conf.Query) > 0 {
+					key = c.QueryParam(conf.Query)
+				}
+				if len(key) > 0 {
+					p, err := verify(c, key)
+					if err != nil {
+						return c.Failure(http.StatusInternalServerError, err)
+					}
+					if p != nil {
+						c.SetPrincipal(p)
+						return next(c)
+					}
+				}
+				return c.Failure(http.StatusUnauthorized, ErrUnauthorized)
+			}
+		}
+	},
+}.Reg()
+
+var JWTAuth = ApiMiddleware{
+	Name: "JWT认证",
+	Desc: "校验请求头Authorization中的Bearer令牌(HS256/384/512、RS256/384/512)，密钥集由RegisterJWTKeySet注册，认证主体为sub，默认拒绝无exp的令牌",
+	// Params由JWTAuthConfig按配置生成
+	Config: JWTAuthConfig{
+		Realm:      "api",
+		KeySet:     "default",
+		Leeway:     60,
+		RequireExp: true,
+	},
+	Middleware: func(confObject interface{}) MiddlewareFunc {
+		conf := confObject.(JWTAuthConfig)
+		challenge := "Bearer realm=" + strconv.Quote(conf.Realm)
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) error {
+				authLock.RLock()
+				keys := jwtKeySets[conf.KeySet]
+				authLock.RUnlock()
+				if keys == nil {
+					return c.Failure(http.StatusInternalServerError, fmt.Errorf("jwt key set %q is not registered", conf.KeySet))
+				}
+				token := bearerToken(c.HeaderParam(HeaderAuthorization))
+				if len(token) == 0 && len(conf.Query) > 0 {
+					token = c.QueryParam(conf.Query)
+				}
+				if len(token) == 0 {
+					c.response.Header().Set(HeaderWWWAuthenticate, challenge)
+					return c.Failure(http.StatusUnauthorized, ErrUnauthorized)
+				}
+				v := jwt.Verifier{
+					Keys:       keys,
+					Leeway:     time.Duration(conf.Leeway) * time.Second,
+					Issuer:     conf.Issuer,
+					Audience:   conf.Audience,
+					RequireExp: conf.RequireExp,
+				}
+				claims, err := v.Verify(token)
+				if err != nil {
+					// RFC 6750
+					c.response.Header().Set(HeaderWWWAuthenticate, challenge+`, error="invalid_token", error_description=`+
+						strconv.Quote(strings.TrimPrefix(err.Error(), "jwt: ")))
+					return c.Failure(http.StatusUnauthorized, err)
+				}
+				c.SetPrincipal(&Principal{Name: claims.Subject(), Scheme: AuthJWT, Claims: claims})
+				return next(c)
+			}
+		}
+	},
+}.Reg()
+
+// JWT认证的参数说明，读取URL参数时请求头Authorization不再必需
+func (conf JWTAuthConfig) params() []Param {
+	params := []Param{
+		{Name: HeaderAuthorization, In: "header", Required: len(conf.Query) == 0, Model: "Bearer <token>", Desc: "JWT令牌"},
+	}
+	if len(conf.Query) > 0 {
+		params = append(params, Param{Name: conf.Query, In: "query", Model: "", Desc: "JWT令牌，请求头Authorization不存在时使用"})
+	}
+	return params
+}
+
+// 读取"Bearer <token>"中的令牌
+func bearerToken(auth string) string {
+	const prefix = "bearer "
+	if len(auth) > len(prefix) && strings.EqualFold(auth[:len(prefix)], prefix) {
+		return strings.TrimSpace(auth[len(prefix):])
+	}
+	return ""
+}
@@ -2,12 +2,16 @@ package lessgo
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 
 	confpkg "github.com/henrylee2cn/lessgo/config"
+	_ "github.com/henrylee2cn/lessgo/config/toml"
+	_ "github.com/henrylee2cn/lessgo/config/yaml"
 	"github.com/henrylee2cn/lessgo/logs"
 )
 
@@ -21,8 +25,11 @@ type (
 		MaxMemoryMB int64 // 文件上传默认内存缓存大小，单位MB
 		Listen      Listen
 		Session     SessionConfig
+		Security    SecurityConfig
+		I18n        I18nConfig
 		Log         LogConfig
 		FileCache   FileCacheConfig
+		sources     map[string]string // 各配置项最终值的来源
 	}
 	Info struct {
 		Version           string
@@ -55,6 +62,25 @@ type (
 		EnableSidInHttpHeader   bool //	enable store/get the sessionId into/from http headers
 		SessionNameInHttpHeader string
 		EnableSidInUrlQuery     bool //	enable get the sessionId from Url Query params
+		SessionCookiePath       string
+		SessionHttpOnly         bool
+		SessionSameSite         string // session cookie的SameSite属性，可选Lax、Strict、None（须启用TLS），为空时不设置
+		SessionAbsoluteLifetime int64  // session自创建起的最长存活秒数(与空闲回收时长SessionGCMaxLifetime无关)，0为不限制
+		SessionSerializer       string // session数据的序列化格式，可选gob、json、msgpack
+	}
+
+	// SecurityConfig holds security related config
+	SecurityConfig struct {
+		CookieKeys   string // 加密cookie的密钥环，以逗号分隔，首个密钥用于加密，全部密钥均可解密；为空时使用随机密钥(重启后失效)
+		CookieMaxAge int64  // 加密cookie自设置起的有效秒数，0为不限制
+	}
+
+	// I18nConfig holds i18n related config
+	I18nConfig struct {
+		DefaultLocale string // 无法匹配请求的语言时使用的默认语言
+		QueryParam    string // 指定语言的URL参数名
+		CookieName    string // 指定语言的cookie名
+		SessionKey    string // session中保存语言的键名
 	}
 
 	// LogConfig holds Log related config
@@ -87,14 +113,18 @@ const (
 	COMMON_DIR      = "common"
 	MIDDLEWARE_DIR  = "middleware"
 	ROUTER_DIR      = "router"
+	I18N_DIR        = "i18n"
 
 	TPL_EXT         = ".tpl"
+	GOTPL_EXT       = ".gohtml"
 	STATIC_HTML_EXT = ".html"
 
 	CONFIG_DIR        = "config"
 	APPCONFIG_FILE    = CONFIG_DIR + "/app.config"
 	ROUTERCONFIG_FILE = CONFIG_DIR + "/virtrouter.config"
 	LOG_FILE          = "logger/lessgo.log"
+
+	ENV_PREFIX = "LESSGO_" // 覆盖配置项的环境变量名前缀
 )
 
 const (
@@ -137,6 +167,23 @@ func newConfig() *config {
 			EnableSidInHttpHeader:   false, //	enable store/get the sessionId into/from http headers
 			SessionNameInHttpHeader: "Lessgosessionid",
 			EnableSidInUrlQuery:     false, //	enable get the sessionId from Url Query params
+			SessionCookiePath:       "/",
+			SessionHttpOnly:         true,
+			SessionSameSite:         "Lax",
+			SessionAbsoluteLifetime: 0,
+			SessionSerializer:       "gob",
+		},
+
+		Security: SecurityConfig{
+			CookieKeys:   "",
+			CookieMaxAge: 30 * 24 * 3600, // 30天
+		},
+
+		I18n: I18nConfig{
+			DefaultLocale: "en",
+			QueryParam:    "lang",
+			CookieName:    "lang",
+			SessionKey:    "lang",
 		},
 
 		FileCache: FileCacheConfig{
@@ -152,37 +199,269 @@ func newConfig() *config {
 }
 
 func (this *config) LoadMainConfig() (err error) {
-	fname := APPCONFIG_FILE
-	iniconf, err := confpkg.NewConfig("ini", fname)
-	if err == nil {
-		os.Remove(fname)
-		ReadSingleConfig("system", this, iniconf)
-		ReadSingleConfig("filecache", &this.FileCache, iniconf)
-		ReadSingleConfig("info", &this.Info, iniconf)
-		ReadSingleConfig("listen", &this.Listen, iniconf)
-		ReadSingleConfig("log", &this.Log, iniconf)
-		ReadSingleConfig("session", &this.Session, iniconf)
+	fname, adapter := MainConfigFile()
+	this.resetSources()
+	conf, err := confpkg.NewConfig(adapter, fname)
+	switch {
+	case err == nil:
+		this.readLayer(fname, conf)
+		if adapter == "ini" {
+			err = this.completeMainConfig(conf, fname)
+		}
+	case os.IsNotExist(err):
+		err = this.createMainConfig(fname)
 	}
+	// 主配置文件只保存默认值与主配置文件中的值，profile与环境变量仅覆盖内存中的配置
+	this.readOverlays()
+	return err
+}
+
+// 创建ini格式的主配置文件，写入全部配置项
+func (this *config) createMainConfig(fname string) error {
 	os.MkdirAll(filepath.Dir(fname), 0777)
 	f, err := os.Create(fname)
 	if err != nil {
 		return err
 	}
 	f.Close()
-	iniconf, err = confpkg.NewConfig("ini", fname)
+	iniconf, err := confpkg.NewConfig("ini", fname)
 	if err != nil {
 		return err
 	}
-	WriteSingleConfig("system", this, iniconf)
-	WriteSingleConfig("filecache", &this.FileCache, iniconf)
-	WriteSingleConfig("info", &this.Info, iniconf)
-	WriteSingleConfig("listen", &this.Listen, iniconf)
-	WriteSingleConfig("log", &this.Log, iniconf)
-	WriteSingleConfig("session", &this.Session, iniconf)
+	return this.completeMainConfig(iniconf, fname)
+}
 
+// 将缺失的配置项追加到ini格式的主配置文件，已有的注释、顺序及未知配置项保持不变
+func (this *config) completeMainConfig(iniconf confpkg.Configer, fname string) error {
+	var missing bool
+	this.walkFields(func(fullname string, field reflect.Value) {
+		sectionKey := strings.SplitN(fullname, "::", 2)
+		if data, err := iniconf.GetSection(sectionKey[0]); err == nil {
+			if _, ok := data[sectionKey[1]]; ok {
+				return
+			}
+		}
+		iniconf.Set(fullname, configValueString(fullname, field))
+		missing = true
+	})
+	if !missing {
+		return nil
+	}
 	return iniconf.SaveConfigFile(fname)
 }
 
+// 按默认值、主配置文件、profile配置文件、环境变量的顺序逐层加载配置(不回写配置文件)
+func (this *config) loadLayers() error {
+	this.resetSources()
+	fname, adapter := MainConfigFile()
+	conf, err := confpkg.NewConfig(adapter, fname)
+	if err != nil {
+		return err
+	}
+	this.readLayer(fname, conf)
+	this.readOverlays()
+	return nil
+}
+
+// 依次读取profile配置文件与环境变量
+func (this *config) readOverlays() {
+	if profile := Profile(); len(profile) > 0 {
+		fname := ProfileConfigFile(profile)
+		_, adapter := MainConfigFile()
+		if conf, err := confpkg.NewConfig(adapter, fname); err == nil {
+			this.readLayer(fname, conf)
+		} else if !os.IsNotExist(err) {
+			fmt.Printf("Failed to load profile config %s: %v\n", fname, err)
+		}
+	}
+	this.readLayer(ENV_PREFIX, this.envConfig())
+}
+
+func (this *config) readMainConfig(iniconf confpkg.Configer) {
+	ReadSingleConfig("system", this, iniconf)
+	ReadSingleConfig("filecache", &this.FileCache, iniconf)
+	ReadSingleConfig("info", &this.Info, iniconf)
+	ReadSingleConfig("listen", &this.Listen, iniconf)
+	ReadSingleConfig("log", &this.Log, iniconf)
+	ReadSingleConfig("session", &this.Session, iniconf)
+	ReadSingleConfig("security", &this.Security, iniconf)
+	ReadSingleConfig("i18n", &this.I18n, iniconf)
+}
+
+// 读取一层配置，并记录该层设置的配置项来源
+func (this *config) readLayer(source string, conf confpkg.Configer) {
+	this.readMainConfig(conf)
+	this.walkFields(func(fullname string, field reflect.Value) {
+		if !hasConfigKey(conf, fullname) {
+			return
+		}
+		if source == ENV_PREFIX {
+			this.sources[fullname] = "env:" + EnvName(fullname)
+		} else {
+			this.sources[fullname] = source
+		}
+	})
+}
+
+// 检查配置中是否设置了该配置项(空字符串视为未设置)
+func hasConfigKey(conf confpkg.Configer, fullname string) bool {
+	if len(conf.String(fullname)) > 0 {
+		return true
+	}
+	// 非字符串类型的值，如yaml与json中的数字和布尔值
+	v, err := conf.DIY(fullname)
+	return err == nil && v != nil
+}
+
+// 将全部配置项的来源重置为默认值
+func (this *config) resetSources() {
+	this.sources = make(map[string]string)
+	this.walkFields(func(fullname string, _ reflect.Value) {
+		this.sources[fullname] = "default"
+	})
+}
+
+// 由环境变量生成的配置
+func (this *config) envConfig() confpkg.Configer {
+	envconf := confpkg.NewFakeConfig()
+	this.walkFields(func(fullname string, _ reflect.Value) {
+		val, ok := os.LookupEnv(EnvName(fullname))
+		if !ok {
+			return
+		}
+		val, err := confpkg.ResolveSecret(val)
+		if err != nil {
+			fmt.Printf("Failed to resolve %s: %v\n", EnvName(fullname), err)
+			return
+		}
+		envconf.Set(fullname, val)
+	})
+	return envconf
+}
+
+// 遍历全部可配置的字段
+func (this *config) walkFields(fn func(fullname string, field reflect.Value)) {
+	for _, section := range this.sections() {
+		pv := reflect.ValueOf(section.ptr).Elem()
+		pt := pv.Type()
+		for i := 0; i < pt.NumField(); i++ {
+			pf := pv.Field(i)
+			if !pf.CanSet() {
+				continue
+			}
+			switch pf.Kind() {
+			case reflect.String, reflect.Int, reflect.Int64, reflect.Bool:
+				fn(getfullname(section.name, pt.Field(i).Name), pf)
+			}
+		}
+	}
+}
+
+// 配置项的最终值及其来源
+type ConfigItem struct {
+	Key    string // 形如"section::key"
+	Value  string
+	Source string // "default"、配置文件名或"env:环境变量名"
+}
+
+// 返回全部配置项的最终值及其来源，用于诊断，其中的密钥值(enc:与file:)显示为******
+func ConfigItems() []ConfigItem {
+	var items []ConfigItem
+	conf := CurrentConfig()
+	conf.walkFields(func(fullname string, field reflect.Value) {
+		items = append(items, ConfigItem{
+			Key:    fullname,
+			Value:  confpkg.MaskSecrets(configValueString(fullname, field)),
+			Source: conf.sources[fullname],
+		})
+	})
+	sort.Sort(configItems(items))
+	return items
+}
+
+// 以"key = value  # source"的格式输出全部配置项
+func DumpConfig(w io.Writer) {
+	for _, item := range ConfigItems() {
+		fmt.Fprintf(w, "%s = %s  # %s\n", item.Key, item.Value, item.Source)
+	}
+}
+
+// 主配置文件及其格式，按扩展名依次查找config/app.config(ini)、app.yaml、app.yml、app.json、app.toml，
+// 均不存在时为config/app.config
+func MainConfigFile() (fname, adapter string) {
+	for _, ext := range []string{".config", ".yaml", ".yml", ".json", ".toml"} {
+		fname = CONFIG_DIR + "/app" + ext
+		if _, err := os.Stat(fname); err == nil {
+			return fname, configAdapter(ext)
+		}
+	}
+	return APPCONFIG_FILE, "ini"
+}
+
+// 配置文件扩展名对应的config适配器
+func configAdapter(ext string) string {
+	switch ext {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	case ".toml":
+		return "toml"
+	}
+	return "ini"
+}
+
+// 当前的配置profile，由环境变量LESSGO_PROFILE指定，如dev、test、prod
+func Profile() string {
+	return os.Getenv(ENV_PREFIX + "PROFILE")
+}
+
+// profile对应的配置文件，与主配置文件格式相同，如config/app.prod.config
+func ProfileConfigFile(profile string) string {
+	fname, _ := MainConfigFile()
+	return CONFIG_DIR + "/app." + profile + filepath.Ext(fname)
+}
+
+// 配置项写入配置文件时的字符串格式
+func configValueString(fullname string, field reflect.Value) string {
+	if fullname == "log::level" {
+		return logLevelString(int(field.Int()))
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// 配置项对应的环境变量名，
+// 如"listen::address"对应LESSGO_LISTEN_ADDRESS，system段的"debug"对应LESSGO_DEBUG
+func EnvName(fullname string) string {
+	fullname = strings.TrimPrefix(fullname, "system::")
+	return ENV_PREFIX + strings.ToUpper(strings.Replace(fullname, "::", "_", -1))
+}
+
+type configItems []ConfigItem
+
+func (c configItems) Len() int           { return len(c) }
+func (c configItems) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c configItems) Less(i, j int) bool { return c[i].Key < c[j].Key }
+
+// 配置文件中的section及其对应的结构体，按写入配置文件的顺序排列
+func (this *config) sections() []configSection {
+	return []configSection{
+		{"system", this},
+		{"filecache", &this.FileCache},
+		{"info", &this.Info},
+		{"listen", &this.Listen},
+		{"log", &this.Log},
+		{"session", &this.Session},
+		{"security", &this.Security},
+		{"i18n", &this.I18n},
+	}
+}
+
+type configSection struct {
+	name string
+	ptr  interface{}
+}
+
 func ReadSingleConfig(section string, p interface{}, iniconf confpkg.Configer) {
 	pt := reflect.TypeOf(p)
 	if pt.Kind() != reflect.Ptr {
@@ -224,7 +503,7 @@ func ReadSingleConfig(section string, p interface{}, iniconf confpkg.Configer) {
 				if num > 0 {
 					pf.SetInt(num)
 				}
-			case "log::asyncchan":
+			case "log::asyncchan", "security::cookiemaxage":
 				if num >= 0 {
 					pf.SetInt(num)
 				}
@@ -44,6 +44,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Configer defines how to get and set value from configuration raw data.
@@ -87,7 +88,7 @@ func Register(name string, adapter Config) {
 	adapters[name] = adapter
 }
 
-// NewConfig adapterName is ini/json/xml/yaml.
+// NewConfig adapterName is ini/json/xml/yaml/toml/dotenv.
 // filename is the config file path.
 func NewConfig(adapterName, filename string) (Configer, error) {
 	adapter, ok := adapters[adapterName]
@@ -97,7 +98,7 @@ func NewConfig(adapterName, filename string) (Configer, error) {
 	return adapter.Parse(filename)
 }
 
-// NewConfigData adapterName is ini/json/xml/yaml.
+// NewConfigData adapterName is ini/json/xml/yaml/toml/dotenv.
 // data is the config data.
 func NewConfigData(adapterName string, data []byte) (Configer, error) {
 	adapter, ok := adapters[adapterName]
@@ -142,3 +143,30 @@ func ParseBool(val interface{}) (value bool, err error) {
 	}
 	return false, fmt.Errorf("parsing <nil>: invalid syntax")
 }
+
+// LookupKey returns the value of key in data, nil if it does not exist.
+// key is "key" or "section::key", the nested maps are walked by more levels
+// such as "section::name::key".
+func LookupKey(data map[string]interface{}, key string) interface{} {
+	if len(key) == 0 {
+		return nil
+	}
+	if v, ok := data[key]; ok {
+		return v
+	}
+	sectionKeys := strings.Split(key, "::")
+	if len(sectionKeys) < 2 {
+		return nil
+	}
+	var curValue interface{} = data
+	for _, k := range sectionKeys {
+		m, ok := curValue.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if curValue, ok = m[k]; !ok {
+			return nil
+		}
+	}
+	return curValue
+}
@@ -0,0 +1,343 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DotenvConfig is a dotenv config parser and implements Config interface.
+//
+// A dotenv file holds NAME=value lines, such as:
+//
+//	# comment
+//	export APP_NAME=demo
+//	DB_HOST=localhost
+//	DB_DSN="user:${DB_PASS}@tcp(${DB_HOST:-127.0.0.1})/db"
+//	GREETING='no ${expansion} here'
+//
+// The key section::key is the name SECTION_KEY.
+type DotenvConfig struct {
+}
+
+// Parse returns a ConfigContainer with parsed dotenv file.
+func (d *DotenvConfig) Parse(filename string) (Configer, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return d.ParseData(content)
+}
+
+// ParseData returns a ConfigContainer with dotenv data.
+// ${VAR} may refer to the names above it in the same data or to the environment variables.
+func (d *DotenvConfig) ParseData(data []byte) (Configer, error) {
+	c := &DotenvConfigContainer{
+		data:     make(map[string]string),
+		rawValue: make(map[string]string),
+	}
+	lookup := func(name string) (string, bool) {
+		if v, ok := c.data[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+	data = bytes.TrimPrefix(data, []byte{239, 187, 191})
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for n := 0; n < len(lines); n++ {
+		raw := lines[n]
+		line := strings.TrimSpace(raw)
+		if len(line) == 0 || line[0] == '#' {
+			c.lines = append(c.lines, dotenvLine{raw: raw})
+			continue
+		}
+		kv := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("dotenv line %d: %q, should be NAME=value", n+1, line)
+		}
+		name := strings.ToUpper(strings.TrimSpace(kv[0]))
+		val := strings.TrimSpace(kv[1])
+		switch {
+		case strings.HasPrefix(val, `"`):
+			// double quoted values may span lines
+			for closingQuote(val[1:]) < 0 && n+1 < len(lines) {
+				n++
+				raw += "\n" + lines[n]
+				val += "\n" + lines[n]
+			}
+			end := closingQuote(val[1:])
+			if end < 0 {
+				return nil, fmt.Errorf("dotenv: unterminated quoted value of %s", name)
+			}
+			val = Expand(unescapeDotenv(val[1:1+end]), lookup)
+		case strings.HasPrefix(val, "'"):
+			end := strings.IndexByte(val[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("dotenv: unterminated quoted value of %s", name)
+			}
+			val = val[1 : 1+end]
+		default:
+			if i := strings.Index(val, " #"); i >= 0 {
+				val = strings.TrimSpace(val[:i])
+			}
+			val = Expand(val, lookup)
+		}
+		val, err := ResolveSecret(val)
+		if err != nil {
+			return nil, fmt.Errorf("dotenv: %s: %v", name, err)
+		}
+		c.data[name] = val
+		c.rawValue[name] = val
+		c.lines = append(c.lines, dotenvLine{raw: raw, name: name})
+	}
+	return c, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote in s.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 't':
+			buf.WriteByte('\t')
+		case 'r':
+			buf.WriteByte('\r')
+		case '$':
+			// keep \${ from being expanded
+			if i+1 < len(s) && s[i+1] == '{' {
+				buf.WriteByte('$')
+			}
+			buf.WriteByte('$')
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String()
+}
+
+type dotenvLine struct {
+	raw  string // the line as written
+	name string // empty for comment and blank lines
+}
+
+// DotenvConfigContainer A Config represents the dotenv configuration.
+type DotenvConfigContainer struct {
+	data     map[string]string // NAME => value
+	rawValue map[string]string // NAME => value as read
+	lines    []dotenvLine
+	sync.RWMutex
+}
+
+// dotenvName converts section::key to SECTION_KEY.
+func dotenvName(key string) string {
+	key = strings.Replace(key, "::", "_", -1)
+	key = strings.Replace(key, ".", "_", -1)
+	key = strings.Replace(key, "-", "_", -1)
+	return strings.ToUpper(key)
+}
+
+func (c *DotenvConfigContainer) getData(key string) (string, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	v, ok := c.data[dotenvName(key)]
+	return v, ok
+}
+
+// Bool returns the boolean value for a given key.
+func (c *DotenvConfigContainer) Bool(key string) (bool, error) {
+	return ParseBool(c.String(key))
+}
+
+// DefaultBool return the bool value if has no error
+// otherwise return the defaultval
+func (c *DotenvConfigContainer) DefaultBool(key string, defaultval bool) bool {
+	v, err := c.Bool(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// Int returns the integer value for a given key.
+func (c *DotenvConfigContainer) Int(key string) (int, error) {
+	return strconv.Atoi(c.String(key))
+}
+
+// DefaultInt returns the integer value for a given key.
+// if err != nil return defaltval
+func (c *DotenvConfigContainer) DefaultInt(key string, defaultval int) int {
+	v, err := c.Int(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// Int64 returns the int64 value for a given key.
+func (c *DotenvConfigContainer) Int64(key string) (int64, error) {
+	return strconv.ParseInt(c.String(key), 10, 64)
+}
+
+// DefaultInt64 returns the int64 value for a given key.
+// if err != nil return defaltval
+func (c *DotenvConfigContainer) DefaultInt64(key string, defaultval int64) int64 {
+	v, err := c.Int64(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// Float returns the float value for a given key.
+func (c *DotenvConfigContainer) Float(key string) (float64, error) {
+	return strconv.ParseFloat(c.String(key), 64)
+}
+
+// DefaultFloat returns the float64 value for a given key.
+// if err != nil return defaltval
+func (c *DotenvConfigContainer) DefaultFloat(key string, defaultval float64) float64 {
+	v, err := c.Float(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// String returns the string value for a given key.
+func (c *DotenvConfigContainer) String(key string) string {
+	v, _ := c.getData(key)
+	return v
+}
+
+// DefaultString returns the string value for a given key.
+// if err != nil return defaltval
+func (c *DotenvConfigContainer) DefaultString(key string, defaultval string) string {
+	if v := c.String(key); v != "" {
+		return v
+	}
+	return defaultval
+}
+
+// Strings returns the []string value for a given key.
+func (c *DotenvConfigContainer) Strings(key string) []string {
+	v := c.String(key)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ";")
+}
+
+// DefaultStrings returns the []string value for a given key.
+// if err != nil return defaltval
+func (c *DotenvConfigContainer) DefaultStrings(key string, defaultval []string) []string {
+	if v := c.Strings(key); v != nil {
+		return v
+	}
+	return defaultval
+}
+
+// GetSection returns map for the given section,
+// the keys are the lower case names without the SECTION_ prefix.
+func (c *DotenvConfigContainer) GetSection(section string) (map[string]string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	prefix := dotenvName(section) + "_"
+	m := make(map[string]string)
+	for name, v := range c.data {
+		if section == DefaultSection {
+			m[strings.ToLower(name)] = v
+		} else if strings.HasPrefix(name, prefix) {
+			m[strings.ToLower(name[len(prefix):])] = v
+		}
+	}
+	if len(m) == 0 {
+		return nil, errors.New("not exist section")
+	}
+	return m, nil
+}
+
+// SaveConfigFile save the config into file.
+// Comments, blank lines and the lines of unchanged values are written as they were read.
+func (c *DotenvConfigContainer) SaveConfigFile(filename string) error {
+	c.RLock()
+	defer c.RUnlock()
+	var buf bytes.Buffer
+	for _, line := range c.lines {
+		if len(line.name) == 0 || c.data[line.name] == c.rawValue[line.name] {
+			buf.WriteString(line.raw)
+		} else {
+			buf.WriteString(line.name + "=" + quoteDotenv(c.data[line.name]))
+		}
+		buf.WriteByte('\n')
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// quoteDotenv quotes the value if needed, so that it reads back unchanged.
+func quoteDotenv(v string) string {
+	if strings.IndexFunc(v, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_./:@+,-", r))
+	}) < 0 {
+		return v
+	}
+	if !strings.ContainsAny(v, "'\n") {
+		return "'" + v + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// Set writes a new value for key, a new name is appended at the end of file when saved.
+func (c *DotenvConfigContainer) Set(key, val string) error {
+	c.Lock()
+	defer c.Unlock()
+	name := dotenvName(key)
+	if _, ok := c.data[name]; !ok {
+		c.lines = append(c.lines, dotenvLine{name: name})
+		c.rawValue[name] = "\x00" // never equal, the line is always written
+	}
+	c.data[name] = val
+	return nil
+}
+
+// DIY returns the raw value by a given key.
+func (c *DotenvConfigContainer) DIY(key string) (interface{}, error) {
+	if v, ok := c.getData(key); ok {
+		return v, nil
+	}
+	return nil, errors.New("not exist key")
+}
+
+func init() {
+	Register("dotenv", &DotenvConfig{})
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("EXPAND_TEST_SET", "value")
+	os.Setenv("EXPAND_TEST_EMPTY", "")
+	os.Unsetenv("EXPAND_TEST_UNSET")
+	for in, out := range map[string]string{
+		"${EXPAND_TEST_SET}":                   "value",
+		"a-${EXPAND_TEST_SET}-b":               "a-value-b",
+		"${EXPAND_TEST_UNSET}":                 "",
+		"${EXPAND_TEST_UNSET:-def}":            "def",
+		"${EXPAND_TEST_EMPTY:-def}":            "def",
+		"${EXPAND_TEST_SET:-def}":              "value",
+		"$EXPAND_TEST_SET p@$$w0rd":            "$EXPAND_TEST_SET p@$$w0rd",
+		"$${EXPAND_TEST_SET}":                  "${EXPAND_TEST_SET}",
+		"${EXPAND_TEST_SET":                    "${EXPAND_TEST_SET",
+		"${EXPAND_TEST_SET}${EXPAND_TEST_SET}": "valuevalue",
+	} {
+		if v := ExpandEnv(in); v != out {
+			t.Errorf("ExpandEnv(%q) = %q, want %q", in, v, out)
+		}
+	}
+}
+
+func TestExpandIniAndJSON(t *testing.T) {
+	os.Setenv("EXPAND_TEST_HOST", "db.local")
+	ini, err := NewConfigData("ini", []byte("[db]\nhost = ${EXPAND_TEST_HOST}\nport = ${EXPAND_TEST_PORT:-3306}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ini.String("db::host"); v != "db.local" {
+		t.Errorf("ini db::host: %q", v)
+	}
+	if v, _ := ini.Int("db::port"); v != 3306 {
+		t.Errorf("ini db::port: %v", v)
+	}
+	js, err := NewConfigData("json", []byte(`{"db": {"hosts": ["${EXPAND_TEST_HOST}"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := js.DIY("db::hosts"); v.([]interface{})[0] != "db.local" {
+		t.Errorf("json db::hosts: %v", v)
+	}
+}
+
+const dotenvContext = `# database
+export DB_HOST=localhost
+DB_PORT=5432 # inline comment
+DB_DSN="postgres://${DB_HOST}:${DB_PORT}/app"
+DB_NOTE='raw ${DB_HOST}'
+CERT="line1
+line2"
+DEBUG=true
+`
+
+func TestDotenv(t *testing.T) {
+	c, err := NewConfigData("dotenv", []byte(dotenvContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.String("db::host"); v != "localhost" {
+		t.Errorf("db::host: %q", v)
+	}
+	if v, err := c.Int("db::port"); err != nil || v != 5432 {
+		t.Errorf("db::port: %v %v", v, err)
+	}
+	if v := c.String("db::dsn"); v != "postgres://localhost:5432/app" {
+		t.Errorf("db::dsn: %q", v)
+	}
+	if v := c.String("DB_NOTE"); v != "raw ${DB_HOST}" {
+		t.Errorf("DB_NOTE: %q", v)
+	}
+	if v := c.String("cert"); v != "line1\nline2" {
+		t.Errorf("cert: %q", v)
+	}
+	if v, _ := c.Bool("debug"); !v {
+		t.Error("debug should be true")
+	}
+	section, err := c.GetSection("db")
+	if err != nil || len(section) != 4 || section["port"] != "5432" {
+		t.Errorf("db section: %v %v", section, err)
+	}
+}
+
+func TestDotenvSave(t *testing.T) {
+	c, err := NewConfigData("dotenv", []byte(dotenvContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err = c.SaveConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(f.Name())
+	if string(b) != dotenvContext {
+		t.Fatalf("unchanged config should be saved as is, got:\n%s", b)
+	}
+
+	c.Set("db::port", "6432")
+	c.Set("app::secret", "it's ${not} expanded")
+	if err = c.SaveConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewConfig("dotenv", f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"db::host", "db::port", "db_note", "cert", "app::secret"} {
+		if c.String(key) != c2.String(key) {
+			t.Errorf("%s: %q != %q", key, c.String(key), c2.String(key))
+		}
+	}
+	// the reference to DB_PORT is kept
+	if v := c2.String("db::dsn"); v != "postgres://localhost:6432/app" {
+		t.Errorf("db::dsn: %q", v)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} in s with the values of the environment variables.
+// ${VAR:-default} uses default when VAR is unset or empty, and $${ is written as a literal ${.
+// Other uses of $ are left unchanged, so values like passwords are safe.
+func ExpandEnv(s string) string {
+	return Expand(s, os.LookupEnv)
+}
+
+// Expand is like ExpandEnv, but looks up the variables with the mapping function.
+func Expand(s string, mapping func(string) (string, bool)) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	i := 0
+	for j := 0; j < len(s); j++ {
+		if s[j] != '$' || j+1 >= len(s) {
+			continue
+		}
+		if s[j+1] == '$' && j+2 < len(s) && s[j+2] == '{' {
+			buf = append(buf, s[i:j+1]...)
+			i = j + 2
+			j++
+			continue
+		}
+		if s[j+1] != '{' {
+			continue
+		}
+		end := strings.IndexByte(s[j+2:], '}')
+		if end < 0 {
+			break
+		}
+		name, def := s[j+2:j+2+end], ""
+		var hasDefault bool
+		if k := strings.Index(name, ":-"); k >= 0 {
+			name, def, hasDefault = name[:k], name[k+2:], true
+		}
+		val, ok := mapping(name)
+		if hasDefault && (!ok || len(val) == 0) {
+			val = def
+		}
+		buf = append(buf, s[i:j]...)
+		buf = append(buf, val...)
+		j += 2 + end
+		i = j + 1
+	}
+	return string(append(buf, s[i:]...))
+}
+
+// ExpandValue applies ExpandEnv to all the strings in v,
+// which may be a string, a map[string]interface{} or a []interface{}.
+// maps and slices are changed in place.
+func ExpandValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		return ExpandEnv(x)
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = ExpandValue(e)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = ExpandValue(e)
+		}
+	}
+	return v
+}
@@ -58,17 +58,22 @@ func (ini *IniConfig) parseFile(name string) (*IniConfigContainer, error) {
 	}
 
 	cfg := &IniConfigContainer{
-		file.Name(),
-		make(map[string]map[string]string),
-		make(map[string]string),
-		make(map[string]string),
-		sync.RWMutex{},
+		filename:       file.Name(),
+		data:           make(map[string]map[string]string),
+		sectionComment: make(map[string]string),
+		keyComment:     make(map[string]string),
+		keyOrder:       make(map[string][]string),
+		rawLines:       make(map[string][]string),
+		rawLine:        make(map[string]string),
+		rawValue:       make(map[string]string),
 	}
 	cfg.Lock()
 	defer cfg.Unlock()
 	defer file.Close()
 
 	var comment bytes.Buffer
+	// comment and blank lines as written, kept for SaveConfigFile
+	var pending []string
 	buf := bufio.NewReader(file)
 	// check the BOM
 	head, err := buf.Peek(3)
@@ -83,10 +88,12 @@ func (ini *IniConfig) parseFile(name string) (*IniConfigContainer, error) {
 		if err == io.EOF {
 			break
 		}
+		raw := string(line)
+		line = bytes.TrimSpace(line)
 		if bytes.Equal(line, bEmpty) {
+			pending = append(pending, raw)
 			continue
 		}
-		line = bytes.TrimSpace(line)
 
 		var bComment []byte
 		switch {
@@ -96,6 +103,7 @@ func (ini *IniConfig) parseFile(name string) (*IniConfigContainer, error) {
 			bComment = bSemComment
 		}
 		if bComment != nil {
+			pending = append(pending, raw)
 			line = bytes.TrimLeft(line, string(bComment))
 			// Need append to a new line if multi-line comments.
 			if comment.Len() > 0 {
@@ -111,15 +119,14 @@ func (ini *IniConfig) parseFile(name string) (*IniConfigContainer, error) {
 				cfg.sectionComment[section] = comment.String()
 				comment.Reset()
 			}
-			if _, ok := cfg.data[section]; !ok {
-				cfg.data[section] = make(map[string]string)
-			}
+			cfg.addSection(section)
+			cfg.rawLines[section] = pending
+			cfg.rawLine[section] = raw
+			pending = nil
 			continue
 		}
 
-		if _, ok := cfg.data[section]; !ok {
-			cfg.data[section] = make(map[string]string)
-		}
+		cfg.addSection(section)
 		keyValue := bytes.SplitN(line, bEqual, 2)
 
 		key := string(bytes.TrimSpace(keyValue[0])) // key name case insensitive
@@ -137,6 +144,8 @@ func (ini *IniConfig) parseFile(name string) (*IniConfigContainer, error) {
 				if err != nil {
 					return nil, err
 				}
+				// the include line is written back as is, the included keys are not.
+				pending = append(pending, raw)
 				for sec, dt := range i.data {
 					if _, ok := cfg.data[sec]; !ok {
 						cfg.data[sec] = make(map[string]string)
@@ -162,14 +171,25 @@ func (ini *IniConfig) parseFile(name string) (*IniConfigContainer, error) {
 		if bytes.HasPrefix(val, bDQuote) {
 			val = bytes.Trim(val, `"`)
 		}
+		resolved, err := ResolveSecret(ExpandEnv(string(val)))
+		if err != nil {
+			return nil, fmt.Errorf("%s::%s: %v", section, key, err)
+		}
+		val = []byte(resolved)
 
+		cfg.addKey(section, key)
 		cfg.data[section][key] = string(val)
+		cfg.rawLines[section+"."+key] = pending
+		cfg.rawLine[section+"."+key] = raw
+		cfg.rawValue[section+"."+key] = string(val)
+		pending = nil
 		if comment.Len() > 0 {
 			cfg.keyComment[section+"."+key] = comment.String()
 			comment.Reset()
 		}
 
 	}
+	cfg.tailLines = pending
 	return cfg, nil
 }
 
@@ -186,14 +206,43 @@ func (ini *IniConfig) ParseData(data []byte) (Configer, error) {
 
 // IniConfigContainer A Config represents the ini configuration.
 // When set and get value, support key as section:name type.
+// The order of sections and keys, comments, blank lines and the original
+// lines of unchanged keys are kept when it is saved.
 type IniConfigContainer struct {
 	filename       string
 	data           map[string]map[string]string // section=> key:val
 	sectionComment map[string]string            // section : comment
 	keyComment     map[string]string            // id: []{comment, key...}; id 1 is for main comment.
+	sectionOrder   []string                     // sections in the order they appear
+	keyOrder       map[string][]string          // section => keys in the order they appear
+	rawLines       map[string][]string          // section or section.key => comment and blank lines above it, as written
+	rawLine        map[string]string            // section or section.key => the line as written
+	rawValue       map[string]string            // section.key => the value as read
+	tailLines      []string                     // comment and blank lines at the end of file
 	sync.RWMutex
 }
 
+func (c *IniConfigContainer) addSection(section string) {
+	if _, ok := c.data[section]; !ok {
+		c.data[section] = make(map[string]string)
+	}
+	for _, s := range c.sectionOrder {
+		if s == section {
+			return
+		}
+	}
+	c.sectionOrder = append(c.sectionOrder, section)
+}
+
+func (c *IniConfigContainer) addKey(section, key string) {
+	for _, k := range c.keyOrder[section] {
+		if k == key {
+			return
+		}
+	}
+	c.keyOrder[section] = append(c.keyOrder[section], key)
+}
+
 func (c *IniConfigContainer) MainKeys() []string {
 	l := len(c.data[DefaultSection])
 	a := make([]string, l)
@@ -372,82 +421,67 @@ func (c *IniConfigContainer) SaveConfigFile(filename string) (err error) {
 
 	buf := bytes.NewBuffer(nil)
 	// Save default section at first place
-	if dt, ok := c.data[DefaultSection]; ok {
-		keys := []string{}
-		for key := range dt {
-			keys = append(keys, key)
-		}
-		sort.Strings(keys)
-		for _, key := range keys {
-			val := dt[key]
-			if key != " " {
-				// Write key comments.
-				if v := getCommentStr(DefaultSection, key); len(v) > 0 {
-					if _, err = buf.WriteString(v + lineBreak); err != nil {
-						return err
-					}
-				}
-
-				// Write key and value.
-				if _, err = buf.WriteString(key + string(bEqual) + val + lineBreak); err != nil {
-					return err
-				}
-			}
-		}
-
-		// Put a line between sections.
-		if _, err = buf.WriteString(lineBreak); err != nil {
-			return err
-		}
+	sections := make([]string, 0, len(c.sectionOrder))
+	if _, ok := c.data[DefaultSection]; ok {
+		sections = append(sections, DefaultSection)
 	}
-	// Save named sections
-	sections := []string{}
-	for section := range c.data {
-		sections = append(sections, section)
+	for _, section := range c.sectionOrder {
+		if section != DefaultSection {
+			sections = append(sections, section)
+		}
 	}
-	sort.Strings(sections)
 	for _, section := range sections {
-		dt := c.data[section]
 		if section != DefaultSection {
-			// Write section comments.
-			if v := getCommentStr(section, ""); len(v) > 0 {
-				if _, err = buf.WriteString(v + lineBreak); err != nil {
-					return err
+			if lines, ok := c.rawLines[section]; ok {
+				// Write comments and blank lines as they were.
+				for _, line := range lines {
+					buf.WriteString(line + lineBreak)
+				}
+			} else {
+				// Put a line between sections.
+				if buf.Len() > 0 {
+					buf.WriteString(lineBreak)
+				}
+				// Write section comments.
+				if v := getCommentStr(section, ""); len(v) > 0 {
+					buf.WriteString(v + lineBreak)
 				}
 			}
 
 			// Write section name.
-			if _, err = buf.WriteString(string(sectionStart) + section + string(sectionEnd) + lineBreak); err != nil {
-				return err
+			if line, ok := c.rawLine[section]; ok {
+				buf.WriteString(line + lineBreak)
+			} else {
+				buf.WriteString(string(sectionStart) + section + string(sectionEnd) + lineBreak)
 			}
-			keys := []string{}
-			for key := range dt {
-				keys = append(keys, key)
+		}
+		dt := c.data[section]
+		for _, key := range c.keyOrder[section] {
+			val, ok := dt[key]
+			if !ok || key == " " {
+				continue
 			}
-			sort.Strings(keys)
-			for _, key := range keys {
-				val := dt[key]
-				if key != " " {
-					// Write key comments.
-					if v := getCommentStr(section, key); len(v) > 0 {
-						if _, err = buf.WriteString(v + lineBreak); err != nil {
-							return err
-						}
-					}
-
-					// Write key and value.
-					if _, err = buf.WriteString(key + string(bEqual) + val + lineBreak); err != nil {
-						return err
-					}
+			id := section + "." + key
+			if lines, ok := c.rawLines[id]; ok {
+				for _, line := range lines {
+					buf.WriteString(line + lineBreak)
 				}
+			} else if v := getCommentStr(section, key); len(v) > 0 {
+				// Write key comments.
+				buf.WriteString(v + lineBreak)
 			}
 
-			// Put a line between sections.
-			if _, err = buf.WriteString(lineBreak); err != nil {
-				return err
+			// Write key and value, keep the original line if the value is unchanged.
+			if line, ok := c.rawLine[id]; ok && c.rawValue[id] == val {
+				buf.WriteString(line + lineBreak)
+			} else {
+				buf.WriteString(key + string(bEqual) + val + lineBreak)
 			}
 		}
 	}
+	for _, line := range c.tailLines {
+		buf.WriteString(line + lineBreak)
+	}
 
 	if _, err = buf.WriteTo(f); err != nil {
 		return err
@@ -478,9 +512,8 @@ func (c *IniConfigContainer) Set(key, value string) error {
 		k = sectionKey[0]
 	}
 
-	if _, ok := c.data[section]; !ok {
-		c.data[section] = make(map[string]string)
-	}
+	c.addSection(section)
+	c.addKey(section, k)
 	c.data[section][k] = value
 	return nil
 }
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
 	"testing"
 )
 
@@ -128,6 +127,7 @@ peers = one;two;three
 func TestIniSave(t *testing.T) {
 
 	const (
+		// saved as is
 		inicontext = `
 app = app
 ;comment one
@@ -141,22 +141,6 @@ httpport = 8080
 # db type name
 # suport mysql,sqlserver
 name = mysql
-`
-
-		saveResult = `
-app=app
-#comment one
-#comment two
-# comment three
-appname=beeapi
-httpport=8080
-
-# DB Info
-# enable db
-[dbinfo]
-# db type name
-# suport mysql,sqlserver
-name=mysql
 `
 	)
 	cfg, err := NewConfigData("ini", []byte(inicontext))
@@ -171,14 +155,57 @@ name=mysql
 
 	if data, err := ioutil.ReadFile(name); err != nil {
 		t.Fatal(err)
-	} else {
-		cfgData := string(data)
-		datas := strings.Split(saveResult, "\n")
-		for _, line := range datas {
-			if strings.Contains(cfgData, line+"\n") == false {
-				t.Fatalf("different after save ini config file. need contains %q", line)
-			}
-		}
+	} else if string(data) != inicontext {
+		t.Fatalf("different after save ini config file:\n%s", data)
+	}
+}
 
+func TestIniSaveKeepLayout(t *testing.T) {
+	const (
+		inicontext = `# team settings
+
+[listen]
+; public address
+Address = "0.0.0.0:80"
+custom = kept
+
+[z]
+a = 1
+# the end
+`
+		saveResult = `# team settings
+
+[listen]
+; public address
+Address = "0.0.0.0:80"
+custom = kept
+readtimeout=30
+
+[z]
+a=2
+
+[session]
+sessionon=true
+# the end
+`
+	)
+	cfg, err := NewConfigData("ini", []byte(inicontext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Set("listen::address", "0.0.0.0:80")
+	cfg.Set("listen::readtimeout", "30")
+	cfg.Set("z::a", "2")
+	cfg.Set("session::sessionon", "true")
+
+	name := "keepLayout.ini"
+	if err := cfg.SaveConfigFile(name); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	if data, err := ioutil.ReadFile(name); err != nil {
+		t.Fatal(err)
+	} else if string(data) != saveResult {
+		t.Fatalf("want:\n%s\ngot:\n%s", saveResult, data)
 	}
 }
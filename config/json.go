@@ -57,6 +57,10 @@ func (js *JSONConfig) ParseData(data []byte) (Configer, error) {
 		}
 		x.data["rootArray"] = wrappingArray
 	}
+	x.raw = CloneValue(x.data).(map[string]interface{})
+	if _, err = ResolveValue(x.data); err != nil {
+		return nil, err
+	}
 	return x, nil
 }
 
@@ -64,6 +68,7 @@ func (js *JSONConfig) ParseData(data []byte) (Configer, error) {
 // Only when get value, support key as section:name type.
 type JSONConfigContainer struct {
 	data map[string]interface{}
+	raw  map[string]interface{} // the values as read, which are saved
 	sync.RWMutex
 }
 
@@ -195,7 +200,8 @@ func (c *JSONConfigContainer) GetSection(section string) (map[string]string, err
 	return nil, errors.New("nonexist section " + section)
 }
 
-// SaveConfigFile save the config into file
+// SaveConfigFile save the config into file,
+// the ${VAR} and secret values are written as they were read.
 func (c *JSONConfigContainer) SaveConfigFile(filename string) (err error) {
 	// Write configuration file by filename.
 	f, err := os.Create(filename)
@@ -203,7 +209,9 @@ func (c *JSONConfigContainer) SaveConfigFile(filename string) (err error) {
 		return err
 	}
 	defer f.Close()
-	b, err := json.MarshalIndent(c.data, "", "  ")
+	c.RLock()
+	b, err := json.MarshalIndent(c.raw, "", "  ")
+	c.RUnlock()
 	if err != nil {
 		return err
 	}
@@ -216,6 +224,7 @@ func (c *JSONConfigContainer) Set(key, val string) error {
 	c.Lock()
 	defer c.Unlock()
 	c.data[key] = val
+	c.raw[key] = val
 	return nil
 }
 
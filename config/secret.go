@@ -0,0 +1,245 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Secret values in the config files, they are resolved when the file is parsed:
+//
+//	enc:<ciphertext>  decrypted with the secret key, see EncryptSecret
+//	file:<path>       the content of the file, without the trailing newline
+//	raw:<value>       the value as is, for plain values starting with enc:, file: or raw:
+//
+// Plain values of these forms, such as the sqlite DSN file:test.db, must be
+// written with raw:, e.g. raw:file:test.db?cache=shared.
+const (
+	SecretEncPrefix  = "enc:"
+	SecretFilePrefix = "file:"
+	SecretRawPrefix  = "raw:"
+
+	// SecretKeyEnv is the environment variable holding the secret key.
+	SecretKeyEnv = "LESSGO_SECRET_KEY"
+	// SecretKeyFileEnv is the environment variable holding the path of the secret key file,
+	// which is used when SecretKeyEnv is not set.
+	SecretKeyFileEnv = "LESSGO_SECRET_KEY_FILE"
+
+	secretMask = "******"
+	// shorter secrets are not masked in log messages, which would garble them
+	minMaskLen = 4
+)
+
+var (
+	ErrNoSecretKey = errors.New("config: no secret key, set " + SecretKeyEnv + " or " + SecretKeyFileEnv)
+
+	secretLock sync.RWMutex
+	secretKey  []byte
+	// the resolved secret values
+	secrets = make(map[string]bool)
+)
+
+// SetSecretKey sets the key used by EncryptSecret and to decrypt enc: values,
+// it overrides SecretKeyEnv and SecretKeyFileEnv. any length of key is accepted,
+// and nil restores the key from the environment.
+func SetSecretKey(key []byte) {
+	secretLock.Lock()
+	defer secretLock.Unlock()
+	if key == nil {
+		secretKey = nil
+		return
+	}
+	secretKey = deriveKey(key)
+}
+
+func deriveKey(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:]
+}
+
+func getSecretKey() ([]byte, error) {
+	secretLock.RLock()
+	key := secretKey
+	secretLock.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+	if k := os.Getenv(SecretKeyEnv); len(k) > 0 {
+		return deriveKey([]byte(k)), nil
+	}
+	if fname := os.Getenv(SecretKeyFileEnv); len(fname) > 0 {
+		b, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return nil, fmt.Errorf("config: read secret key file: %v", err)
+		}
+		return deriveKey([]byte(strings.TrimRight(string(b), "\r\n"))), nil
+	}
+	return nil, ErrNoSecretKey
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts the value with AES-GCM and returns it as enc:<ciphertext>,
+// which can be written in a config file.
+func EncryptSecret(plaintext string) (string, error) {
+	key, err := getSecretKey()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return SecretEncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts a value returned by EncryptSecret.
+func DecryptSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, SecretEncPrefix) {
+		return "", errors.New("config: secret must start with " + SecretEncPrefix)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[len(SecretEncPrefix):]))
+	if err != nil {
+		return "", fmt.Errorf("config: invalid secret: %v", err)
+	}
+	key, err := getSecretKey()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("config: invalid secret: too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("config: invalid secret: wrong key or corrupted value")
+	}
+	return string(plain), nil
+}
+
+// ResolveSecret returns the plain value of enc:, file: and raw: values,
+// other values are returned unchanged.
+// the resolved enc: and file: values are remembered, see MaskSecrets.
+func ResolveSecret(value string) (string, error) {
+	var (
+		plain string
+		err   error
+	)
+	switch {
+	case strings.HasPrefix(value, SecretEncPrefix):
+		plain, err = DecryptSecret(value)
+	case strings.HasPrefix(value, SecretFilePrefix):
+		var b []byte
+		b, err = ioutil.ReadFile(value[len(SecretFilePrefix):])
+		plain = strings.TrimRight(string(b), "\r\n")
+	case strings.HasPrefix(value, SecretRawPrefix):
+		return value[len(SecretRawPrefix):], nil
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(plain) > 0 {
+		secretLock.Lock()
+		secrets[plain] = true
+		secretLock.Unlock()
+	}
+	return plain, nil
+}
+
+// ResolveValue applies ExpandEnv and ResolveSecret to all the strings in v,
+// which may be a string, a map[string]interface{} or a []interface{}.
+// maps and slices are changed in place.
+func ResolveValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		return ResolveSecret(ExpandEnv(x))
+	case map[string]interface{}:
+		for k, e := range x {
+			r, err := ResolveValue(e)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			x[k] = r
+		}
+	case []interface{}:
+		for i, e := range x {
+			r, err := ResolveValue(e)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = r
+		}
+	}
+	return v, nil
+}
+
+// CloneValue returns a deep copy of v, which may be a map[string]interface{}
+// or a []interface{}, so that the values as read are kept when the copy is
+// resolved by ResolveValue.
+func CloneValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = CloneValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, e := range x {
+			s[i] = CloneValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+// IsSecret reports whether the value is a resolved secret.
+func IsSecret(value string) bool {
+	secretLock.RLock()
+	defer secretLock.RUnlock()
+	return secrets[value]
+}
+
+// MaskSecrets replaces the resolved secrets in s with ******.
+func MaskSecrets(s string) string {
+	secretLock.RLock()
+	defer secretLock.RUnlock()
+	if len(secrets) == 0 {
+		return s
+	}
+	if secrets[s] {
+		return secretMask
+	}
+	for secret := range secrets {
+		if len(secret) >= minMaskLen && strings.Contains(s, secret) {
+			s = strings.Replace(s, secret, secretMask, -1)
+		}
+	}
+	return s
+}
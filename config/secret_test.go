@@ -0,0 +1,84 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	SetSecretKey([]byte("test key"))
+	defer SetSecretKey(nil)
+
+	enc, err := EncryptSecret("s3cr3t-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, SecretEncPrefix) || strings.Contains(enc, "s3cr3t") {
+		t.Fatalf("unexpected ciphertext %q", enc)
+	}
+	keyFile, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile.WriteString("file-password\n")
+	keyFile.Close()
+	defer os.Remove(keyFile.Name())
+
+	c, err := NewConfigData("ini", []byte(`
+[db]
+password = `+enc+`
+token = file:`+keyFile.Name()+`
+note = raw:file:not/a/secret
+dsn = raw:file:test.db?cache=shared
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.String("db::password"); v != "s3cr3t-password" {
+		t.Errorf("db::password: %q", v)
+	}
+	if v := c.String("db::token"); v != "file-password" {
+		t.Errorf("db::token: %q", v)
+	}
+	if v := c.String("db::note"); v != "file:not/a/secret" {
+		t.Errorf("db::note: %q", v)
+	}
+	if v := c.String("db::dsn"); v != "file:test.db?cache=shared" {
+		t.Errorf("db::dsn: %q", v)
+	}
+	if !IsSecret("s3cr3t-password") || IsSecret("file:not/a/secret") {
+		t.Error("IsSecret")
+	}
+	if v := MaskSecrets("dsn=root:s3cr3t-password@db token=file-password"); v != "dsn=root:******@db token=******" {
+		t.Errorf("MaskSecrets: %q", v)
+	}
+
+	// the values as read are saved
+	c, err = NewConfigData("json", []byte(`{"db": {"password": "`+enc+`", "dsn": "root:${SECRET_TEST_PASS}@db"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("name", "demo")
+	f, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err = c.SaveConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(f.Name())
+	for _, s := range []string{enc, "${SECRET_TEST_PASS}", `"name": "demo"`} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected %q in the saved file:\n%s", s, b)
+		}
+	}
+
+	SetSecretKey([]byte("wrong key"))
+	if _, err = NewConfigData("json", []byte(`{"password": "`+enc+`"}`)); err == nil {
+		t.Error("expected an error for the wrong key")
+	}
+}
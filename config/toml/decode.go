@@ -0,0 +1,615 @@
+package toml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Decode parses a TOML document into a map.
+// Tables are map[string]interface{}, arrays are []interface{},
+// and values are string, int64, float64, bool or time.Time.
+// The elements of an array may be of different types (TOML 1.0).
+func Decode(doc string) (map[string]interface{}, error) {
+	root, _, err := decode(doc)
+	return root, err
+}
+
+// decode parses the document and records its layout.
+func decode(doc string) (map[string]interface{}, *layout, error) {
+	p := &parser{s: strings.Replace(doc, "\r\n", "\n", -1), line: 1}
+	p.s = strings.TrimPrefix(p.s, "\ufeff")
+	p.layout = &layout{
+		doc:    p.s,
+		values: make(map[string]span),
+		tables: map[string]int{"": 0},
+	}
+	root := make(map[string]interface{})
+	if err := p.parse(root); err != nil {
+		return nil, nil, err
+	}
+	return root, p.layout, nil
+}
+
+type parser struct {
+	s       string
+	pos     int
+	line    int
+	defined map[string]bool // tables defined by a [header]
+	layout  *layout
+	path    []string // the table of the values being parsed, nil if they are not recorded
+}
+
+// Error is a TOML syntax error.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("toml: line %d: %s", e.Line, e.Msg)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.s[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) hasPrefix(s string) bool { return strings.HasPrefix(p.s[p.pos:], s) }
+
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips spaces, newlines and comments.
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if p.peek() != '\n' {
+			return
+		}
+		p.next()
+	}
+}
+
+// endOfLine expects only spaces and a comment up to the end of line.
+func (p *parser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parse(root map[string]interface{}) error {
+	p.defined = make(map[string]bool)
+	p.path = []string{}
+	cur := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			cur, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(cur)
+		}
+		if err != nil {
+			return err
+		}
+		if err = p.endOfLine(); err != nil {
+			return err
+		}
+		if p.path != nil {
+			p.layout.tables[strings.Join(p.path, "::")] = p.pos
+		}
+	}
+}
+
+// parseHeader parses [table] and [[array.of.tables]] and returns the table.
+func (p *parser) parseHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	p.next()
+	isArray := p.peek() == '['
+	if isArray {
+		p.next()
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	closing := "]"
+	if isArray {
+		closing = "]]"
+	}
+	if !p.hasPrefix(closing) {
+		return nil, p.errorf("expected %s", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.walk(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	if isArray {
+		var arr []interface{}
+		switch v := parent[last].(type) {
+		case nil:
+		case []interface{}:
+			arr = v
+		default:
+			return nil, p.errorf("key %q is not an array of tables", strings.Join(keys, "."))
+		}
+		t := make(map[string]interface{})
+		parent[last] = append(arr, t)
+		p.path = nil
+		return t, nil
+	}
+	name := strings.Join(keys, "\x00")
+	if p.defined[name] {
+		return nil, p.errorf("table %q is defined twice", strings.Join(keys, "."))
+	}
+	p.defined[name] = true
+	p.path = keys
+	switch v := parent[last].(type) {
+	case nil:
+		t := make(map[string]interface{})
+		parent[last] = t
+		return t, nil
+	case map[string]interface{}:
+		return v, nil
+	}
+	return nil, p.errorf("key %q is not a table", strings.Join(keys, "."))
+}
+
+// walk returns the table of the dotted keys, creating the missing tables.
+// an array of tables stands for its last table.
+func (p *parser) walk(t map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for i, k := range keys {
+		switch v := t[k].(type) {
+		case nil:
+			sub := make(map[string]interface{})
+			t[k] = sub
+			t = sub
+		case map[string]interface{}:
+			t = v
+		case []interface{}:
+			if len(v) == 0 {
+				return nil, p.errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+			}
+			sub, ok := v[len(v)-1].(map[string]interface{})
+			if !ok {
+				return nil, p.errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+			}
+			t = sub
+		default:
+			return nil, p.errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) parseKeyValue(t map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("expected = after key %q", strings.Join(keys, "."))
+	}
+	p.next()
+	p.skipSpace()
+	path := p.path
+	if path != nil {
+		p.path = append(path[:len(path):len(path)], keys...)
+	}
+	start := p.pos
+	v, err := p.parseValue()
+	if p.path != nil {
+		p.layout.values[strings.Join(p.path, "::")] = span{start, p.pos}
+	}
+	p.path = path
+	if err != nil {
+		return err
+	}
+	t, err = p.walk(t, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := t[last]; ok {
+		return p.errorf("key %q is defined twice", strings.Join(keys, "."))
+	}
+	t[last] = v
+	return nil
+}
+
+// parseKey parses a bare, quoted or dotted key.
+func (p *parser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var (
+			k   string
+			err error
+		)
+		switch c := p.peek(); {
+		case c == '"':
+			k, err = p.parseBasicString()
+		case c == '\'':
+			k, err = p.parseLiteralString()
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key, found %q", c)
+			}
+			k = p.s[start:p.pos]
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.next()
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.peek() {
+	case '"':
+		if p.hasPrefix(`"""`) {
+			return p.parseMultilineBasicString()
+		}
+		return p.parseBasicString()
+	case '\'':
+		if p.hasPrefix("'''") {
+			return p.parseMultilineLiteralString()
+		}
+		return p.parseLiteralString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+	return p.parseScalar()
+}
+
+func (p *parser) parseBasicString() (string, error) {
+	p.next()
+	var buf []byte
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.next()
+		switch c {
+		case '"':
+			return string(buf), nil
+		case '\\':
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, r...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+}
+
+func (p *parser) parseMultilineBasicString() (string, error) {
+	p.pos += 3
+	if p.peek() == '\n' {
+		p.next()
+	}
+	var buf []byte
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if p.hasPrefix(`"""`) {
+			p.pos += 3
+			// up to two quotes may end the content
+			for i := 0; i < 2 && p.peek() == '"'; i++ {
+				buf = append(buf, p.next())
+			}
+			return string(buf), nil
+		}
+		c := p.next()
+		if c != '\\' {
+			buf = append(buf, c)
+			continue
+		}
+		// a line ending backslash trims the following whitespace
+		rest := p.pos
+		for rest < len(p.s) && (p.s[rest] == ' ' || p.s[rest] == '\t') {
+			rest++
+		}
+		if rest < len(p.s) && p.s[rest] == '\n' {
+			p.pos = rest
+			for !p.eof() && strings.IndexByte(" \t\n", p.peek()) >= 0 {
+				p.next()
+			}
+			continue
+		}
+		r, err := p.parseEscape()
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, r...)
+	}
+}
+
+func (p *parser) parseEscape() ([]byte, error) {
+	if p.eof() {
+		return nil, p.errorf("unterminated string")
+	}
+	switch c := p.next(); c {
+	case 'b':
+		return []byte{'\b'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case 'n':
+		return []byte{'\n'}, nil
+	case 'f':
+		return []byte{'\f'}, nil
+	case 'r':
+		return []byte{'\r'}, nil
+	case 'e':
+		return []byte{0x1b}, nil
+	case '"', '\\':
+		return []byte{c}, nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return nil, p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return nil, p.errorf("invalid unicode escape \\%c%s", c, p.s[p.pos:p.pos+n])
+		}
+		p.pos += n
+		b := make([]byte, utf8.UTFMax)
+		return b[:utf8.EncodeRune(b, rune(code))], nil
+	default:
+		return nil, p.errorf("invalid escape \\%c", c)
+	}
+}
+
+func (p *parser) parseLiteralString() (string, error) {
+	p.next()
+	start := p.pos
+	for !p.eof() && p.peek() != '\'' {
+		if p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.s[start:p.pos]
+	p.next()
+	return s, nil
+}
+
+func (p *parser) parseMultilineLiteralString() (string, error) {
+	p.pos += 3
+	if p.peek() == '\n' {
+		p.next()
+	}
+	end := strings.Index(p.s[p.pos:], "'''")
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	end += p.pos + 3
+	// up to two quotes may end the content
+	for i := 0; i < 2 && end < len(p.s) && p.s[end] == '\''; i++ {
+		end++
+	}
+	s := p.s[p.pos : end-3]
+	for p.pos < end {
+		p.next()
+	}
+	return s, nil
+}
+
+func (p *parser) parseArray() ([]interface{}, error) {
+	p.next()
+	// the values in arrays are not recorded
+	path := p.path
+	p.path = nil
+	defer func() { p.path = path }()
+	arr := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.next()
+			return arr, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *parser) parseInlineTable() (map[string]interface{}, error) {
+	p.next()
+	t := make(map[string]interface{})
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.next()
+		return t, nil
+	}
+	for {
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case '}':
+			p.next()
+			return t, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table")
+		}
+	}
+}
+
+func (p *parser) parseScalar() (interface{}, error) {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n,]}#", p.peek()) < 0 {
+		p.pos++
+	}
+	tok := p.s[start:p.pos]
+	// a date and a time may be separated by a space
+	if len(tok) == 10 && tok[4] == '-' && p.pos+3 < len(p.s) && p.s[p.pos] == ' ' && isDigit(p.s[p.pos+1]) && isDigit(p.s[p.pos+2]) && p.s[p.pos+3] == ':' {
+		p.pos++
+		for !p.eof() && strings.IndexByte(" \t\n,]}#", p.peek()) < 0 {
+			p.pos++
+		}
+		tok = tok + "T" + p.s[start+11:p.pos]
+	}
+	switch tok {
+	case "":
+		return nil, p.errorf("expected a value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+	if t, ok := parseDatetime(tok); ok {
+		return t, nil
+	}
+	if strings.Contains(tok, "__") || strings.HasPrefix(tok, "_") || strings.HasSuffix(tok, "_") {
+		return nil, p.errorf("invalid number %q", tok)
+	}
+	num := strings.Replace(tok, "_", "", -1)
+	if len(num) > 2 && num[0] == '0' {
+		base := 0
+		switch num[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base > 0 {
+			n, err := strconv.ParseInt(num[2:], base, 64)
+			if err != nil {
+				return nil, p.errorf("invalid number %q", tok)
+			}
+			return n, nil
+		}
+	}
+	if strings.ContainsAny(num, ".eE") {
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok)
+		}
+		return f, nil
+	}
+	digits := strings.TrimLeft(num, "+-")
+	if len(digits) > 1 && digits[0] == '0' {
+		return nil, p.errorf("leading zeros are not allowed in %q", tok)
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid value %q", tok)
+	}
+	return n, nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func parseDatetime(s string) (time.Time, bool) {
+	if len(s) < 8 || !isDigit(s[0]) || !(len(s) > 4 && s[4] == '-' || s[2] == ':') {
+		return time.Time{}, false
+	}
+	for _, layout := range datetimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
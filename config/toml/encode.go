@@ -0,0 +1,270 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encode writes the map as a TOML document.
+// keys are sorted, the values of a table are written before its sub tables.
+func Encode(data map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	encodeTable(&buf, nil, data)
+	return buf.Bytes()
+}
+
+func encodeTable(buf *bytes.Buffer, path []string, t map[string]interface{}) {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tables, arrays []string
+	for _, k := range keys {
+		switch v := t[k].(type) {
+		case map[string]interface{}:
+			tables = append(tables, k)
+			continue
+		case []interface{}:
+			if isTableArray(v) {
+				arrays = append(arrays, k)
+				continue
+			}
+		}
+		buf.WriteString(encodeKey(k))
+		buf.WriteString(" = ")
+		encodeValue(buf, t[k])
+		buf.WriteByte('\n')
+	}
+	for _, k := range tables {
+		sub := append(path[:len(path):len(path)], k)
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("[" + encodePath(sub) + "]\n")
+		encodeTable(buf, sub, t[k].(map[string]interface{}))
+	}
+	for _, k := range arrays {
+		sub := append(path[:len(path):len(path)], k)
+		for _, e := range t[k].([]interface{}) {
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString("[[" + encodePath(sub) + "]]\n")
+			encodeTable(buf, sub, e.(map[string]interface{}))
+		}
+	}
+}
+
+func isTableArray(arr []interface{}) bool {
+	if len(arr) == 0 {
+		return false
+	}
+	for _, e := range arr {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func encodePath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = encodeKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+func encodeKey(k string) string {
+	if len(k) == 0 {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyChar(k[i]) {
+			return quote(k)
+		}
+	}
+	return k
+}
+
+func encodeValue(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case string:
+		buf.WriteString(quote(x))
+	case bool:
+		buf.WriteString(strconv.FormatBool(x))
+	case int:
+		buf.WriteString(strconv.Itoa(x))
+	case int64:
+		buf.WriteString(strconv.FormatInt(x, 10))
+	case float64:
+		buf.WriteString(formatFloat(x))
+	case time.Time:
+		buf.WriteString(x.Format(time.RFC3339Nano))
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range x {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			encodeValue(buf, e)
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(encodeKey(k) + " = ")
+			encodeValue(buf, x[k])
+		}
+		buf.WriteByte('}')
+	default:
+		buf.WriteString(quote(fmt.Sprint(x)))
+	}
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
+
+// quote returns a TOML basic string.
+func quote(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// span is the position of a value in a document.
+type span struct {
+	start, end int
+}
+
+// layout records where the values and the tables are written in a document,
+// so that it is saved with only the changed values rewritten.
+type layout struct {
+	doc    string
+	values map[string]span // section::key => the value, except the values in arrays
+	tables map[string]int  // section ("" for the top level) => the end of its last line
+}
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+type edits []edit
+
+func (e edits) Len() int           { return len(e) }
+func (e edits) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e edits) Less(i, j int) bool { return e[i].start < e[j].start }
+
+// save returns the document with the changed values (section::key => value)
+// rewritten in place. A new key is added at the end of its table, or in a
+// new table at the end of the document.
+func (l *layout) save(changed map[string]interface{}) []byte {
+	keys := make([]string, 0, len(changed))
+	for k := range changed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var (
+		list      edits
+		newTables []string
+		added     = make(map[string][]string)
+	)
+	for _, key := range keys {
+		var v bytes.Buffer
+		encodeValue(&v, changed[key])
+		if sp, ok := l.values[key]; ok {
+			list = append(list, edit{sp.start, sp.end, v.String()})
+			continue
+		}
+		table, name := "", key
+		if i := strings.LastIndex(key, "::"); i >= 0 {
+			table, name = key[:i], key[i+2:]
+		}
+		line := encodeKey(name) + " = " + v.String() + "\n"
+		if end, ok := l.tables[table]; ok {
+			list = append(list, edit{end, end, line})
+			continue
+		}
+		if _, ok := added[table]; !ok {
+			newTables = append(newTables, table)
+		}
+		added[table] = append(added[table], line)
+	}
+	sort.Stable(list)
+
+	var buf bytes.Buffer
+	pos := 0
+	for _, e := range list {
+		buf.WriteString(l.doc[pos:e.start])
+		if e.start == e.end && buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(e.text)
+		pos = e.end
+	}
+	buf.WriteString(l.doc[pos:])
+	for _, table := range newTables {
+		if buf.Len() > 0 {
+			if buf.Bytes()[buf.Len()-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("[" + encodePath(strings.Split(table, "::")) + "]\n")
+		for _, line := range added[table] {
+			buf.WriteString(line)
+		}
+	}
+	return buf.Bytes()
+}
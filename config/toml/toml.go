@@ -0,0 +1,332 @@
+// Package toml for config provider
+//
+// Usage:
+// import(
+//
+//	_ "github.com/henrylee2cn/lessgo/config/toml"
+//	"github.com/henrylee2cn/lessgo/config"
+//
+// )
+//
+//	cnf, err := config.NewConfig("toml", "config.toml")
+//
+// Keys are section::key, or section::table::key for nested tables.
+package toml
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/config"
+)
+
+// Config is a toml config parser and implements Config interface.
+type Config struct{}
+
+// Parse returns a ConfigContainer with parsed toml config map.
+func (tc *Config) Parse(filename string) (config.Configer, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return tc.ParseData(content)
+}
+
+// ParseData returns a ConfigContainer with toml data.
+func (tc *Config) ParseData(data []byte) (config.Configer, error) {
+	d, l, err := decode(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = config.ResolveValue(d); err != nil {
+		return nil, err
+	}
+	return &ConfigContainer{data: d, layout: l, changed: make(map[string]interface{})}, nil
+}
+
+// ConfigContainer A Config represents the toml configuration.
+type ConfigContainer struct {
+	data    map[string]interface{}
+	layout  *layout
+	changed map[string]interface{} // section::key => the value set
+	sync.RWMutex
+}
+
+// Bool returns the boolean value for a given key.
+func (c *ConfigContainer) Bool(key string) (bool, error) {
+	if v := c.getData(key); v != nil {
+		return config.ParseBool(v)
+	}
+	return false, fmt.Errorf("not exist key: %q", key)
+}
+
+// DefaultBool return the bool value if has no error
+// otherwise return the defaultval
+func (c *ConfigContainer) DefaultBool(key string, defaultval bool) bool {
+	v, err := c.Bool(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// Int returns the integer value for a given key.
+func (c *ConfigContainer) Int(key string) (int, error) {
+	v, err := c.Int64(key)
+	return int(v), err
+}
+
+// DefaultInt returns the integer value for a given key.
+// if err != nil return defaltval
+func (c *ConfigContainer) DefaultInt(key string, defaultval int) int {
+	v, err := c.Int(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// Int64 returns the int64 value for a given key.
+func (c *ConfigContainer) Int64(key string) (int64, error) {
+	switch v := c.getData(key).(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return 0, fmt.Errorf("not exist key: %q", key)
+	}
+	return 0, errors.New("not int value")
+}
+
+// DefaultInt64 returns the int64 value for a given key.
+// if err != nil return defaltval
+func (c *ConfigContainer) DefaultInt64(key string, defaultval int64) int64 {
+	v, err := c.Int64(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// Float returns the float value for a given key.
+func (c *ConfigContainer) Float(key string) (float64, error) {
+	switch v := c.getData(key).(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case nil:
+		return 0, fmt.Errorf("not exist key: %q", key)
+	}
+	return 0, errors.New("not float64 value")
+}
+
+// DefaultFloat returns the float64 value for a given key.
+// if err != nil return defaltval
+func (c *ConfigContainer) DefaultFloat(key string, defaultval float64) float64 {
+	v, err := c.Float(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
+// String returns the string value for a given key,
+// numbers, booleans and datetimes are formatted, and arrays are joined by ";".
+func (c *ConfigContainer) String(key string) string {
+	return formatScalar(c.getData(key))
+}
+
+// DefaultString returns the string value for a given key.
+// if err != nil return defaltval
+func (c *ConfigContainer) DefaultString(key string, defaultval string) string {
+	if v := c.String(key); v != "" {
+		return v
+	}
+	return defaultval
+}
+
+// Strings returns the []string value for a given key,
+// the value may be an array or a string split by ";".
+func (c *ConfigContainer) Strings(key string) []string {
+	if arr, ok := c.getData(key).([]interface{}); ok {
+		s := make([]string, len(arr))
+		for i, v := range arr {
+			s[i] = formatScalar(v)
+		}
+		return s
+	}
+	v := c.String(key)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ";")
+}
+
+// DefaultStrings returns the []string value for a given key.
+// if err != nil return defaltval
+func (c *ConfigContainer) DefaultStrings(key string, defaultval []string) []string {
+	if v := c.Strings(key); v != nil {
+		return v
+	}
+	return defaultval
+}
+
+// GetSection returns map for the given table, with the values formatted as strings.
+func (c *ConfigContainer) GetSection(section string) (map[string]string, error) {
+	t, ok := c.getData(section).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("not exist section")
+	}
+	c.RLock()
+	defer c.RUnlock()
+	m := make(map[string]string, len(t))
+	for k, v := range t {
+		if _, ok := v.(map[string]interface{}); !ok {
+			m[k] = formatScalar(v)
+		}
+	}
+	return m, nil
+}
+
+// SaveConfigFile save the config into file.
+// The document is written as it was read, with only the values set by Set
+// rewritten, so the comments, the order and the ${VAR} and secret values are kept.
+func (c *ConfigContainer) SaveConfigFile(filename string) error {
+	c.RLock()
+	defer c.RUnlock()
+	return ioutil.WriteFile(filename, c.layout.save(c.changed), 0644)
+}
+
+// Set writes a new value for key, the missing tables of section::key are created.
+// The value of an existing integer, float, boolean or datetime key is parsed
+// as that type, so that it keeps its type when the file is saved.
+func (c *ConfigContainer) Set(key, val string) error {
+	c.Lock()
+	defer c.Unlock()
+	keys := strings.Split(key, "::")
+	t := c.data
+	for i, k := range keys[:len(keys)-1] {
+		keys[i] = lookupName(t, k)
+		sub, ok := t[keys[i]].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			t[keys[i]] = sub
+		}
+		t = sub
+	}
+	name := lookupName(t, keys[len(keys)-1])
+	v, err := parseAs(t[name], val)
+	if err != nil {
+		return fmt.Errorf("toml: %s: %v", key, err)
+	}
+	keys[len(keys)-1] = name
+	t[name] = v
+	c.changed[strings.Join(keys, "::")] = v
+	return nil
+}
+
+// parseAs parses val as the type of old, val is a string if old is nil or a string.
+func parseAs(old interface{}, val string) (interface{}, error) {
+	switch old.(type) {
+	case int64:
+		return strconv.ParseInt(strings.Replace(val, "_", "", -1), 10, 64)
+	case float64:
+		return strconv.ParseFloat(strings.Replace(val, "_", "", -1), 64)
+	case bool:
+		return strconv.ParseBool(val)
+	case time.Time:
+		if len(val) > 10 && val[10] == ' ' {
+			val = val[:10] + "T" + val[11:]
+		}
+		if t, ok := parseDatetime(val); ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("invalid datetime %q", val)
+	}
+	return val, nil
+}
+
+// DIY returns the raw value by a given key.
+func (c *ConfigContainer) DIY(key string) (interface{}, error) {
+	if v := c.getData(key); v != nil {
+		return v, nil
+	}
+	return nil, errors.New("not exist key")
+}
+
+// section::key or key, the names are matched case-insensitively when there is no exact match.
+func (c *ConfigContainer) getData(key string) interface{} {
+	if len(key) == 0 {
+		return nil
+	}
+	c.RLock()
+	defer c.RUnlock()
+	var cur interface{} = c.data
+	for _, k := range strings.Split(key, "::") {
+		t, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if cur = lookup(t, k); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+func lookup(t map[string]interface{}, k string) interface{} {
+	return t[lookupName(t, k)]
+}
+
+// lookupName returns the name of k in t, which is matched case-insensitively
+// when there is no exact match.
+func lookupName(t map[string]interface{}, k string) string {
+	if _, ok := t[k]; ok {
+		return k
+	}
+	for name := range t {
+		if strings.EqualFold(name, k) {
+			return name
+		}
+	}
+	return k
+}
+
+func formatScalar(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case []interface{}:
+		s := make([]string, len(x))
+		for i, e := range x {
+			s[i] = formatScalar(e)
+		}
+		return strings.Join(s, ";")
+	case map[string]interface{}:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func init() {
+	config.Register("toml", &Config{})
+}
@@ -0,0 +1,195 @@
+package toml
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/config"
+)
+
+const tomlContext = `
+# top level
+appname = "demo"
+port = 8_080
+pi = 3.14
+on = true
+born = 1979-05-27 07:32:00Z
+
+[db]
+host = '127.0.0.1'
+dsn = "root:${TOML_TEST_PASS:-secret}@tcp(localhost)/app"
+tags = ["a", "b",
+  "c", # trailing comment
+]
+limits = { max = 10, min = 1 }
+
+[db.replica]
+host = "10.0.0.2"
+
+[[server]]
+name = "alpha"
+
+[[server]]
+name = """
+beta\
+  gamma"""
+`
+
+func TestToml(t *testing.T) {
+	os.Unsetenv("TOML_TEST_PASS")
+	c, err := config.NewConfigData("toml", []byte(tomlContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.String("appname"); v != "demo" {
+		t.Errorf("appname: %q", v)
+	}
+	if v, err := c.Int("port"); err != nil || v != 8080 {
+		t.Errorf("port: %v %v", v, err)
+	}
+	if v, err := c.Float("pi"); err != nil || v != 3.14 {
+		t.Errorf("pi: %v %v", v, err)
+	}
+	if v, err := c.Bool("on"); err != nil || !v {
+		t.Errorf("on: %v %v", v, err)
+	}
+	born, _ := c.DIY("born")
+	if !born.(time.Time).Equal(time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)) {
+		t.Errorf("born: %v", born)
+	}
+	if v := c.String("db::dsn"); v != "root:secret@tcp(localhost)/app" {
+		t.Errorf("db::dsn: %q", v)
+	}
+	if v := c.Strings("db::tags"); !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Errorf("db::tags: %v", v)
+	}
+	if v := c.DefaultInt("db::limits::max", 0); v != 10 {
+		t.Errorf("db::limits::max: %v", v)
+	}
+	if v := c.String("DB::Replica::HOST"); v != "10.0.0.2" {
+		t.Errorf("db::replica::host: %q", v)
+	}
+	section, err := c.GetSection("db")
+	if err != nil || section["host"] != "127.0.0.1" || section["tags"] != "a;b;c" || len(section) != 3 {
+		t.Errorf("db section: %v %v", section, err)
+	}
+	servers, _ := c.DIY("server")
+	if s := servers.([]interface{}); len(s) != 2 || s[1].(map[string]interface{})["name"] != "betagamma" {
+		t.Errorf("server: %v", servers)
+	}
+}
+
+func TestTomlSave(t *testing.T) {
+	c, err := config.NewConfigData("toml", []byte(tomlContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("db::host", "db.local")
+	c.Set("DB::Replica::port", "5432")
+	c.Set("cache::redis::addr", ":6379")
+	f, err := ioutil.TempFile("", "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err = c.SaveConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := config.NewConfig("toml", f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"appname", "port", "pi", "born", "db::host", "db::dsn", "db::limits::min", "db::replica::host", "cache::redis::addr"} {
+		if c.String(key) != c2.String(key) {
+			t.Errorf("%s: %q != %q", key, c.String(key), c2.String(key))
+		}
+	}
+	if v, _ := c2.DIY("server"); len(v.([]interface{})) != 2 {
+		t.Errorf("server: %v", v)
+	}
+	if v := c2.String("db::replica::port"); v != "5432" {
+		t.Errorf("db::replica::port: %q", v)
+	}
+
+	// only the values set are rewritten
+	b, _ := ioutil.ReadFile(f.Name())
+	for _, s := range []string{
+		"# top level\nappname = \"demo\"\nport = 8_080\n",
+		"host = \"db.local\"\ndsn = \"root:${TOML_TEST_PASS:-secret}@tcp(localhost)/app\"\n",
+		"\"c\", # trailing comment\n",
+		"[db.replica]\nhost = \"10.0.0.2\"\nport = \"5432\"\n\n[[server]]",
+		"\n\n[cache.redis]\naddr = \":6379\"\n",
+	} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected %q in the saved file:\n%s", s, b)
+		}
+	}
+}
+
+func TestTomlSaveTypes(t *testing.T) {
+	c, err := config.NewConfigData("toml", []byte(tomlContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, val := range map[string]string{"port": "9000", "pi": "2.5", "on": "false", "born": "2000-01-02 03:04:05Z", "appname": "app"} {
+		if err = c.Set(key, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = c.Set("port", "ninety"); err == nil {
+		t.Error("expected an error for an invalid integer")
+	}
+	f, err := ioutil.TempFile("", "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err = c.SaveConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(f.Name())
+	for _, s := range []string{"port = 9000\n", "pi = 2.5\n", "on = false\n", "born = 2000-01-02T03:04:05Z\n", "appname = \"app\"\n"} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected %q in the saved file:\n%s", s, b)
+		}
+	}
+	d, err := Decode(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d["port"] != int64(9000) || d["pi"] != 2.5 || d["on"] != false || !d["born"].(time.Time).Equal(time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("the types are not kept: %v", d)
+	}
+}
+
+func TestTomlMixedArray(t *testing.T) {
+	d, err := Decode("a = [1, \"x\", 2.0, {b = 2}, [true]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int64(1), "x", 2.0, map[string]interface{}{"b": int64(2)}, []interface{}{true}}
+	if !reflect.DeepEqual(d["a"], want) {
+		t.Errorf("expected %v, got %v", want, d["a"])
+	}
+}
+
+func TestTomlErrors(t *testing.T) {
+	for _, doc := range []string{
+		"a = ",
+		"a = 1\na = 2",
+		"[t]\n[t]",
+		"a = \"open",
+		"a = 01",
+		"a = 1 b = 2",
+	} {
+		if _, err := Decode(doc); err == nil {
+			t.Errorf("expected an error for %q", doc)
+		}
+	}
+}
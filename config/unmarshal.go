@@ -0,0 +1,226 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a config value that can not be stored in the field of a struct.
+type FieldError struct {
+	Key string // full key, such as section::key
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+// UnmarshalErrors holds all the errors found by Unmarshal.
+type UnmarshalErrors []*FieldError
+
+func (e UnmarshalErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return "config: " + strings.Join(s, "; ")
+}
+
+var (
+	errRequired = errors.New("required value is missing")
+	durationT   = reflect.TypeOf(time.Duration(0))
+)
+
+// Unmarshal stores the values of the section into the struct pointed to by v.
+// section may be empty for the top level keys.
+//
+// The field tags are:
+//
+//	config:"name"           key name, the lower case field name by default, "-" to skip
+//	config:"name,required"  the key must be set
+//	default:"value"         value used when the key is not set
+//
+// Nested structs are read from sub keys, which are "section::name::key" for
+// json, xml and yaml, and "name.key" in the section for ini.
+// Slices accept lists or strings split by ";", and time.Duration accepts
+// strings such as "1m30s" or numbers of seconds.
+// All invalid and missing required values are returned together as UnmarshalErrors.
+func Unmarshal(c Configer, section string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Unmarshal needs a non-nil pointer to a struct")
+	}
+	var errs UnmarshalErrors
+	unmarshalStruct(c, section, nil, rv.Elem(), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func unmarshalStruct(c Configer, section string, path []string, rv reflect.Value, errs *UnmarshalErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		name, required := parseTag(sf)
+		if name == "-" {
+			continue
+		}
+		fpath := append(path[:len(path):len(path)], name)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationT {
+			unmarshalStruct(c, section, fpath, fv, errs)
+			continue
+		}
+		key := fullKey(c, section, fpath)
+		raw, ok := lookup(c, section, fpath)
+		if !ok {
+			def, hasDefault := sf.Tag.Lookup("default")
+			if !hasDefault {
+				if required {
+					*errs = append(*errs, &FieldError{Key: key, Err: errRequired})
+				}
+				continue
+			}
+			raw = def
+		}
+		if err := setValue(fv, raw); err != nil {
+			*errs = append(*errs, &FieldError{Key: key, Err: err})
+		}
+	}
+}
+
+func parseTag(sf reflect.StructField) (name string, required bool) {
+	tag := strings.Split(sf.Tag.Get("config"), ",")
+	name = tag[0]
+	if len(name) == 0 {
+		name = strings.ToLower(sf.Name)
+	}
+	for _, opt := range tag[1:] {
+		if opt == "required" {
+			required = true
+		}
+	}
+	return
+}
+
+// fullKey returns the key used in error messages.
+func fullKey(c Configer, section string, path []string) string {
+	var key string
+	if _, ok := c.(*IniConfigContainer); ok {
+		key = strings.Join(path, ".")
+	} else {
+		key = strings.Join(path, "::")
+	}
+	if len(section) > 0 {
+		key = section + "::" + key
+	}
+	return key
+}
+
+// lookup returns the raw value of the key, empty strings are treated as not set.
+func lookup(c Configer, section string, path []string) (interface{}, bool) {
+	if ini, ok := c.(*IniConfigContainer); ok {
+		if len(section) == 0 {
+			section = DefaultSection
+		}
+		ini.RLock()
+		defer ini.RUnlock()
+		v, ok := ini.data[strings.ToLower(section)][strings.ToLower(strings.Join(path, "."))]
+		return v, ok && len(v) > 0
+	}
+	v, err := c.DIY(fullKey(c, section, path))
+	if err != nil || v == nil {
+		return nil, false
+	}
+	if s, ok := v.(string); ok && len(s) == 0 {
+		return nil, false
+	}
+	return v, true
+}
+
+func setValue(fv reflect.Value, raw interface{}) error {
+	if fv.Type() == durationT {
+		d, err := parseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(fmt.Sprint(raw))
+	case reflect.Bool:
+		b, err := ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(numberString(raw), 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(numberString(raw), 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(numberString(raw), fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		var items []interface{}
+		switch r := raw.(type) {
+		case []interface{}:
+			items = r
+		case []string:
+			for _, s := range r {
+				items = append(items, s)
+			}
+		default:
+			for _, s := range strings.Split(fmt.Sprint(raw), ";") {
+				items = append(items, strings.TrimSpace(s))
+			}
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d: %v", i, err)
+			}
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// numberString formats json float64 values without exponent.
+func numberString(raw interface{}) string {
+	if f, ok := raw.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strings.TrimSpace(fmt.Sprint(raw))
+}
+
+func parseDuration(raw interface{}) (time.Duration, error) {
+	s := numberString(raw)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
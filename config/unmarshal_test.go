@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	Name    string        `config:"name,required"`
+	Port    int           `default:"8080"`
+	Debug   bool          `config:"debug"`
+	Timeout time.Duration `default:"30s"`
+	Hosts   []string
+	Ignored string `config:"-"`
+	TLS     struct {
+		Enable bool
+		Cert   string `default:"cert.pem"`
+	}
+}
+
+func TestUnmarshalIni(t *testing.T) {
+	c, err := NewConfigData("ini", []byte(`
+[server]
+name = demo
+debug = on
+timeout = 90
+hosts = a.com;b.com
+tls.enable = true
+ignored = x
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s testServer
+	if err = Unmarshal(c, "server", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "demo" || s.Port != 8080 || !s.Debug || s.Timeout != 90*time.Second || s.Ignored != "" {
+		t.Fatalf("unexpected %+v", s)
+	}
+	if !reflect.DeepEqual(s.Hosts, []string{"a.com", "b.com"}) {
+		t.Fatalf("hosts: %v", s.Hosts)
+	}
+	if !s.TLS.Enable || s.TLS.Cert != "cert.pem" {
+		t.Fatalf("tls: %+v", s.TLS)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	c, err := NewConfigData("json", []byte(`{
+	"server": {
+		"name": "demo",
+		"port": 9000,
+		"timeout": "1m",
+		"hosts": ["a.com", "b.com"],
+		"tls": {"enable": true, "cert": "a.pem"}
+	}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s testServer
+	if err = Unmarshal(c, "server", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 9000 || s.Timeout != time.Minute || len(s.Hosts) != 2 || s.TLS.Cert != "a.pem" {
+		t.Fatalf("unexpected %+v", s)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	c, err := NewConfigData("ini", []byte(`
+[server]
+port = http
+timeout = soon
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s testServer
+	err = Unmarshal(c, "server", &s)
+	errs, ok := err.(UnmarshalErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	for _, key := range []string{"server::name", "server::port", "server::timeout"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("missing error for %s: %v", key, err)
+		}
+	}
+	if s.Port != 0 {
+		t.Errorf("invalid value must not fall back to the default, got %d", s.Port)
+	}
+}
@@ -71,6 +71,10 @@ func (xc *Config) Parse(filename string) (config.Configer, error) {
 	}
 
 	x.data = d["config"].(map[string]interface{})
+	x.raw = config.CloneValue(x.data).(map[string]interface{})
+	if _, err = config.ResolveValue(x.data); err != nil {
+		return nil, err
+	}
 	return x, nil
 }
 
@@ -88,12 +92,13 @@ func (xc *Config) ParseData(data []byte) (config.Configer, error) {
 // ConfigContainer A Config represents the xml configuration.
 type ConfigContainer struct {
 	data map[string]interface{}
+	raw  map[string]interface{} // the values as read, which are saved
 	sync.Mutex
 }
 
 // Bool returns the boolean value for a given key.
 func (c *ConfigContainer) Bool(key string) (bool, error) {
-	if v, ok := c.data[key]; ok {
+	if v := c.getData(key); v != nil {
 		return config.ParseBool(v)
 	}
 	return false, fmt.Errorf("not exist key: %q", key)
@@ -111,7 +116,7 @@ func (c *ConfigContainer) DefaultBool(key string, defaultval bool) bool {
 
 // Int returns the integer value for a given key.
 func (c *ConfigContainer) Int(key string) (int, error) {
-	return strconv.Atoi(c.data[key].(string))
+	return strconv.Atoi(c.String(key))
 }
 
 // DefaultInt returns the integer value for a given key.
@@ -126,7 +131,7 @@ func (c *ConfigContainer) DefaultInt(key string, defaultval int) int {
 
 // Int64 returns the int64 value for a given key.
 func (c *ConfigContainer) Int64(key string) (int64, error) {
-	return strconv.ParseInt(c.data[key].(string), 10, 64)
+	return strconv.ParseInt(c.String(key), 10, 64)
 }
 
 // DefaultInt64 returns the int64 value for a given key.
@@ -142,7 +147,7 @@ func (c *ConfigContainer) DefaultInt64(key string, defaultval int64) int64 {
 
 // Float returns the float value for a given key.
 func (c *ConfigContainer) Float(key string) (float64, error) {
-	return strconv.ParseFloat(c.data[key].(string), 64)
+	return strconv.ParseFloat(c.String(key), 64)
 }
 
 // DefaultFloat returns the float64 value for a given key.
@@ -157,7 +162,7 @@ func (c *ConfigContainer) DefaultFloat(key string, defaultval float64) float64 {
 
 // String returns the string value for a given key.
 func (c *ConfigContainer) String(key string) string {
-	if v, ok := c.data[key].(string); ok {
+	if v, ok := c.getData(key).(string); ok {
 		return v
 	}
 	return ""
@@ -200,7 +205,8 @@ func (c *ConfigContainer) GetSection(section string) (map[string]string, error)
 	return nil, errors.New("not exist setction")
 }
 
-// SaveConfigFile save the config into file
+// SaveConfigFile save the config into file,
+// the ${VAR} and secret values are written as they were read.
 func (c *ConfigContainer) SaveConfigFile(filename string) (err error) {
 	// Write configuration file by filename.
 	f, err := os.Create(filename)
@@ -208,7 +214,9 @@ func (c *ConfigContainer) SaveConfigFile(filename string) (err error) {
 		return err
 	}
 	defer f.Close()
-	b, err := xml.MarshalIndent(c.data, "  ", "    ")
+	c.Lock()
+	b, err := xml.MarshalIndent(c.raw, "  ", "    ")
+	c.Unlock()
 	if err != nil {
 		return err
 	}
@@ -221,17 +229,23 @@ func (c *ConfigContainer) Set(key, val string) error {
 	c.Lock()
 	defer c.Unlock()
 	c.data[key] = val
+	c.raw[key] = val
 	return nil
 }
 
 // DIY returns the raw value by a given key.
 func (c *ConfigContainer) DIY(key string) (v interface{}, err error) {
-	if v, ok := c.data[key]; ok {
+	if v := c.getData(key); v != nil {
 		return v, nil
 	}
 	return nil, errors.New("not exist key")
 }
 
+// section::key or key
+func (c *ConfigContainer) getData(key string) interface{} {
+	return config.LookupKey(c.data, key)
+}
+
 func init() {
 	config.Register("xml", &Config{})
 }
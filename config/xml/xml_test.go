@@ -17,6 +17,7 @@ package xml
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/henrylee2cn/lessgo/config"
 )
@@ -86,3 +87,46 @@ func TestXML(t *testing.T) {
 		t.Fatal("get emtpy strings error")
 	}
 }
+
+func TestXMLUnmarshal(t *testing.T) {
+	xmlconf, err := config.NewConfigData("xml", []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<config>
+<server>
+<name>demo</name>
+<port>9000</port>
+<timeout>90</timeout>
+<hosts>a.com;b.com</hosts>
+<tls><enable>true</enable></tls>
+</server>
+</config>
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		Name    string        `config:"name,required"`
+		Port    int           `default:"8080"`
+		Timeout time.Duration `default:"30s"`
+		Hosts   []string
+		TLS     struct {
+			Enable bool
+			Cert   string `default:"cert.pem"`
+		}
+	}
+	if err = config.Unmarshal(xmlconf, "server", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "demo" || s.Port != 9000 || s.Timeout != 90*time.Second || len(s.Hosts) != 2 || s.Hosts[1] != "b.com" {
+		t.Fatalf("unexpected %+v", s)
+	}
+	if !s.TLS.Enable || s.TLS.Cert != "cert.pem" {
+		t.Fatalf("tls: %+v", s.TLS)
+	}
+
+	var missing struct {
+		Name string `config:"name,required"`
+	}
+	if err = config.Unmarshal(xmlconf, "client", &missing); err == nil {
+		t.Fatal("expected the error of the missing client::name")
+	}
+}
@@ -56,8 +56,13 @@ func (yaml *Config) Parse(filename string) (y config.Configer, err error) {
 	if err != nil {
 		return
 	}
+	raw, _ := config.CloneValue(cnf).(map[string]interface{})
+	if _, err = config.ResolveValue(cnf); err != nil {
+		return
+	}
 	y = &ConfigContainer{
 		data: cnf,
+		raw:  raw,
 	}
 	return
 }
@@ -117,12 +122,13 @@ func ReadYmlReader(path string) (cnf map[string]interface{}, err error) {
 // ConfigContainer A Config represents the yaml configuration.
 type ConfigContainer struct {
 	data map[string]interface{}
+	raw  map[string]interface{} // the values as read, which are saved
 	sync.Mutex
 }
 
 // Bool returns the boolean value for a given key.
 func (c *ConfigContainer) Bool(key string) (bool, error) {
-	if v, ok := c.data[key]; ok {
+	if v := c.getData(key); v != nil {
 		return config.ParseBool(v)
 	}
 	return false, fmt.Errorf("not exist key: %q", key)
@@ -140,7 +146,7 @@ func (c *ConfigContainer) DefaultBool(key string, defaultval bool) bool {
 
 // Int returns the integer value for a given key.
 func (c *ConfigContainer) Int(key string) (int, error) {
-	if v, ok := c.data[key].(int64); ok {
+	if v, ok := c.getData(key).(int64); ok {
 		return int(v), nil
 	}
 	return 0, errors.New("not int value")
@@ -158,7 +164,7 @@ func (c *ConfigContainer) DefaultInt(key string, defaultval int) int {
 
 // Int64 returns the int64 value for a given key.
 func (c *ConfigContainer) Int64(key string) (int64, error) {
-	if v, ok := c.data[key].(int64); ok {
+	if v, ok := c.getData(key).(int64); ok {
 		return v, nil
 	}
 	return 0, errors.New("not bool value")
@@ -176,7 +182,7 @@ func (c *ConfigContainer) DefaultInt64(key string, defaultval int64) int64 {
 
 // Float returns the float value for a given key.
 func (c *ConfigContainer) Float(key string) (float64, error) {
-	if v, ok := c.data[key].(float64); ok {
+	if v, ok := c.getData(key).(float64); ok {
 		return v, nil
 	}
 	return 0.0, errors.New("not float64 value")
@@ -194,7 +200,7 @@ func (c *ConfigContainer) DefaultFloat(key string, defaultval float64) float64 {
 
 // String returns the string value for a given key.
 func (c *ConfigContainer) String(key string) string {
-	if v, ok := c.data[key].(string); ok {
+	if v, ok := c.getData(key).(string); ok {
 		return v
 	}
 	return ""
@@ -238,7 +244,8 @@ func (c *ConfigContainer) GetSection(section string) (map[string]string, error)
 	return nil, errors.New("not exist setction")
 }
 
-// SaveConfigFile save the config into file
+// SaveConfigFile save the config into file,
+// the ${VAR} and secret values are written as they were read.
 func (c *ConfigContainer) SaveConfigFile(filename string) (err error) {
 	// Write configuration file by filename.
 	f, err := os.Create(filename)
@@ -246,7 +253,9 @@ func (c *ConfigContainer) SaveConfigFile(filename string) (err error) {
 		return err
 	}
 	defer f.Close()
-	err = goyaml2.Write(f, c.data)
+	c.Lock()
+	defer c.Unlock()
+	err = goyaml2.Write(f, c.raw)
 	return err
 }
 
@@ -255,17 +264,23 @@ func (c *ConfigContainer) Set(key, val string) error {
 	c.Lock()
 	defer c.Unlock()
 	c.data[key] = val
+	c.raw[key] = val
 	return nil
 }
 
 // DIY returns the raw value by a given key.
 func (c *ConfigContainer) DIY(key string) (v interface{}, err error) {
-	if v, ok := c.data[key]; ok {
+	if v := c.getData(key); v != nil {
 		return v, nil
 	}
 	return nil, errors.New("not exist key")
 }
 
+// section::key or key
+func (c *ConfigContainer) getData(key string) interface{} {
+	return config.LookupKey(c.data, key)
+}
+
 func init() {
 	config.Register("yaml", &Config{})
 }
@@ -17,6 +17,7 @@ package yaml
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/henrylee2cn/lessgo/config"
 )
@@ -84,3 +85,64 @@ func TestYaml(t *testing.T) {
 		t.Fatal("get emtpy strings error")
 	}
 }
+
+func TestYamlSection(t *testing.T) {
+	yamlconf, err := config.NewConfigData("yaml", []byte(`
+listen:
+  address: "0.0.0.0:80"
+  readtimeout: 30
+system:
+  debug: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := yamlconf.String("listen::address"); v != "0.0.0.0:80" {
+		t.Fatalf("listen::address: got %q", v)
+	}
+	if v, err := yamlconf.Int64("listen::readtimeout"); err != nil || v != 30 {
+		t.Fatalf("listen::readtimeout: got %v, %v", v, err)
+	}
+	if v, err := yamlconf.Bool("system::debug"); err != nil || v {
+		t.Fatalf("system::debug: got %v, %v", v, err)
+	}
+	if v := yamlconf.String("listen::missing"); v != "" {
+		t.Fatalf("listen::missing: got %q", v)
+	}
+}
+
+func TestYamlUnmarshal(t *testing.T) {
+	yamlconf, err := config.NewConfigData("yaml", []byte(`
+server:
+  name: demo
+  port: 9000
+  timeout: 1m
+  hosts:
+    - a.com
+    - b.com
+  tls:
+    enable: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		Name    string        `config:"name,required"`
+		Port    int           `default:"8080"`
+		Timeout time.Duration `default:"30s"`
+		Hosts   []string
+		TLS     struct {
+			Enable bool
+			Cert   string `default:"cert.pem"`
+		}
+	}
+	if err = config.Unmarshal(yamlconf, "server", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "demo" || s.Port != 9000 || s.Timeout != time.Minute || len(s.Hosts) != 2 || s.Hosts[1] != "b.com" {
+		t.Fatalf("unexpected %+v", s)
+	}
+	if !s.TLS.Enable || s.TLS.Cert != "cert.pem" {
+		t.Fatalf("tls: %+v", s.TLS)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"html/template"
 	"io"
 	"io/ioutil"
 	"mime"
@@ -16,10 +17,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/henrylee2cn/lessgo/i18n"
 	"github.com/henrylee2cn/lessgo/logs"
 	"github.com/henrylee2cn/lessgo/markdown"
 	"github.com/henrylee2cn/lessgo/session"
@@ -41,6 +44,7 @@ type (
 		cruSession     session.Store
 		socket         *websocket.Conn
 		failureHandler FailureHandlerFunc
+		locale         string
 	}
 
 	store map[string]interface{}
@@ -63,6 +67,9 @@ var (
 	// 文件上传默认内存缓存大小，默认值是64MB。
 	MaxMemory int64 = 64 * MB
 
+	// 加密cookie的编解码器，密钥环来自Config.Security.CookieKeys
+	secureCookie = new(session.SecureCookie)
+
 	reverseProxys = &ReverseProxys{
 		list: map[string]*httputil.ReverseProxy{},
 	}
@@ -392,6 +399,48 @@ func (c *Context) AddCookieParam(cookie *http.Cookie) {
 	c.request.AddCookie(cookie)
 }
 
+// SetSecureCookie sets a cookie encrypted and authenticated with AES-GCM by the key
+// ring of Config.Security.CookieKeys. maxAge is the cookie max age in seconds (0 for a
+// session cookie), the optional others are the path (default "/") and the domain.
+// It returns a *session.CookieSizeError when the encrypted cookie is too large.
+func (c *Context) SetSecureCookie(name, value string, maxAge int, others ...string) error {
+	str, err := secureCookie.Encode(name, []byte(value))
+	if err != nil {
+		return err
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    str,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   c.IsTLS(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if len(others) > 0 && len(others[0]) > 0 {
+		cookie.Path = others[0]
+	}
+	if len(others) > 1 {
+		cookie.Domain = others[1]
+	}
+	c.AddCookie(cookie)
+	return nil
+}
+
+// GetSecureCookie returns the value of the cookie set by SetSecureCookie, the value
+// older than Config.Security.CookieMaxAge seconds is expired.
+func (c *Context) GetSecureCookie(name string) (string, error) {
+	cookie, err := c.request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := secureCookie.Decode(name, cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
 // Bind binds the request body into provided type `container`. The default binder
 // does it based on Content-Type header.
 func (c *Context) Bind(container interface{}) error {
@@ -470,6 +519,10 @@ func (c *Context) Render(code int, name string, data interface{}) error {
 	buf := new(bytes.Buffer)
 	var err error
 	if err = app.renderer.Render(buf, name, data, c); err != nil {
+		// 调试模式下在浏览器中显示出错的模板代码
+		if app.Debug() {
+			c.HTML(500, templateErrorPage(name, err))
+		}
 		return err
 	}
 	c.response.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
@@ -627,12 +680,16 @@ func (c *Context) XMLBlob(code int, b []byte) error {
 func (c *Context) File(file string) error {
 	if app.CanMemoryCache() {
 		b, fi, exist := app.memoryCache.GetCacheFile(file)
+		if exist && fi.IsDir() {
+			b, fi, exist = app.memoryCache.GetCacheFile(path.Join(file, indexPage))
+		}
 		if !exist {
 			return c.Failure(404, nil)
 		}
 		return c.ServeContent(bytes.NewReader(b), fi.Name(), fi.ModTime())
 	}
-	f, err := os.Open(file)
+	fs := app.FileSystem()
+	f, err := fs.Open(file)
 	if err != nil {
 		return c.Failure(404, nil)
 	}
@@ -640,34 +697,66 @@ func (c *Context) File(file string) error {
 
 	fi, _ := f.Stat()
 	if fi.IsDir() {
-		file = filepath.Join(file, indexPage)
-		f, err = os.Open(file)
+		file = path.Join(file, indexPage)
+		f, err = fs.Open(file)
 		if err != nil {
 			return c.Failure(404, nil)
 		}
+		defer f.Close()
 		fi, _ = f.Stat()
 	}
 	return c.ServeContent(f, fi.Name(), fi.ModTime())
 }
 
-// Markdown parses markdown file and generates html in github style
+// Markdown parses markdown file and generates html in github style.
+// The YAML front matter of the file (see markdown.SplitFrontMatter) is passed to the
+// template named by its "layout" (a path in TPL_DIR, BIZ_VIEW_DIR or SYS_VIEW_DIR,
+// ignored when opts.Sanitize is set, as the file is user-contributed), along with "meta" (the front matter), "content"
+// (the rendered html, use `{{ content|safe }}` in pongo2) and "css" (put it in
+// `<style nonce="{{ csp_nonce }}">` under the default CSP); without a layout
+// the github style page is sent, its <style> has the CSP nonce. The rendered html is kept in MemoryCache until the file changes.
 func (c *Context) Markdown(file string, hasCatalog ...bool) error {
 	var catalog bool
 	if len(hasCatalog) > 0 {
 		catalog = hasCatalog[0]
 	}
+	return c.MarkdownOptions(file, markdown.GithubOptions{
+		Catalog:   catalog,
+		Highlight: markdown.Highlight,
+	})
+}
+
+// MarkdownOptions is like Markdown but with the options, e.g. sanitize user-contributed content
+// with a policy created once (the cached html is keyed by its fingerprint):
+//
+//	c.MarkdownOptions(file, markdown.GithubOptions{Highlight: markdown.Highlight, Sanitize: lessgo.SanitizePolicy})
+func (c *Context) MarkdownOptions(file string, opts markdown.GithubOptions) error {
 	if app.CanMemoryCache() {
-		b, fi, exist := app.memoryCache.GetCacheFile(file)
+		// 不同选项生成的html分别缓存
+		kind := fmt.Sprintf("markdown:%t:%p", opts.Catalog, opts.Highlight)
+		if opts.Sanitize != nil {
+			kind += ":" + opts.Sanitize.Fingerprint()
+		}
+		build := func(b []byte) (interface{}, error) {
+			return parseMarkdown(b, opts)
+		}
+		v, fi, exist, err := app.memoryCache.GetDerived(file, kind, build)
+		if exist && fi.IsDir() {
+			v, fi, exist, err = app.memoryCache.GetDerived(path.Join(file, indexPage), kind, build)
+		}
 		if !exist {
 			return c.Failure(404, nil)
 		}
+		if err != nil {
+			return c.Failure(500, err)
+		}
 		if c.isModified(fi.Name(), fi.ModTime()) {
-			c.WriteHeader(http.StatusOK)
-			return markdown.GithubMarkdown(b, c.response, catalog)
+			return c.renderMarkdown(v.(*markdownPage))
 		}
 		return c.NoContent(http.StatusNotModified)
 	}
-	f, err := os.Open(file)
+	fs := app.FileSystem()
+	f, err := fs.Open(file)
 	if err != nil {
 		return c.Failure(404, nil)
 	}
@@ -675,11 +764,12 @@ func (c *Context) Markdown(file string, hasCatalog ...bool) error {
 
 	fi, _ := f.Stat()
 	if fi.IsDir() {
-		file = filepath.Join(file, indexPage)
-		f, err = os.Open(file)
+		file = path.Join(file, indexPage)
+		f, err = fs.Open(file)
 		if err != nil {
 			return c.Failure(404, nil)
 		}
+		defer f.Close()
 		fi, _ = f.Stat()
 	}
 	if c.isModified(fi.Name(), fi.ModTime()) {
@@ -687,12 +777,77 @@ func (c *Context) Markdown(file string, hasCatalog ...bool) error {
 		if err != nil {
 			return c.Failure(404, nil)
 		}
-		c.WriteHeader(http.StatusOK)
-		return markdown.GithubMarkdown(buf, c.response, catalog)
+		page, err := parseMarkdown(buf, opts)
+		if err != nil {
+			return c.Failure(500, err)
+		}
+		return c.renderMarkdown(page)
 	}
 	return c.NoContent(http.StatusNotModified)
 }
 
+// 由markdown文件生成的页面
+type markdownPage struct {
+	meta   map[string]interface{} // YAML front matter
+	layout string                 // 布局模板，为空时输出github风格的页面
+	body   []byte                 // 渲染后的html
+}
+
+func parseMarkdown(b []byte, opts markdown.GithubOptions) (*markdownPage, error) {
+	meta, body, err := markdown.SplitFrontMatter(b)
+	if err != nil {
+		return nil, err
+	}
+	page := &markdownPage{
+		meta: meta,
+		body: markdown.GithubBody(body, opts),
+	}
+	// 用户提交的内容不可指定模板
+	if layout, _ := meta["layout"].(string); len(layout) > 0 && opts.Sanitize == nil {
+		if page.layout, err = markdownLayout(layout); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// 校验front matter中的布局模板，须为模板目录下的相对路径，且不含".."
+func markdownLayout(name string) (string, error) {
+	slashed := filepath.ToSlash(name)
+	if path.IsAbs(slashed) || filepath.IsAbs(name) || len(filepath.VolumeName(name)) > 0 {
+		return "", fmt.Errorf("markdown layout %q must be a relative path", name)
+	}
+	for _, elem := range strings.Split(slashed, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("markdown layout %q must not contain \"..\"", name)
+		}
+	}
+	layout := path.Clean(slashed)
+	for _, dir := range templateDirs {
+		if strings.HasPrefix(layout, dir+"/") {
+			return layout, nil
+		}
+	}
+	return "", fmt.Errorf("markdown layout %q is not in the template directories %v", name, templateDirs)
+}
+
+func (c *Context) renderMarkdown(page *markdownPage) error {
+	layout := page.layout
+	if len(layout) == 0 {
+		c.response.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
+		c.WriteHeader(http.StatusOK)
+		return markdown.GithubPageNonce(c.response, page.body, c.CSPNonce())
+	}
+	data := make(map[string]interface{}, len(page.meta)+3)
+	for k, v := range page.meta {
+		data[k] = v
+	}
+	data["meta"] = page.meta
+	data["content"] = template.HTML(page.body)
+	data["css"] = template.CSS(markdown.GithubCSS)
+	return c.Render(http.StatusOK, layout, data)
+}
+
 // Attachment sends a response from `io.ReaderSeeker` as attachment, prompting
 // client to save the file.
 func (c *Context) Attachment(r io.ReadSeeker, name string) error {
@@ -812,6 +967,134 @@ func (c *Context) GetSession(key interface{}) interface{} {
 	return c.cruSession.Get(key)
 }
 
+// GetSessionString gets a string from session, def[0] (or "") if it is absent.
+func (c *Context) GetSessionString(key interface{}, def ...string) string {
+	switch v := c.GetSession(key).(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return ""
+}
+
+// GetSessionInt gets an int from session, def[0] (or 0) if it is absent or not a number.
+func (c *Context) GetSessionInt(key interface{}, def ...int) int {
+	if i, ok := sessionInt64(c.GetSession(key)); ok {
+		return int(i)
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return 0
+}
+
+// GetSessionInt64 gets an int64 from session, def[0] (or 0) if it is absent or not a number.
+func (c *Context) GetSessionInt64(key interface{}, def ...int64) int64 {
+	if i, ok := sessionInt64(c.GetSession(key)); ok {
+		return i
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return 0
+}
+
+// GetSessionFloat64 gets a float64 from session, def[0] (or 0) if it is absent or not a number.
+func (c *Context) GetSessionFloat64(key interface{}, def ...float64) float64 {
+	var (
+		f   float64
+		err error
+	)
+	switch v := c.GetSession(key).(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case json.Number:
+		f, err = v.Float64()
+	case string:
+		f, err = strconv.ParseFloat(v, 64)
+	default:
+		if i, ok := sessionInt64(v); ok {
+			return float64(i)
+		}
+		err = strconv.ErrSyntax
+	}
+	if err == nil {
+		return f
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return 0
+}
+
+// GetSessionBool gets a bool from session, def[0] (or false) if it is absent or not a bool.
+func (c *Context) GetSessionBool(key interface{}, def ...bool) bool {
+	switch v := c.GetSession(key).(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return false
+}
+
+// sessionInt64 converts a session value to int64, the numbers decoded by
+// the json or msgpack serializer are int64, float64 or json.Number.
+func sessionInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float32:
+		if n == float32(int64(n)) {
+			return int64(n), true
+		}
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n), true
+		}
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // DelSession removes value from session.
 func (c *Context) DelSession(key interface{}) {
 	if c.cruSession == nil {
@@ -827,7 +1110,34 @@ func (c *Context) SessionRegenerateID() {
 		return
 	}
 	c.cruSession.SessionRelease(c.response)
-	c.cruSession = app.sessions.SessionRegenerateID(c.response, c.request)
+	c.cruSession = app.sessions.SessionRegenerateStore(c.response, c.request, c.cruSession)
+}
+
+// SetSessionUser binds this session to the user key (e.g. the user id after login),
+// so that Sessions().RevokeUser(user) signs the user out on every device.
+// It is a privilege change, so the session id is rotated.
+func (c *Context) SetSessionUser(user string) {
+	if c.cruSession == nil {
+		return
+	}
+	app.sessions.SetUser(c.cruSession, user)
+	c.SessionPrivilegeChanged()
+}
+
+// SessionPrivilegeChanged marks a privilege change of this session (login, sudo...),
+// the session id is rotated at once against session fixation and the values are kept,
+// except the CSRF token, which is replaced with a new one.
+// It must be called before the response is written.
+func (c *Context) SessionPrivilegeChanged() {
+	if c.cruSession == nil {
+		return
+	}
+	if c.response.committed {
+		Log.Warn("session id can not be rotated after the response is committed")
+		return
+	}
+	c.SessionRegenerateID()
+	c.rotateCSRFToken()
 }
 
 // DestroySession cleans session data and session cookie.
@@ -840,6 +1150,58 @@ func (c *Context) DestroySession() {
 	app.sessions.SessionDestroy(c.response, c.request)
 }
 
+// 当前请求的语言，依次由URL参数、cookie、session及请求头Accept-Language
+// 匹配I18n中已有的语言，均无法匹配时为默认语言
+func (c *Context) Locale() string {
+	if len(c.locale) == 0 {
+		c.locale = c.resolveLocale()
+	}
+	return c.locale
+}
+
+// 设置当前请求的语言，开启session时同时保存到session
+func (c *Context) SetLocale(locale string) {
+	c.locale = locale
+	if key := CurrentConfig().I18n.SessionKey; len(key) > 0 {
+		c.SetSession(key, locale)
+	}
+}
+
+// 按当前请求的语言翻译消息，参数的用法详见i18n.Catalog.Translate
+func (c *Context) T(key string, args ...interface{}) string {
+	return I18n.Translate(c.Locale(), key, args...)
+}
+
+func (c *Context) resolveLocale() string {
+	if c.request == nil {
+		return I18n.Default()
+	}
+	conf := CurrentConfig().I18n
+	var preferred []string
+	if len(conf.QueryParam) > 0 {
+		preferred = append(preferred, c.QueryParam(conf.QueryParam))
+	}
+	if len(conf.CookieName) > 0 {
+		if cookie := c.CookieParam(conf.CookieName); cookie != nil {
+			preferred = append(preferred, cookie.Value)
+		}
+	}
+	if len(conf.SessionKey) > 0 {
+		if locale, ok := c.GetSession(conf.SessionKey).(string); ok {
+			preferred = append(preferred, locale)
+		}
+	}
+	for _, locale := range preferred {
+		if locale = I18n.Match(locale); len(locale) > 0 {
+			return locale
+		}
+	}
+	if locale := I18n.Match(i18n.ParseAcceptLanguage(c.HeaderParam(HeaderAcceptLanguage))...); len(locale) > 0 {
+		return locale
+	}
+	return I18n.Default()
+}
+
 // 获取websocket实例
 func (c *Context) Ws() *websocket.Conn {
 	return c.socket
@@ -949,6 +1311,7 @@ func (c *Context) free() {
 	c.freeSession()
 	c.socket = nil
 	c.store = nil
+	c.locale = ""
 	c.realRemoteAddr = ""
 	c.query = nil
 	c.form = nil
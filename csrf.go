@@ -0,0 +1,188 @@
+package lessgo
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/henrylee2cn/lessgo/pongo2"
+)
+
+const (
+	// session中保存CSRF令牌的键
+	csrfSessionKey = "_csrf"
+	// 当前请求的CSRF令牌在Context中的键
+	csrfContextKey = "_csrf"
+	// CSRF令牌的随机字节数
+	csrfTokenSize = 32
+
+	// 模板中CSRF令牌的变量名，如<meta name="csrf-token" content="{{ csrf_token }}">
+	CSRFTokenTplVar = "csrf_token"
+	// 模板中CSRF隐藏表单字段的变量名，如<form method="post">{{ csrf_field }}...</form>
+	CSRFFieldTplVar = "csrf_field"
+)
+
+// CSRF令牌缺失或不匹配
+var ErrCSRFToken = errors.New("invalid or missing CSRF token")
+
+type (
+	// CSRF防护中间件的配置
+	CSRFConfig struct {
+		FieldName    string   `json:"fieldName"`    // 表单字段名
+		CookieName   string   `json:"cookieName"`   // 未开启session时双重提交cookie的名称
+		CookieMaxAge int      `json:"cookieMaxAge"` // 双重提交cookie的有效期(秒)，0表示会话cookie
+		Exempt       []string `json:"exempt"`       // 免检路径，以"*"结尾时匹配前缀，如"/api/webhook/*"
+	}
+
+	// 当前请求的CSRF令牌及表单字段名
+	csrfValue struct {
+		token string
+		field string
+	}
+)
+
+var CSRF = ApiMiddleware{
+	Name: "CSRF防护",
+	Desc: "为每个session签发CSRF令牌(未开启session时使用双重提交cookie)，校验非安全方法请求头X-CSRF-Token或表单字段中的令牌",
+	Params: []Param{
+		{Name: HeaderXCSRFToken, In: "header", Model: "", Desc: "CSRF令牌，POST等非安全方法须提供，亦可使用表单字段(默认_csrf)"},
+	},
+	Config: CSRFConfig{
+		FieldName:  "_csrf",
+		CookieName: "_csrf",
+	},
+	Middleware: func(confObject interface{}) MiddlewareFunc {
+		conf := confObject.(CSRFConfig)
+		if len(conf.FieldName) == 0 {
+			conf.FieldName = "_csrf"
+		}
+		if len(conf.CookieName) == 0 {
+			conf.CookieName = "_csrf"
+		}
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) error {
+				token := c.issueCSRFToken(&conf)
+				c.Set(csrfContextKey, csrfValue{token: token, field: conf.FieldName})
+				if csrfSafeMethod(c.request.Method) || conf.exempt(c.request.URL.Path) {
+					return next(c)
+				}
+				sent := c.HeaderParam(HeaderXCSRFToken)
+				if len(sent) == 0 {
+					sent = c.FormParam(conf.FieldName)
+				}
+				if len(sent) == 0 || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+					return c.Failure(http.StatusForbidden, ErrCSRFToken)
+				}
+				return next(c)
+			}
+		}
+	},
+}.Reg()
+
+// 当前请求的CSRF令牌，未使用CSRF中间件时为空
+func (c *Context) CSRFToken() string {
+	v, _ := c.Get(csrfContextKey).(csrfValue)
+	return v.token
+}
+
+// 含CSRF令牌的隐藏表单字段，未使用CSRF中间件时为空
+func (c *Context) CSRFField() string {
+	v, ok := c.Get(csrfContextKey).(csrfValue)
+	if !ok {
+		return ""
+	}
+	return `<input type="hidden" name="` + html.EscapeString(v.field) + `" value="` + v.token + `">`
+}
+
+// 权限变更(如登录)时更换session中的CSRF令牌，使变更前泄露的令牌失效；
+// 当前请求使用CSRF中间件时一并更新其令牌，以便随后渲染的页面使用新令牌
+func (c *Context) rotateCSRFToken() {
+	if c.cruSession == nil {
+		return
+	}
+	v, ok := c.Get(csrfContextKey).(csrfValue)
+	if !ok {
+		c.cruSession.Delete(csrfSessionKey)
+		return
+	}
+	v.token = newCSRFToken()
+	c.SetSession(csrfSessionKey, v.token)
+	c.Set(csrfContextKey, v)
+}
+
+// 读取或签发CSRF令牌，开启session时保存于session，否则保存于双重提交cookie
+func (c *Context) issueCSRFToken(conf *CSRFConfig) string {
+	if c.cruSession != nil {
+		token := c.GetSessionString(csrfSessionKey)
+		if len(token) == 0 {
+			token = newCSRFToken()
+			c.SetSession(csrfSessionKey, token)
+		}
+		return token
+	}
+	if cookie := c.CookieParam(conf.CookieName); cookie != nil && validCSRFToken(cookie.Value) {
+		return cookie.Value
+	}
+	token := newCSRFToken()
+	// 须允许js读取，以便通过请求头提交
+	c.AddCookie(&http.Cookie{
+		Name:     conf.CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   conf.CookieMaxAge,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// 是否为免检路径
+func (conf *CSRFConfig) exempt(p string) bool {
+	for _, e := range conf.Exempt {
+		if strings.HasSuffix(e, "*") {
+			if strings.HasPrefix(p, e[:len(e)-1]) {
+				return true
+			}
+		} else if p == e {
+			return true
+		}
+	}
+	return false
+}
+
+// 无副作用的方法，不校验令牌
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case GET, HEAD, OPTIONS, TRACE:
+		return true
+	}
+	return false
+}
+
+func newCSRFToken() string {
+	b := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		panic("lessgo: failed to generate csrf token: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// 检查cookie中的令牌格式，防止注入任意内容
+func validCSRFToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == csrfTokenSize
+}
+
+// 注册CSRF令牌的模板变量
+func registerCSRFTemplateVariables(r Renderer) {
+	r.TemplateVariable(CSRFTokenTplVar, func(c *Context) interface{} {
+		return c.CSRFToken()
+	})
+	r.TemplateVariable(CSRFFieldTplVar, func(c *Context) interface{} {
+		return pongo2.AsSafeValue(c.CSRFField())
+	})
+}
@@ -2,6 +2,7 @@ package lessgo
 
 import (
 	"io/ioutil"
+	"net/http"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -15,6 +16,7 @@ type MemoryCache struct {
 	enable          *bool                 // 是否启动缓存
 	gc              time.Duration         // 缓存更新检查时长及动态过期时长
 	filemap         map[string]*Cachefile // 已监控的文件缓存
+	fs              http.FileSystem       // 读取文件的文件系统
 	trigger         chan struct{}         // 主动触发扫描本地文件
 	once            sync.Once
 	sync.RWMutex
@@ -27,6 +29,7 @@ func NewMemoryCache(singleFileAllow, maxCap int64, gc time.Duration) *MemoryCach
 		gc:              gc,
 		enable:          new(bool),
 		filemap:         map[string]*Cachefile{},
+		fs:              OSFileSystem{},
 		trigger:         make(chan struct{}),
 	}
 }
@@ -51,6 +54,15 @@ func (m *MemoryCache) SetEnable(bl bool) {
 	}
 }
 
+// 运行时修改缓存容量限制与监测频率
+func (m *MemoryCache) SetLimits(singleFileAllow, maxCap int64, gc time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.singleFileAllow = singleFileAllow
+	m.maxCap = maxCap
+	m.gc = gc
+}
+
 // 返回文件字节流、文件信息、文件是否存在
 func (m *MemoryCache) GetCacheFile(fname string) ([]byte, os.FileInfo, bool) {
 	m.RLock()
@@ -72,12 +84,19 @@ func (m *MemoryCache) GetCacheFile(fname string) ([]byte, os.FileInfo, bool) {
 	}
 
 	// 读取本地文件
-	file, err := os.Open(fname)
+	file, err := m.fs.Open(fname)
 	if err != nil {
 		return nil, nil, false
 	}
 	defer file.Close()
-	info, _ := file.Stat()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, false
+	}
+	if info.IsDir() {
+		// 目录不缓存
+		return nil, info, true
+	}
 	buf, err := ioutil.ReadAll(file)
 	if err != nil {
 		return buf, info, true
@@ -96,6 +115,42 @@ func (m *MemoryCache) GetCacheFile(fname string) ([]byte, os.FileInfo, bool) {
 	return buf, info, true
 }
 
+// 返回由文件内容生成的派生数据(如markdown渲染后的HTML)、文件信息、文件是否存在，
+// kind区分不同的生成方式，派生数据随文件缓存保存并以文件修改时间为准，文件被修改或移出缓存后重新生成；
+// 文件未被缓存(如超出容量)时每次调用build生成，fname为目录时返回的派生数据为nil
+func (m *MemoryCache) GetDerived(fname, kind string, build func([]byte) (interface{}, error)) (interface{}, os.FileInfo, bool, error) {
+	b, info, exist := m.GetCacheFile(fname)
+	if !exist || info == nil || info.IsDir() {
+		return nil, info, exist, nil
+	}
+	m.RLock()
+	cfile := m.filemap[fname]
+	m.RUnlock()
+	if cfile != nil {
+		if v, ok := cfile.getDerived(kind, info.ModTime()); ok {
+			return v, info, true, nil
+		}
+	}
+	v, err := build(b)
+	if err != nil {
+		return nil, info, true, err
+	}
+	if cfile != nil {
+		cfile.setDerived(kind, info.ModTime(), v)
+	}
+	return v, info, true, nil
+}
+
+// 设置读取文件的文件系统，并清空全部文件缓存
+func (m *MemoryCache) SetFileSystem(fs http.FileSystem) {
+	m.Lock()
+	defer m.Unlock()
+	m.fs = fs
+	for _, cfile := range m.filemap {
+		m.delete(cfile)
+	}
+}
+
 // 主动触发扫描本地文件
 func (m *MemoryCache) TriggerScan() {
 	defer func() {
@@ -188,7 +243,7 @@ const (
 func (m *MemoryCache) check(c *Cachefile) int {
 	c.RLock()
 	defer c.RUnlock()
-	info, err := os.Stat(c.fname)
+	info, err := statFS(m.fs, c.fname)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// 文件不存在
@@ -235,9 +290,10 @@ func (m *MemoryCache) update(c *Cachefile, preupdate bool) {
 		defer c.Unlock()
 		c.bytes = nil
 		c.info = nil
+		c.derived = nil
 	}
 	// 读取本地文件
-	file, err := os.Open(c.fname)
+	file, err := m.fs.Open(c.fname)
 	if err != nil {
 		return
 	}
@@ -254,6 +310,7 @@ func (m *MemoryCache) update(c *Cachefile, preupdate bool) {
 	// 写入缓存
 	c.bytes = buf
 	c.info = info
+	c.derived = nil
 	c.exist = true
 	c.time = time.Now().Unix()
 }
@@ -267,17 +324,25 @@ func (m *MemoryCache) delete(c *Cachefile) {
 	c.exist = false
 	c.bytes = nil
 	c.info = nil
+	c.derived = nil
 }
 
 type Cachefile struct {
-	fname string      // 文件全名
-	info  os.FileInfo // 文件信息
-	bytes []byte      // 文件字节流
-	time  int64       // 最近一次访问或更新时间，用于gc回收
-	exist bool        // 文件在本地是否存在，避免每次扫描本地文件
+	fname   string                 // 文件全名
+	info    os.FileInfo            // 文件信息
+	bytes   []byte                 // 文件字节流
+	time    int64                  // 最近一次访问或更新时间，用于gc回收
+	exist   bool                   // 文件在本地是否存在，避免每次扫描本地文件
+	derived map[string]derivedData // 由文件内容生成的派生数据
 	sync.RWMutex
 }
 
+// 由文件内容生成的派生数据
+type derivedData struct {
+	modTime time.Time // 生成时文件的修改时间
+	value   interface{}
+}
+
 // 获取缓存的文件大小
 func (c *Cachefile) size() int64 {
 	c.RLock()
@@ -314,4 +379,29 @@ func (c *Cachefile) clean() {
 	c.exist = false
 	c.bytes = nil
 	c.info = nil
+	c.derived = nil
+}
+
+// 获取与缓存文件修改时间一致的派生数据
+func (c *Cachefile) getDerived(kind string, modTime time.Time) (interface{}, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	d, ok := c.derived[kind]
+	if !ok || c.info == nil || !d.modTime.Equal(modTime) || !d.modTime.Equal(c.info.ModTime()) {
+		return nil, false
+	}
+	return d.value, true
+}
+
+// 保存派生数据，缓存文件已被修改时放弃
+func (c *Cachefile) setDerived(kind string, modTime time.Time, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	if c.info == nil || !modTime.Equal(c.info.ModTime()) {
+		return
+	}
+	if c.derived == nil {
+		c.derived = make(map[string]derivedData)
+	}
+	c.derived[kind] = derivedData{modTime: modTime, value: value}
 }
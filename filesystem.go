@@ -0,0 +1,266 @@
+package lessgo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模板渲染、Context.File、Context.Markdown及MemoryCache读取文件时使用的文件系统，
+// 文件名为相对于工作目录的路径(如"static/tpl/index.tpl")，默认为OSFileSystem，可通过SetFileSystem替换：
+//
+//	// 将static、biz_view等目录打包进二进制文件(如go-bindata生成的Asset等函数)，本地存在的同名文件优先
+//	bundle, _ := lessgo.NewBindataFileSystem("", AssetNames(), Asset, AssetInfo)
+//	lessgo.SetFileSystem(lessgo.OverlayFileSystem{lessgo.OSFileSystem{}, bundle})
+var _ http.FileSystem = OSFileSystem{}
+
+// 操作系统的文件系统，文件名为相对于工作目录的路径或绝对路径
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (http.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+// 编译进二进制文件的文件系统，文件与目录均只读，目录由文件路径自动生成
+type BundleFileSystem struct {
+	files map[string]*bundleFile
+	dirs  map[string]map[string]bool // 目录 => 子文件及子目录名称
+	sync.RWMutex
+}
+
+type bundleFile struct {
+	data []byte
+	info os.FileInfo
+}
+
+// 创建空的BundleFileSystem
+func NewBundleFileSystem() *BundleFileSystem {
+	return &BundleFileSystem{
+		files: make(map[string]*bundleFile),
+		dirs:  map[string]map[string]bool{".": {}},
+	}
+}
+
+// 由go-bindata生成的AssetNames、Asset与AssetInfo创建BundleFileSystem(如_fixture目录)，
+// prefix为文件名前缀，如"static"时"js/app.js"对应"static/js/app.js"
+func NewBindataFileSystem(prefix string, names []string, asset func(string) ([]byte, error), assetInfo func(string) (os.FileInfo, error)) (*BundleFileSystem, error) {
+	b := NewBundleFileSystem()
+	for _, name := range names {
+		data, err := asset(name)
+		if err != nil {
+			return nil, err
+		}
+		var modTime time.Time
+		if info, err := assetInfo(name); err == nil {
+			modTime = info.ModTime()
+		}
+		b.Add(path.Join(prefix, filepath.ToSlash(name)), data, modTime)
+	}
+	return b, nil
+}
+
+// 添加文件，同名文件将被替换
+func (b *BundleFileSystem) Add(name string, data []byte, modTime time.Time) {
+	name = cleanFSName(name)
+	b.Lock()
+	defer b.Unlock()
+	b.files[name] = &bundleFile{
+		data: data,
+		info: &bundleFileInfo{name: path.Base(name), size: int64(len(data)), modTime: modTime},
+	}
+	for child, dir := path.Base(name), path.Dir(name); ; child, dir = path.Base(dir), path.Dir(dir) {
+		if b.dirs[dir] == nil {
+			b.dirs[dir] = make(map[string]bool)
+		}
+		b.dirs[dir][child] = true
+		if dir == "." {
+			break
+		}
+	}
+}
+
+func (b *BundleFileSystem) Open(name string) (http.File, error) {
+	name = cleanFSName(name)
+	b.RLock()
+	defer b.RUnlock()
+	if f, ok := b.files[name]; ok {
+		return &bundleHTTPFile{Reader: bytes.NewReader(f.data), info: f.info}, nil
+	}
+	children, ok := b.dirs[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	infos := make([]os.FileInfo, 0, len(children))
+	for child := range children {
+		childName := path.Join(name, child)
+		if f, ok := b.files[childName]; ok {
+			infos = append(infos, f.info)
+		} else {
+			infos = append(infos, &bundleFileInfo{name: child, dir: true})
+		}
+	}
+	sort.Sort(fileInfos(infos))
+	return &bundleHTTPFile{
+		Reader:   bytes.NewReader(nil),
+		info:     &bundleFileInfo{name: path.Base(name), dir: true},
+		children: infos,
+	}, nil
+}
+
+// BundleFileSystem中的文件名，均为不以"/"开头的相对路径，根目录为"."
+func cleanFSName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+type bundleHTTPFile struct {
+	*bytes.Reader
+	info     os.FileInfo
+	children []os.FileInfo
+	offset   int
+}
+
+func (f *bundleHTTPFile) Close() error { return nil }
+
+func (f *bundleHTTPFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *bundleHTTPFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+	rest := f.children[f.offset:]
+	if count <= 0 {
+		f.offset = len(f.children)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	f.offset += count
+	return rest[:count], nil
+}
+
+type bundleFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *bundleFileInfo) Name() string       { return fi.name }
+func (fi *bundleFileInfo) Size() int64        { return fi.size }
+func (fi *bundleFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *bundleFileInfo) IsDir() bool        { return fi.dir }
+func (fi *bundleFileInfo) Sys() interface{}   { return nil }
+func (fi *bundleFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+type fileInfos []os.FileInfo
+
+func (f fileInfos) Len() int           { return len(f) }
+func (f fileInfos) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f fileInfos) Less(i, j int) bool { return f[i].Name() < f[j].Name() }
+
+// 叠加的文件系统，按顺序打开第一个存在的文件，目录的内容同样只来自第一个存在该目录的文件系统
+type OverlayFileSystem []http.FileSystem
+
+func (o OverlayFileSystem) Open(name string) (http.File, error) {
+	var err error = &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	for _, fs := range o {
+		var f http.File
+		f, err = fs.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// 读取文件系统中的文件内容
+func readFSFile(fs http.FileSystem, name string) ([]byte, os.FileInfo, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := ioutil.ReadAll(f)
+	return b, info, err
+}
+
+// 获取文件系统中的文件信息
+func statFS(fs http.FileSystem, name string) (os.FileInfo, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// 遍历文件系统中dir目录下的全部文件(不含目录)，文件名为以"/"分隔的路径
+func walkFS(fs http.FileSystem, dir string, fn func(name string, info os.FileInfo)) {
+	f, err := fs.Open(dir)
+	if err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil || !info.IsDir() {
+		f.Close()
+		if err == nil {
+			fn(dir, info)
+		}
+		return
+	}
+	infos, _ := f.Readdir(-1)
+	f.Close()
+	sort.Sort(fileInfos(infos))
+	for _, info := range infos {
+		name := path.Join(dir, info.Name())
+		if info.IsDir() {
+			walkFS(fs, name, fn)
+		} else {
+			fn(name, info)
+		}
+	}
+}
+
+// pongo2的模板加载器，从App的文件系统中读取模板，
+// 模板中include、extends等引用的相对路径相对于当前模板所在的目录
+type fsTemplateLoader struct{}
+
+func (fsTemplateLoader) Abs(base, name string) string {
+	if filepath.IsAbs(name) || len(base) == 0 {
+		return path.Clean(filepath.ToSlash(name))
+	}
+	return path.Join(path.Dir(filepath.ToSlash(base)), filepath.ToSlash(name))
+}
+
+func (fsTemplateLoader) Get(name string) (io.Reader, error) {
+	b, _, err := readFSFile(app.FileSystem(), name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
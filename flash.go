@@ -0,0 +1,122 @@
+package lessgo
+
+import (
+	"sync"
+)
+
+// session中保存闪存消息的键
+const flashSessionKey = "_flash"
+
+// 模板中闪存消息的变量名
+const flashesTplVar = "flashes"
+
+// 常用的闪存消息类别
+const (
+	FlashSuccess = "success"
+	FlashInfo    = "info"
+	FlashWarning = "warning"
+	FlashError   = "error"
+)
+
+type (
+	// 闪存消息，用于post/redirect/get模式下向重定向后的页面传递提示
+	Flash struct {
+		Kind    string
+		Message string
+	}
+	// 按添加顺序排列的闪存消息
+	Flashes []Flash
+	// 延迟读取的闪存消息，首次访问时才从session中读取并清除，
+	// 故未使用flashes变量的模板(如局部模板、错误页)不会消耗闪存消息
+	LazyFlashes struct {
+		c       *Context
+		once    sync.Once
+		flashes Flashes
+	}
+)
+
+// 指定类别的全部消息，模板中用法如{% for msg in flashes.Of("error") %}
+func (f Flashes) Of(kind string) []string {
+	var msgs []string
+	for _, m := range f {
+		if m.Kind == kind {
+			msgs = append(msgs, m.Message)
+		}
+	}
+	return msgs
+}
+
+// 是否含有指定类别的消息
+func (f Flashes) Has(kind string) bool {
+	for _, m := range f {
+		if m.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// 全部闪存消息，模板中用法如{% for f in flashes.All() %}{{ f.Message }}{% endfor %}
+func (l *LazyFlashes) All() Flashes {
+	l.once.Do(func() {
+		l.flashes = l.c.Flashes()
+	})
+	return l.flashes
+}
+
+// 指定类别的全部消息，模板中用法如{% for msg in flashes.Of("error") %}
+func (l *LazyFlashes) Of(kind string) []string {
+	return l.All().Of(kind)
+}
+
+// 是否含有指定类别的消息
+func (l *LazyFlashes) Has(kind string) bool {
+	return l.All().Has(kind)
+}
+
+// 是否含有消息，模板中用法如{% if flashes.Any() %}
+func (l *LazyFlashes) Any() bool {
+	return len(l.All()) > 0
+}
+
+// 添加一条闪存消息，保存于session直至被读取，须开启session；
+// 以各序列化方式(gob/json/msgpack)均可还原的类型保存，故同样适用于cookie session
+func (c *Context) Flash(kind, msg string) {
+	if c.cruSession == nil {
+		Log.Warn("flash message is discarded: the session is disabled")
+		return
+	}
+	list, _ := c.cruSession.Get(flashSessionKey).([]interface{})
+	list = append(list, map[string]interface{}{"kind": kind, "msg": msg})
+	c.cruSession.Set(flashSessionKey, list)
+}
+
+// 读取并清除全部闪存消息，即每条消息只能读取一次；
+// Pongo2Render渲染模板时以LazyFlashes类型的flashes变量提供给模板，模板访问时才读取
+func (c *Context) Flashes() Flashes {
+	if c.cruSession == nil {
+		return nil
+	}
+	v := c.cruSession.Get(flashSessionKey)
+	if v == nil {
+		return nil
+	}
+	c.cruSession.Delete(flashSessionKey)
+	list, _ := v.([]interface{})
+	flashes := make(Flashes, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _ := m["kind"].(string)
+		msg, _ := m["msg"].(string)
+		flashes = append(flashes, Flash{Kind: kind, Message: msg})
+	}
+	return flashes
+}
+
+// 是否有未读取的闪存消息
+func (c *Context) HasFlashes() bool {
+	return c.cruSession != nil && c.cruSession.Get(flashSessionKey) != nil
+}
@@ -0,0 +1,160 @@
+package lessgo
+
+import (
+	"html"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/henrylee2cn/lessgo/i18n"
+	"github.com/henrylee2cn/lessgo/pongo2"
+)
+
+// 全局国际化消息目录，启动时加载I18N_DIR目录下的消息文件(如i18n/zh-CN.json、i18n/en.yaml)，
+// 消息文件的格式及消息的格式化规则详见i18n包
+var I18n = newI18n()
+
+// 模板中表示当前请求语言的变量名，由Pongo2Render自动传入
+const localeTplVar = "locale"
+
+// 默认失败页面使用的状态码
+var i18nStatusCodes = []int{400, 401, 403, 404, 405, 408, 413, 415, 429, 500, 502, 503, 504}
+
+// 内置的状态码消息，可被消息文件中的同名消息覆盖
+var builtinStatusText = map[string]map[int]string{
+	"zh-CN": {
+		400: "错误的请求",
+		401: "未授权",
+		403: "禁止访问",
+		404: "页面不存在",
+		405: "不允许的请求方法",
+		408: "请求超时",
+		413: "请求实体过大",
+		415: "不支持的媒体类型",
+		429: "请求过于频繁",
+		500: "服务器内部错误",
+		502: "网关错误",
+		503: "服务不可用",
+		504: "网关超时",
+	},
+}
+
+func newI18n() *i18n.Catalog {
+	c := i18n.NewCatalog(Config.I18n.DefaultLocale)
+	for _, code := range i18nStatusCodes {
+		c.Set("en", statusTextKey(code), http.StatusText(code))
+	}
+	for locale, texts := range builtinStatusText {
+		for code, text := range texts {
+			c.Set(locale, statusTextKey(code), text)
+		}
+	}
+	if err := c.LoadDir(I18N_DIR); err != nil && !os.IsNotExist(err) {
+		Log.Error("Failed to load i18n messages: %v", err)
+	}
+	return c
+}
+
+// 状态码消息的键名，如"status.404"
+func statusTextKey(code int) string {
+	return "status." + strconv.Itoa(code)
+}
+
+func init() {
+	pongo2.RegisterTag("trans", tagTransParser)
+	pongo2.RegisterFilter("trans", filterTrans)
+}
+
+// 模板标签，按当前请求的语言翻译消息：
+//
+//	{% trans "hello" %}
+//	{% trans "apples" count %}
+//	{% trans "greeting" name=user.Name count=n %}
+//
+// 自动转义开启时，参数中的字符串会被转义，消息本身不转义。
+type tagTransNode struct {
+	key   pongo2.IEvaluator
+	args  []pongo2.IEvaluator
+	named map[string]pongo2.IEvaluator
+}
+
+func (node *tagTransNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	key, err := node.key.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	var args []interface{}
+	if len(node.named) > 0 {
+		named := make(i18n.Args, len(node.named))
+		for name, expr := range node.named {
+			v, err := expr.Evaluate(ctx)
+			if err != nil {
+				return err
+			}
+			named[name] = transArg(ctx, v)
+		}
+		args = append(args, named)
+	} else {
+		for _, expr := range node.args {
+			v, err := expr.Evaluate(ctx)
+			if err != nil {
+				return err
+			}
+			args = append(args, transArg(ctx, v))
+		}
+	}
+	locale, _ := ctx.Public[localeTplVar].(string)
+	if len(locale) == 0 {
+		locale = I18n.Default()
+	}
+	writer.WriteString(I18n.Translate(locale, key.String(), args...))
+	return nil
+}
+
+func transArg(ctx *pongo2.ExecutionContext, v *pongo2.Value) interface{} {
+	if ctx.Autoescape && v.IsString() {
+		return html.EscapeString(v.String())
+	}
+	return v.Interface()
+}
+
+func tagTransParser(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	node := &tagTransNode{named: make(map[string]pongo2.IEvaluator)}
+	key, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	node.key = key
+	for arguments.Remaining() > 0 {
+		if arguments.PeekType(pongo2.TokenIdentifier) != nil && arguments.PeekN(1, pongo2.TokenSymbol, "=") != nil {
+			name := arguments.MatchType(pongo2.TokenIdentifier)
+			arguments.Consume()
+			expr, err := arguments.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			node.named[name.Val] = expr
+			continue
+		}
+		expr, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		node.args = append(node.args, expr)
+	}
+	if len(node.args) > 0 && len(node.named) > 0 {
+		return nil, arguments.Error("Positional and named arguments can not be mixed in 'trans'.", nil)
+	}
+	return node, nil
+}
+
+// 模板过滤器，按参数指定的语言翻译消息，参数为空时使用默认语言：
+//
+//	{{ "status.404"|trans:locale }}
+func filterTrans(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	locale := param.String()
+	if param.IsNil() || len(locale) == 0 {
+		locale = I18n.Default()
+	}
+	return pongo2.AsValue(I18n.Translate(locale, in.String())), nil
+}
@@ -0,0 +1,435 @@
+// Package i18n provides message catalogs with plural rules and locale negotiation.
+//
+// Catalog files are named after their locale, e.g. i18n/zh-CN.json or i18n/en.yaml:
+//
+//	{
+//	  "hello": "Hello, {name}!",
+//	  "apples": {"one": "%d apple", "other": "%d apples"},
+//	  "status": {"404": "Page Not Found"}
+//	}
+//
+// Nested objects are flattened with ".", e.g. "status.404". An object whose keys
+// are all plural categories (zero, one, two, few, many, other) is a plural message,
+// the form is chosen by the plural rule of the locale and falls back to "other".
+//
+// Messages are formatted with the arguments of Translate:
+//
+//	Translate("en", "hello", i18n.Args{"name": "Henry"})  // {name} is replaced
+//	Translate("en", "apples", 3)                          // fmt.Sprintf("%d apples", 3)
+//
+// the count of a plural message is the first argument, or the "count" of Args.
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/henrylee2cn/lessgo/config/yaml/goyaml2"
+)
+
+// Args holds the named arguments of a message, they replace the {name} placeholders.
+type Args map[string]interface{}
+
+// Catalog holds the messages of all the locales.
+type Catalog struct {
+	defaultLocale string
+	locales       map[string]string              // normalized locale => locale
+	messages      map[string]map[string]*message // normalized locale => key => message
+	sync.RWMutex
+}
+
+type message struct {
+	text   string
+	plural map[string]string // plural category => text
+}
+
+// NewCatalog creates an empty catalog, defaultLocale is used when no locale matches.
+func NewCatalog(defaultLocale string) *Catalog {
+	return &Catalog{
+		defaultLocale: defaultLocale,
+		locales:       make(map[string]string),
+		messages:      make(map[string]map[string]*message),
+	}
+}
+
+// Default returns the default locale.
+func (c *Catalog) Default() string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.defaultLocale
+}
+
+// SetDefault sets the default locale.
+func (c *Catalog) SetDefault(locale string) {
+	c.Lock()
+	defer c.Unlock()
+	c.defaultLocale = locale
+}
+
+// Locales returns all the locales of the catalog in order.
+func (c *Catalog) Locales() []string {
+	c.RLock()
+	defer c.RUnlock()
+	locales := make([]string, 0, len(c.locales))
+	for _, locale := range c.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Set sets a message of the locale.
+func (c *Catalog) Set(locale, key, text string) {
+	c.Lock()
+	defer c.Unlock()
+	c.localeMessages(locale)[key] = &message{text: text}
+}
+
+// SetPlural sets a plural message of the locale, forms maps the plural categories to the texts.
+func (c *Catalog) SetPlural(locale, key string, forms map[string]string) {
+	plural := make(map[string]string, len(forms))
+	for k, v := range forms {
+		plural[k] = v
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.localeMessages(locale)[key] = &message{plural: plural}
+}
+
+// localeMessages returns the messages of the locale, the caller must hold the lock.
+func (c *Catalog) localeMessages(locale string) map[string]*message {
+	n := normalize(locale)
+	msgs, ok := c.messages[n]
+	if !ok {
+		msgs = make(map[string]*message)
+		c.messages[n] = msgs
+		c.locales[n] = locale
+	}
+	return msgs
+}
+
+// LoadDir loads all the .json, .yaml and .yml files in the directory,
+// the file name without extension is the locale, e.g. zh-CN.json.
+// messages of the same key are replaced.
+func (c *Catalog) LoadDir(dir string) error {
+	fnames, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range fnames {
+		ext := filepath.Ext(info.Name())
+		if info.IsDir() || format(ext) == "" {
+			continue
+		}
+		locale := strings.TrimSuffix(info.Name(), ext)
+		if err = c.LoadFile(locale, filepath.Join(dir, info.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile loads the messages of the locale from a .json, .yaml or .yml file.
+func (c *Catalog) LoadFile(locale, fname string) error {
+	f := format(filepath.Ext(fname))
+	if f == "" {
+		return fmt.Errorf("i18n: unsupported file %s", fname)
+	}
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	if err = c.Load(locale, f, data); err != nil {
+		return fmt.Errorf("i18n: %s: %v", fname, err)
+	}
+	return nil
+}
+
+// Load loads the messages of the locale, format is "json" or "yaml".
+func (c *Catalog) Load(locale, format string, data []byte) error {
+	var (
+		tree interface{}
+		err  error
+	)
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, &tree)
+	case "yaml":
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil
+		}
+		tree, err = goyaml2.Read(bytes.NewReader(data))
+	default:
+		return fmt.Errorf("i18n: unsupported format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	root, ok := tree.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("i18n: the catalog must be an object")
+	}
+	msgs := make(map[string]*message)
+	if err = flatten("", root, msgs); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	dst := c.localeMessages(locale)
+	for k, m := range msgs {
+		dst[k] = m
+	}
+	return nil
+}
+
+func format(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+func flatten(prefix string, tree map[string]interface{}, msgs map[string]*message) error {
+	for k, v := range tree {
+		key := prefix + k
+		switch x := v.(type) {
+		case map[string]interface{}:
+			if plural, ok := pluralForms(x); ok {
+				msgs[key] = &message{plural: plural}
+			} else if err := flatten(key+".", x, msgs); err != nil {
+				return err
+			}
+		case nil:
+		case []interface{}:
+			return fmt.Errorf("%s: a message must be a string or an object", key)
+		default:
+			msgs[key] = &message{text: fmt.Sprint(x)}
+		}
+	}
+	return nil
+}
+
+func pluralForms(tree map[string]interface{}) (map[string]string, bool) {
+	if len(tree) == 0 {
+		return nil, false
+	}
+	forms := make(map[string]string, len(tree))
+	for k, v := range tree {
+		if !isPluralCategory(k) {
+			return nil, false
+		}
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, false
+		}
+		forms[k] = fmt.Sprint(v)
+	}
+	return forms, true
+}
+
+// Has reports whether the key has a message in the locale or its fallback locales.
+func (c *Catalog) Has(locale, key string) bool {
+	_, _, ok := c.lookup(locale, key)
+	return ok
+}
+
+// Translate returns the message of the key in the locale formatted with args,
+// and the key itself when there is no such message.
+// the locale falls back to its language (zh-CN => zh) and then to the default locale.
+func (c *Catalog) Translate(locale, key string, args ...interface{}) string {
+	m, found, ok := c.lookup(locale, key)
+	if !ok {
+		return key
+	}
+	text := m.text
+	if m.plural != nil {
+		n, _ := count(args)
+		text, ok = m.plural[PluralCategory(found, n)]
+		if !ok {
+			text = m.plural[Other]
+		}
+	}
+	return Format(text, args...)
+}
+
+// lookup returns the message of the key and the locale where it is found.
+func (c *Catalog) lookup(locale, key string) (*message, string, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	for _, l := range c.fallbacks(locale) {
+		if m, ok := c.messages[l][key]; ok {
+			return m, c.locales[l], true
+		}
+	}
+	return nil, "", false
+}
+
+// fallbacks returns the normalized locales to look up in order, the caller must hold the lock.
+func (c *Catalog) fallbacks(locale string) []string {
+	n, base, def := normalize(locale), baseLanguage(locale), normalize(c.defaultLocale)
+	list := []string{n}
+	if base != n {
+		list = append(list, base)
+	}
+	if def != n && def != base {
+		list = append(list, def)
+		if b := baseLanguage(def); b != def && b != base {
+			list = append(list, b)
+		}
+	}
+	return list
+}
+
+// Match returns the first locale of the catalog matching the preferred locales,
+// a locale matches its language and the other locales of its language, e.g.
+// "zh-TW" matches "zh" and "zh-CN" when there is no "zh-TW". returns "" if none matches.
+func (c *Catalog) Match(preferred ...string) string {
+	c.RLock()
+	defer c.RUnlock()
+	for _, p := range preferred {
+		n, base := normalize(p), baseLanguage(p)
+		if n == "" || n == "*" {
+			continue
+		}
+		if locale, ok := c.locales[n]; ok {
+			return locale
+		}
+		if locale, ok := c.locales[base]; ok {
+			return locale
+		}
+		var same []string
+		for l, locale := range c.locales {
+			if baseLanguage(l) == base {
+				same = append(same, locale)
+			}
+		}
+		if len(same) > 0 {
+			sort.Strings(same)
+			return same[0]
+		}
+	}
+	return ""
+}
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header ordered by quality,
+// e.g. "en;q=0.8, zh-CN" => ["zh-CN", "en"].
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var list []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		locale := strings.TrimSpace(fields[0])
+		if locale == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > 0 {
+			list = append(list, weighted{locale, q})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+	locales := make([]string, len(list))
+	for i, w := range list {
+		locales[i] = w.locale
+	}
+	return locales
+}
+
+// Format formats the message with args, a single Args (or map[string]interface{})
+// replaces the {name} placeholders, other args are applied with fmt.Sprintf.
+func Format(text string, args ...interface{}) string {
+	if len(args) == 0 {
+		return text
+	}
+	if named, ok := namedArgs(args); ok {
+		var buf bytes.Buffer
+		for {
+			i := strings.IndexByte(text, '{')
+			if i < 0 {
+				break
+			}
+			j := strings.IndexByte(text[i:], '}')
+			if j < 0 {
+				break
+			}
+			name := text[i+1 : i+j]
+			v, ok := named[name]
+			buf.WriteString(text[:i])
+			if ok {
+				fmt.Fprint(&buf, v)
+			} else {
+				buf.WriteString(text[i : i+j+1])
+			}
+			text = text[i+j+1:]
+		}
+		buf.WriteString(text)
+		return buf.String()
+	}
+	if !strings.Contains(text, "%") {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+func namedArgs(args []interface{}) (map[string]interface{}, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	switch x := args[0].(type) {
+	case Args:
+		return x, true
+	case map[string]interface{}:
+		return x, true
+	}
+	return nil, false
+}
+
+// count returns the count of a plural message, which is the first argument or the "count" of Args.
+func count(args []interface{}) (float64, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	v := args[0]
+	if named, ok := namedArgs(args); ok {
+		v = named["count"]
+	}
+	return toFloat(v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
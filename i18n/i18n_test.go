@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"en.json": `{
+			"hello": "Hello, {name}!",
+			"apples": {"one": "%d apple", "other": "%d apples"},
+			"items": {"one": "{count} item", "other": "{count} items"},
+			"status": {"404": "Page Not Found"}
+		}`,
+		"zh-CN.yaml": "hello: 你好，{name}！\napples:\n  other: \"%d个苹果\"\nstatus:\n  404: 页面不存在\n",
+		"ru.yml":     "apples:\n  one: \"%d яблоко\"\n  few: \"%d яблока\"\n  many: \"%d яблок\"\n",
+		"README.md":  "ignored",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := NewCatalog("en")
+	if err = c.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if locales := c.Locales(); !reflect.DeepEqual(locales, []string{"en", "ru", "zh-CN"}) {
+		t.Fatalf("locales: %v", locales)
+	}
+	cases := []struct {
+		locale, key string
+		args        []interface{}
+		want        string
+	}{
+		{"en", "hello", []interface{}{Args{"name": "Henry"}}, "Hello, Henry!"},
+		{"zh-cn", "hello", []interface{}{Args{"name": "Henry"}}, "你好，Henry！"},
+		{"zh_CN", "status.404", nil, "页面不存在"},
+		{"en", "apples", []interface{}{1}, "1 apple"},
+		{"en", "apples", []interface{}{5}, "5 apples"},
+		{"zh-CN", "apples", []interface{}{1}, "1个苹果"},
+		{"ru", "apples", []interface{}{21}, "21 яблоко"},
+		{"ru", "apples", []interface{}{3}, "3 яблока"},
+		{"ru", "apples", []interface{}{11}, "11 яблок"},
+		{"ru", "hello", []interface{}{Args{"name": "Henry"}}, "Hello, Henry!"}, // default locale
+		{"fr", "status.404", nil, "Page Not Found"},
+		{"en", "missing", nil, "missing"},
+		{"zh-CN", "items", []interface{}{Args{"count": 2}}, "2 items"},
+	}
+	for _, cs := range cases {
+		if got := c.Translate(cs.locale, cs.key, cs.args...); got != cs.want {
+			t.Errorf("Translate(%q, %q, %v) = %q, want %q", cs.locale, cs.key, cs.args, got, cs.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	c := NewCatalog("en")
+	c.Set("en", "a", "a")
+	c.Set("zh-CN", "a", "a")
+	c.Set("pt-BR", "a", "a")
+	cases := []struct {
+		preferred []string
+		want      string
+	}{
+		{[]string{"zh-CN"}, "zh-CN"},
+		{[]string{"zh-TW"}, "zh-CN"},
+		{[]string{"zh"}, "zh-CN"},
+		{[]string{"en-US"}, "en"},
+		{[]string{"de", "pt"}, "pt-BR"},
+		{[]string{"de", "*"}, ""},
+	}
+	for _, cs := range cases {
+		if got := c.Match(cs.preferred...); got != cs.want {
+			t.Errorf("Match(%v) = %q, want %q", cs.preferred, got, cs.want)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := ParseAcceptLanguage("en;q=0.8, zh-CN,zh;q=0.9, de;q=0, fr")
+	want := []string{"zh-CN", "fr", "zh", "en"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	cases := []struct {
+		locale string
+		n      float64
+		want   string
+	}{
+		{"en", 1, One},
+		{"en-GB", 0, Other},
+		{"zh-CN", 1, Other},
+		{"fr", 0, One},
+		{"fr", 1.5, One},
+		{"pl", 22, Few},
+		{"pl", 25, Many},
+		{"cs", 3, Few},
+		{"ar", 0, Zero},
+		{"ar", 102, Other},
+		{"xx", 1, One},
+	}
+	for _, cs := range cases {
+		if got := PluralCategory(cs.locale, cs.n); got != cs.want {
+			t.Errorf("PluralCategory(%q, %v) = %q, want %q", cs.locale, cs.n, got, cs.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format("{a} and {b} and {c}", Args{"a": 1, "b": "x"}); got != "1 and x and {c}" {
+		t.Errorf("got %q", got)
+	}
+	if got := Format("%d%%", 50); got != "50%" {
+		t.Errorf("got %q", got)
+	}
+	if got := Format("no args"); got != "no args" {
+		t.Errorf("got %q", got)
+	}
+}
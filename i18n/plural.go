@@ -0,0 +1,165 @@
+package i18n
+
+import (
+	"math"
+	"strings"
+	"sync"
+)
+
+// Plural categories, see http://cldr.unicode.org/index/cldr-spec/plural-rules
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// PluralRule returns the plural category of the count n.
+type PluralRule func(n float64) string
+
+var (
+	pluralLock  sync.RWMutex
+	pluralRules = make(map[string]PluralRule)
+)
+
+func init() {
+	RegisterPluralRule(pluralOther, "zh", "ja", "ko", "vi", "th", "id", "ms", "lo", "my")
+	RegisterPluralRule(pluralOne, "en", "de", "nl", "sv", "da", "no", "nb", "nn", "fi", "it",
+		"es", "pt", "el", "hu", "tr", "bg", "et", "ca", "eu", "gl", "af", "sw")
+	RegisterPluralRule(pluralFrench, "fr", "pt-br", "hy", "kab")
+	RegisterPluralRule(pluralRussian, "ru", "uk", "be")
+	RegisterPluralRule(pluralPolish, "pl")
+	RegisterPluralRule(pluralCzech, "cs", "sk")
+	RegisterPluralRule(pluralArabic, "ar")
+}
+
+// RegisterPluralRule sets the plural rule of the languages or locales, e.g. "en", "pt-BR".
+// a locale without its own rule uses the rule of its language.
+func RegisterPluralRule(rule PluralRule, langs ...string) {
+	pluralLock.Lock()
+	defer pluralLock.Unlock()
+	for _, lang := range langs {
+		pluralRules[normalize(lang)] = rule
+	}
+}
+
+// PluralCategory returns the plural category of the count n in the locale,
+// languages without a registered rule use "one" for 1 and "other" for the rest.
+func PluralCategory(locale string, n float64) string {
+	pluralLock.RLock()
+	rule, ok := pluralRules[normalize(locale)]
+	if !ok {
+		rule, ok = pluralRules[baseLanguage(locale)]
+	}
+	pluralLock.RUnlock()
+	if !ok {
+		rule = pluralOne
+	}
+	return rule(n)
+}
+
+func isInt(n float64) bool {
+	return n == math.Trunc(n)
+}
+
+func pluralOther(n float64) string {
+	return Other
+}
+
+func pluralOne(n float64) string {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+func pluralFrench(n float64) string {
+	if n >= 0 && n < 2 {
+		return One
+	}
+	return Other
+}
+
+func pluralRussian(n float64) string {
+	if !isInt(n) {
+		return Other
+	}
+	i := int64(math.Abs(n))
+	switch {
+	case i%10 == 1 && i%100 != 11:
+		return One
+	case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+		return Few
+	}
+	return Many
+}
+
+func pluralPolish(n float64) string {
+	if !isInt(n) {
+		return Other
+	}
+	i := int64(math.Abs(n))
+	switch {
+	case i == 1:
+		return One
+	case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+		return Few
+	}
+	return Many
+}
+
+func pluralCzech(n float64) string {
+	switch {
+	case !isInt(n):
+		return Many
+	case n == 1:
+		return One
+	case n >= 2 && n <= 4:
+		return Few
+	}
+	return Other
+}
+
+func pluralArabic(n float64) string {
+	if !isInt(n) {
+		return Other
+	}
+	i := int64(math.Abs(n))
+	switch {
+	case i == 0:
+		return Zero
+	case i == 1:
+		return One
+	case i == 2:
+		return Two
+	case i%100 >= 3 && i%100 <= 10:
+		return Few
+	case i%100 >= 11:
+		return Many
+	}
+	return Other
+}
+
+func isPluralCategory(s string) bool {
+	switch s {
+	case Zero, One, Two, Few, Many, Other:
+		return true
+	}
+	return false
+}
+
+// normalize returns the lower case locale with "-" as the separator, e.g. "zh_CN" => "zh-cn".
+func normalize(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// baseLanguage returns the language of the locale, e.g. "zh-CN" => "zh".
+func baseLanguage(locale string) string {
+	locale = normalize(locale)
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"mime"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/henrylee2cn/lessgo/session"
+	"github.com/henrylee2cn/lessgo/utils"
 )
 
 func newLessgo() *Lessgo {
@@ -41,11 +43,24 @@ func newLessgo() *Lessgo {
 	)
 
 	// 设置渲染接口
-	l.App.SetRenderer(NewPongo2Render(!Config.Debug))
+	render := NewMultiRender()
+	pongo2Render := NewPongo2Render(!Config.Debug)
+	render.Register(TPL_EXT, pongo2Render)
+	// 其它扩展名(如.html)的模板沿用pongo2
+	render.SetDefault(pongo2Render)
+	render.Register(GOTPL_EXT, NewGoTemplateRender(!Config.Debug))
+	registerCSRFTemplateVariables(render)
+	registerCSPTemplateVariables(render)
+	l.App.SetRenderer(render)
 
 	// 设置上传文件允许的最大尺寸
 	MaxMemory = Config.MaxMemoryMB * MB
 
+	// 初始化加密cookie的密钥环
+	if err := setSecureCookieKeys(Config.Security.CookieKeys); err != nil {
+		Log.Error("Failed to set secure cookie keys: %v.", err)
+	}
+
 	// 初始化sessions管理实例
 	sessions, err := newSessions()
 	if err != nil {
@@ -83,11 +98,32 @@ func newSessions() (sessions *session.Manager, err error) {
 		"enableSidInHttpHeader":   Config.Session.EnableSidInHttpHeader,
 		"sessionNameInHttpHeader": Config.Session.SessionNameInHttpHeader,
 		"enableSidInUrlQuery":     Config.Session.EnableSidInUrlQuery,
+		"cookiePath":              Config.Session.SessionCookiePath,
+		"httpOnly":                Config.Session.SessionHttpOnly,
+		"sameSite":                Config.Session.SessionSameSite,
+		"absoluteLifetime":        Config.Session.SessionAbsoluteLifetime,
+		"serializer":              Config.Session.SessionSerializer,
 	}
 	confBytes, _ := json.Marshal(conf)
 	return session.NewManager(Config.Session.SessionProvider, string(confBytes))
 }
 
+// 设置加密cookie的密钥环，keys以逗号分隔，为空时使用随机密钥
+func setSecureCookieKeys(keys string) error {
+	var ring []string
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); len(key) > 0 {
+			ring = append(ring, key)
+		}
+	}
+	if len(ring) == 0 {
+		Log.Warn("Config security::cookiekeys is empty, secure cookies are invalid after restart.")
+		ring = []string{string(utils.RandomCreateBytes(32))}
+	}
+	secureCookie.SetMaxAge(CurrentConfig().Security.CookieMaxAge)
+	return secureCookie.SetKeys(ring...)
+}
+
 // 添加系统预设的路由操作前的中间件
 func registerBefore() {
 	PreUse(
@@ -95,7 +131,10 @@ func registerBefore() {
 		&MiddlewareConfig{Name: "检查是否为访问主页"},
 		&MiddlewareConfig{Name: "系统运行日志打印"},
 	)
-	if Config.CrossDomain {
+	lessgo.lock.Lock()
+	crossDomainIndex = len(lessgo.virtBefore)
+	lessgo.lock.Unlock()
+	if CurrentConfig().CrossDomain {
 		BeforeUse(&MiddlewareConfig{Name: "设置允许跨域"})
 	}
 }
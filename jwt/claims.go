@@ -0,0 +1,83 @@
+package jwt
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Claims is the payload of a token. The numbers of the verified claims are
+// json.Number, the time claims (exp, nbf, iat) are Unix seconds.
+type Claims map[string]interface{}
+
+// GetString returns the claim as a string, "" if it is absent or not a string.
+func (c Claims) GetString(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject returns the sub claim.
+func (c Claims) Subject() string {
+	return c.GetString("sub")
+}
+
+// Issuer returns the iss claim.
+func (c Claims) Issuer() string {
+	return c.GetString("iss")
+}
+
+// ID returns the jti claim.
+func (c Claims) ID() string {
+	return c.GetString("jti")
+}
+
+// Audience returns the aud claim, which is a string or an array of strings.
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []string:
+		return aud
+	case []interface{}:
+		list := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// ExpiresAt returns the exp claim, false if it is absent or invalid.
+func (c Claims) ExpiresAt() (time.Time, bool) {
+	t, ok, err := c.time("exp")
+	return t, ok && err == nil
+}
+
+// time returns the NumericDate claim, ErrInvalidClaim if it is not a number.
+func (c Claims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	var f float64
+	switch n := v.(type) {
+	case json.Number:
+		var err error
+		if f, err = n.Float64(); err != nil {
+			return time.Time{}, false, ErrInvalidClaim
+		}
+	case float64:
+		f = n
+	case int64:
+		f = float64(n)
+	case int:
+		f = float64(n)
+	default:
+		return time.Time{}, false, ErrInvalidClaim
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), true, nil
+}
@@ -0,0 +1,335 @@
+// Package jwt signs and verifies JSON Web Tokens (RFC 7519) with the HMAC
+// (HS256, HS384, HS512) and RSA PKCS #1 v1.5 (RS256, RS384, RS512) algorithms.
+//
+// The keys are kept in a KeySet, so that they can be rotated at runtime: add
+// the new key, sign with it, and remove the old key after the tokens signed
+// with it have expired.
+//
+// Usage:
+//
+//	keys := jwt.NewKeySet(&jwt.Key{ID: "2024-01", Algorithm: jwt.HS256, Secret: secret})
+//	token, err := jwt.Sign(jwt.Claims{"sub": "astaxie", "exp": time.Now().Add(time.Hour).Unix()}, keys.Get("2024-01"))
+//
+//	v := &jwt.Verifier{Keys: keys, Leeway: time.Minute, RequireExp: true}
+//	claims, err := v.Verify(token)
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The supported algorithms.
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+)
+
+var (
+	ErrMalformed    = errors.New("jwt: malformed token")
+	ErrAlgorithm    = errors.New("jwt: unsupported algorithm")
+	ErrUnknownKey   = errors.New("jwt: no key to verify the token")
+	ErrSignature    = errors.New("jwt: invalid signature")
+	ErrExpired      = errors.New("jwt: token is expired")
+	ErrMissingExp   = errors.New("jwt: token has no exp claim")
+	ErrNotValidYet  = errors.New("jwt: token is not valid yet")
+	ErrIssuer       = errors.New("jwt: invalid issuer")
+	ErrAudience     = errors.New("jwt: invalid audience")
+	ErrInvalidClaim = errors.New("jwt: invalid claim")
+)
+
+var hashes = map[string]crypto.Hash{
+	HS256: crypto.SHA256,
+	HS384: crypto.SHA384,
+	HS512: crypto.SHA512,
+	RS256: crypto.SHA256,
+	RS384: crypto.SHA384,
+	RS512: crypto.SHA512,
+}
+
+// Key is a key of an algorithm. An HMAC key has the Secret, an RSA key has
+// the PublicKey to verify and the PrivateKey (optional) to sign.
+type Key struct {
+	ID         string // the "kid" header
+	Algorithm  string
+	Secret     []byte
+	PublicKey  *rsa.PublicKey
+	PrivateKey *rsa.PrivateKey
+}
+
+func (k *Key) hmac() bool {
+	return strings.HasPrefix(k.Algorithm, "HS")
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	hash, ok := hashes[k.Algorithm]
+	if !ok {
+		return nil, ErrAlgorithm
+	}
+	if k.hmac() {
+		if len(k.Secret) == 0 {
+			return nil, errors.New("jwt: the secret of the key is empty")
+		}
+		mac := hmac.New(hash.New, k.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	if k.PrivateKey == nil {
+		return nil, errors.New("jwt: the key has no private key")
+	}
+	h := hash.New()
+	h.Write(input)
+	return rsa.SignPKCS1v15(rand.Reader, k.PrivateKey, hash, h.Sum(nil))
+}
+
+func (k *Key) verify(input, sig []byte) bool {
+	hash, ok := hashes[k.Algorithm]
+	if !ok {
+		return false
+	}
+	if k.hmac() {
+		if len(k.Secret) == 0 {
+			return false
+		}
+		mac := hmac.New(hash.New, k.Secret)
+		mac.Write(input)
+		return hmac.Equal(sig, mac.Sum(nil))
+	}
+	pub := k.PublicKey
+	if pub == nil && k.PrivateKey != nil {
+		pub = &k.PrivateKey.PublicKey
+	}
+	if pub == nil {
+		return false
+	}
+	h := hash.New()
+	h.Write(input)
+	return rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), sig) == nil
+}
+
+// KeySet is a set of keys safe for concurrent use.
+type KeySet struct {
+	keys []*Key
+	lock sync.RWMutex
+}
+
+// NewKeySet creates a key set.
+func NewKeySet(keys ...*Key) *KeySet {
+	s := &KeySet{}
+	s.Add(keys...)
+	return s
+}
+
+// Add adds the keys, a key replaces the key of the same ID.
+func (s *KeySet) Add(keys ...*Key) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, k := range keys {
+		replaced := false
+		for i, old := range s.keys {
+			if old.ID == k.ID {
+				s.keys[i] = k
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.keys = append(s.keys, k)
+		}
+	}
+}
+
+// Remove removes the key of the ID.
+func (s *KeySet) Remove(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, k := range s.keys {
+		if k.ID == id {
+			s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get returns the key of the ID, nil if it does not exist.
+func (s *KeySet) Get(id string) *Key {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+// Keys returns all the keys.
+func (s *KeySet) Keys() []*Key {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]*Key(nil), s.keys...)
+}
+
+// candidates returns the keys which may have signed a token: the key of the
+// kid, or all the keys of the algorithm if the token has no kid. The
+// algorithm of a key must match, so an RSA public key is never used as an
+// HMAC secret.
+func (s *KeySet) candidates(kid, alg string) []*Key {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var keys []*Key
+	for _, k := range s.keys {
+		if k.Algorithm == alg && (kid == "" || k.ID == kid) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Sign signs the claims with the key, the kid header is the ID of the key.
+func Sign(claims Claims, key *Key) (string, error) {
+	if key == nil {
+		return "", ErrUnknownKey
+	}
+	h, err := json.Marshal(header{Alg: key.Algorithm, Kid: key.ID, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := encode(h) + "." + encode(c)
+	sig, err := key.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + encode(sig), nil
+}
+
+// Verifier verifies the signature and the registered claims of the tokens.
+type Verifier struct {
+	Keys       *KeySet
+	Leeway     time.Duration    // the clock skew allowed when checking exp, nbf and iat
+	Issuer     string           // the iss claim must be it if not empty
+	Audience   string           // the aud claim must contain it if not empty
+	RequireExp bool             // the exp claim must be present, so no token is valid forever
+	Now        func() time.Time // time.Now if nil
+}
+
+// Verify parses the token and returns its claims. The numbers of the claims
+// are json.Number.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if _, ok := hashes[h.Alg]; !ok {
+		return nil, ErrAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if v.Keys == nil {
+		return nil, ErrUnknownKey
+	}
+	keys := v.Keys.candidates(h.Kid, h.Alg)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	input := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if k.verify(input, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrSignature
+	}
+	var claims Claims
+	if err = decodeJSON(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrMalformed
+	}
+	if err = v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if exp, ok, err := claims.time("exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(v.Leeway)) {
+		return ErrExpired
+	} else if !ok && v.RequireExp {
+		return ErrMissingExp
+	}
+	if nbf, ok, err := claims.time("nbf"); err != nil {
+		return err
+	} else if ok && now.Add(v.Leeway).Before(nbf) {
+		return ErrNotValidYet
+	}
+	if iat, ok, err := claims.time("iat"); err != nil {
+		return err
+	} else if ok && now.Add(v.Leeway).Before(iat) {
+		return ErrNotValidYet
+	}
+	if v.Issuer != "" && claims.Issuer() != v.Issuer {
+		return ErrIssuer
+	}
+	if v.Audience != "" {
+		for _, aud := range claims.Audience() {
+			if aud == v.Audience {
+				return nil
+			}
+		}
+		return ErrAudience
+	}
+	return nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
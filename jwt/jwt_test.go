@@ -0,0 +1,143 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMAC(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	keys := NewKeySet(&Key{ID: "k1", Algorithm: HS256, Secret: []byte("secret")})
+	token, err := Sign(Claims{"sub": "astaxie", "iss": "lessgo", "aud": []string{"api", "web"}, "exp": now.Add(time.Hour).Unix()}, keys.Get("k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{Keys: keys, Issuer: "lessgo", Audience: "web", Now: func() time.Time { return now }}
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject() != "astaxie" {
+		t.Fatalf("expected the subject astaxie, got %q", claims.Subject())
+	}
+	if exp, ok := claims.ExpiresAt(); !ok || !exp.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected exp %v", exp)
+	}
+
+	for _, c := range []struct {
+		name string
+		v    Verifier
+		err  error
+	}{
+		{"issuer", Verifier{Keys: keys, Issuer: "other", Now: v.Now}, ErrIssuer},
+		{"audience", Verifier{Keys: keys, Audience: "admin", Now: v.Now}, ErrAudience},
+		{"secret", Verifier{Keys: NewKeySet(&Key{ID: "k1", Algorithm: HS256, Secret: []byte("other")}), Now: v.Now}, ErrSignature},
+		{"algorithm", Verifier{Keys: NewKeySet(&Key{ID: "k1", Algorithm: HS512, Secret: []byte("secret")}), Now: v.Now}, ErrUnknownKey},
+	} {
+		if _, err = c.v.Verify(token); err != c.err {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2]
+	if _, err = v.Verify(tampered); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	if _, err = v.Verify(none); err != ErrAlgorithm {
+		t.Fatalf("expected %v, got %v", ErrAlgorithm, err)
+	}
+	if _, err = v.Verify("abc"); err != ErrMalformed {
+		t.Fatalf("expected %v, got %v", ErrMalformed, err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	key := &Key{Algorithm: HS384, Secret: []byte("secret")}
+	keys := NewKeySet(key)
+	expired, _ := Sign(Claims{"exp": now.Add(-30 * time.Second).Unix()}, key)
+	early, _ := Sign(Claims{"nbf": now.Add(30 * time.Second).Unix()}, key)
+	invalid, _ := Sign(Claims{"exp": "tomorrow"}, key)
+
+	v := &Verifier{Keys: keys, Now: func() time.Time { return now }}
+	if _, err := v.Verify(expired); err != ErrExpired {
+		t.Fatalf("expected %v, got %v", ErrExpired, err)
+	}
+	if _, err := v.Verify(early); err != ErrNotValidYet {
+		t.Fatalf("expected %v, got %v", ErrNotValidYet, err)
+	}
+	if _, err := v.Verify(invalid); err != ErrInvalidClaim {
+		t.Fatalf("expected %v, got %v", ErrInvalidClaim, err)
+	}
+	forever, _ := Sign(Claims{"sub": "astaxie"}, key)
+	if _, err := v.Verify(forever); err != nil {
+		t.Fatal(err)
+	}
+	strict := &Verifier{Keys: keys, RequireExp: true, Now: v.Now}
+	if _, err := strict.Verify(forever); err != ErrMissingExp {
+		t.Fatalf("expected %v, got %v", ErrMissingExp, err)
+	}
+	v.Leeway = time.Minute
+	if _, err := v.Verify(expired); err != nil {
+		t.Fatal("the leeway is not applied to exp,", err)
+	}
+	if _, err := v.Verify(early); err != nil {
+		t.Fatal("the leeway is not applied to nbf,", err)
+	}
+}
+
+func TestRSAAndRotation(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	pub, err := ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseRSAPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := &Key{ID: "old", Algorithm: HS256, Secret: []byte("secret")}
+	keys := NewKeySet(old)
+	oldToken, _ := Sign(Claims{"sub": "old"}, old)
+
+	// rotate: sign with the new key, the tokens of the old key are still valid
+	keys.Add(&Key{ID: "new", Algorithm: RS256, PublicKey: pub})
+	newToken, err := Sign(Claims{"sub": "new"}, &Key{ID: "new", Algorithm: RS256, PrivateKey: parsed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{Keys: keys}
+	for _, token := range []string{oldToken, newToken} {
+		if _, err = v.Verify(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys.Remove("old")
+	if _, err = v.Verify(oldToken); err != ErrUnknownKey {
+		t.Fatalf("expected %v, got %v", ErrUnknownKey, err)
+	}
+	if _, err = v.Verify(newToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// the RSA public key must not be accepted as an HMAC secret
+	keys.Add(&Key{ID: "new", Algorithm: HS256, Secret: der})
+	forged, _ := Sign(Claims{"sub": "admin"}, &Key{ID: "new", Algorithm: HS256, Secret: der})
+	keys.Add(&Key{ID: "new", Algorithm: RS256, PublicKey: pub})
+	if _, err = v.Verify(forged); err != ErrUnknownKey {
+		t.Fatalf("expected %v, got %v", ErrUnknownKey, err)
+	}
+}
@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// ParseRSAPublicKey parses a PEM encoded RSA public key of the PKIX ("PUBLIC
+// KEY") or PKCS #1 ("RSA PUBLIC KEY") form, or the key of a certificate.
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM data")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return pub, nil
+		}
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if pub, ok := key.(*rsa.PublicKey); ok {
+			return pub, nil
+		}
+	}
+	return nil, errors.New("jwt: not an RSA public key")
+}
+
+// ParseRSAPrivateKey parses a PEM encoded RSA private key of the PKCS #1
+// ("RSA PRIVATE KEY") or PKCS #8 ("PRIVATE KEY") form.
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM data")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if priv, ok := key.(*rsa.PrivateKey); ok {
+		return priv, nil
+	}
+	return nil, errors.New("jwt: not an RSA private key")
+}
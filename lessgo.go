@@ -9,6 +9,7 @@ package lessgo
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
@@ -16,6 +17,7 @@ import (
 	"sync"
 
 	_ "github.com/henrylee2cn/lessgo/_fixture"
+	confpkg "github.com/henrylee2cn/lessgo/config"
 	"github.com/henrylee2cn/lessgo/logs"
 	"github.com/henrylee2cn/lessgo/session"
 	"github.com/henrylee2cn/lessgo/utils"
@@ -65,7 +67,8 @@ const (
 )
 
 var (
-	// 全局配置实例
+	// 全局配置实例，即启动时加载的配置；
+	// 热重载不修改它，运行时的最新配置见CurrentConfig()
 	Config = func() *config {
 		fmt.Printf("%s\n(%s)\n\n", banner, ADDRESS)
 		c := newConfig()
@@ -85,6 +88,10 @@ var (
 	// 全局运行日志实例(来自数据库的日志除外)
 	Log = func() logs.Logger {
 		l := logs.NewLogger(1000)
+		// 日志中的配置密钥值显示为******
+		if f, ok := l.(logs.Filterer); ok {
+			f.SetFilter(confpkg.MaskSecrets)
+		}
 		l.AddAdapter("console", "")
 		l.AddAdapter("file", `{"filename":"`+LOG_FILE+`"}`)
 		return l
@@ -168,6 +175,16 @@ func SetDebug(on bool) {
 	app.SetDebug(on)
 }
 
+// 返回读取模板及静态文件的文件系统
+func FileSystem() http.FileSystem {
+	return app.FileSystem()
+}
+
+// 设置读取模板及静态文件的文件系统(默认为OSFileSystem)，可用于将静态资源打包进二进制文件
+func SetFileSystem(fs http.FileSystem) {
+	app.SetFileSystem(fs)
+}
+
 // 判断文件缓存是否开启
 func CanMemoryCache() bool {
 	return app.CanMemoryCache()
@@ -234,7 +251,9 @@ func PreUse(middlewares ...interface{}) error {
 	if err != nil {
 		return err
 	}
+	lessgo.lock.Lock()
 	lessgo.virtBefore = append(ms, lessgo.virtBefore...)
+	lessgo.lock.Unlock()
 	return nil
 }
 
@@ -244,7 +263,9 @@ func BeforeUse(middlewares ...interface{}) error {
 	if err != nil {
 		return err
 	}
+	lessgo.lock.Lock()
 	lessgo.virtBefore = append(lessgo.virtBefore, ms...)
+	lessgo.lock.Unlock()
 	return nil
 }
 
@@ -312,7 +333,9 @@ func Static(prefix, root string, middlewares ...interface{}) error {
 
 // 清空用户添加到处理链中路由操作前的所有中间件(子链)
 func ResetBefore() {
+	lessgo.lock.Lock()
 	lessgo.virtBefore = lessgo.virtBefore[:0]
+	lessgo.lock.Unlock()
 	registerBefore()
 }
 
@@ -461,7 +484,11 @@ func ReregisterRouter(reasons ...string) {
 		return
 	}
 
-	if err = isExistMiddlewares(lessgo.virtBefore...); err != nil {
+	lessgo.lock.RLock()
+	virtBefore := append([]*MiddlewareConfig(nil), lessgo.virtBefore...)
+	lessgo.lock.RUnlock()
+
+	if err = isExistMiddlewares(virtBefore...); err != nil {
 		return
 	}
 	if err = isExistMiddlewares(lessgo.virtAfter...); err != nil {
@@ -483,7 +510,7 @@ func ReregisterRouter(reasons ...string) {
 	defer app.resetRouterEnd()
 
 	// Build real router
-	app.beforeUse(getMiddlewareFuncs(lessgo.virtBefore)...)
+	app.beforeUse(getMiddlewareFuncs(virtBefore)...)
 	app.afterUse(getMiddlewareFuncs(lessgo.virtAfter)...)
 
 	group := app.group(
@@ -525,6 +552,17 @@ func Run(graceExitCallback ...func() error) {
 	// 重建路由
 	ReregisterRouter()
 
+	// 监控配置文件变动及SIGHUP信号，热重载配置
+	go watchConfig()
+
+	// 预编译全部模板，并监控模板文件变动
+	if r, ok := app.renderer.(templateEngine); ok {
+		if err := r.Precompile(templateDirs...); err != nil {
+			Log.Error("%v", err)
+		}
+		go r.Watch(templateWatchInterval, templateDirs...)
+	}
+
 	// 开启最大核心数运行
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
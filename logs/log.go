@@ -26,6 +26,13 @@ type (
 		Debug(format string, v ...interface{})
 	}
 
+	// Filterer is implemented by the loggers which can rewrite the messages,
+	// such as the Logger returned by NewLogger.
+	Filterer interface {
+		// SetFilter sets a function to rewrite every message before it is written.
+		SetFilter(filter func(msg string) string)
+	}
+
 	TgLogger struct {
 		*logs.BeeLogger
 	}
@@ -0,0 +1,204 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package logs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// journaldWriter implements LoggerInterface.
+// it writes structured entries to the journal through its native protocol.
+type journaldWriter struct {
+	lock   sync.Mutex
+	conn   *net.UnixConn
+	raddr  *net.UnixAddr
+	fields []byte
+	Addr   string            `json:"addr"`
+	Tag    string            `json:"tag"`
+	Fields map[string]string `json:"fields"`
+	Level  int               `json:"level"`
+}
+
+// NewJournald create journald writer returning as LoggerInterface.
+func NewJournald() Logger {
+	return &journaldWriter{
+		Addr:  "/run/systemd/journal/socket",
+		Level: LevelDebug,
+	}
+}
+
+// Init journald writer with json config.
+// config like:
+//
+//	{
+//		"addr":"/run/systemd/journal/socket",
+//		"tag":"myapp",
+//		"fields":{"APP_VERSION":"1.0"},
+//		"level":LevelInformational
+//	}
+//
+// the names of extra fields must consist of uppercase letters, digits and underscores.
+func (j *journaldWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) > 0 {
+		if err := json.Unmarshal([]byte(jsonConfig), j); err != nil {
+			return err
+		}
+	}
+	if len(j.Tag) == 0 {
+		j.Tag = filepath.Base(os.Args[0])
+	}
+	var buf bytes.Buffer
+	appendJournalField(&buf, "SYSLOG_IDENTIFIER", j.Tag)
+	appendJournalField(&buf, "SYSLOG_PID", strconv.Itoa(os.Getpid()))
+	for k, v := range j.Fields {
+		if !validJournalField(k) {
+			return fmt.Errorf("logs: invalid journald field name %q", k)
+		}
+		appendJournalField(&buf, k, v)
+	}
+	j.fields = buf.Bytes()
+	j.raddr = &net.UnixAddr{Name: j.Addr, Net: "unixgram"}
+	return nil
+}
+
+// WriteMsg write message in journald.
+func (j *journaldWriter) WriteMsg(lm logMsg) error {
+	if lm.level > j.Level {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.Write(j.fields)
+	appendJournalField(&buf, "MESSAGE", strings.TrimRight(lm.msg, "\n"))
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(Severity(lm.level)))
+	appendJournalField(&buf, "LESSGO_LEVEL", strings.Trim(lm.prefix, "[]"))
+	if file, line := splitCodeLine(lm.line); len(file) > 0 {
+		appendJournalField(&buf, "CODE_FILE", file)
+		appendJournalField(&buf, "CODE_LINE", line)
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.conn == nil {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		j.conn = conn
+	}
+	_, err := j.conn.WriteToUnix(buf.Bytes(), j.raddr)
+	if err == nil {
+		return nil
+	}
+	if !isMsgSize(err) {
+		return err
+	}
+	// the entry is too large for a datagram, pass it through a sealed temp file.
+	return j.writeFile(buf.Bytes())
+}
+
+func (j *journaldWriter) writeFile(b []byte) error {
+	f, err := ioutil.TempFile("/dev/shm", "journal.")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	_, _, err = j.conn.WriteMsgUnix(nil, rights, j.raddr)
+	return err
+}
+
+// Flush implementing method. empty.
+func (j *journaldWriter) Flush() {
+
+}
+
+// Destroy close the journal socket.
+func (j *journaldWriter) Destroy() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.conn != nil {
+		j.conn.Close()
+		j.conn = nil
+	}
+}
+
+// appendJournalField writes a field in the journal native protocol,
+// values with newlines are written with an explicit length.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func validJournalField(name string) bool {
+	if len(name) == 0 || name[0] == '_' {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCodeLine splits "[file.go:12]" into file and line.
+func splitCodeLine(s string) (file, line string) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return "", ""
+	}
+	return s[:i], s[i+1:]
+}
+
+func isMsgSize(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+			return sysErr.Err == syscall.EMSGSIZE || sysErr.Err == syscall.ENOBUFS
+		}
+	}
+	return false
+}
+
+func init() {
+	Register("journald", NewJournald)
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package logs
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournald(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "journal.sock")
+	pc, err := net.ListenPacket("unixgram", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	w := NewJournald()
+	if err = w.Init(`{"addr":"` + addr + `","tag":"lessgo","fields":{"APP_VERSION":"1.0"}}`); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Destroy()
+	err = w.WriteMsg(logMsg{level: LevelError, prefix: "[E]", line: "[app.go:12]", msg: "first\nsecond", when: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := string(buf[:n])
+	for _, field := range []string{
+		"SYSLOG_IDENTIFIER=lessgo\n",
+		"APP_VERSION=1.0\n",
+		"PRIORITY=3\n",
+		"CODE_FILE=app.go\n",
+		"CODE_LINE=12\n",
+		"MESSAGE\n\x0c\x00\x00\x00\x00\x00\x00\x00first\nsecond\n",
+	} {
+		if !strings.Contains(entry, field) {
+			t.Fatalf("field %q not found in %q", field, entry)
+		}
+	}
+
+	if err = NewJournald().Init(`{"fields":{"_PRIVATE":"x"}}`); err == nil {
+		t.Fatal("expected an error for a reserved field name")
+	}
+}
@@ -34,13 +34,17 @@
 package logs
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/henrylee2cn/lessgo/config"
 )
 
 // log message levels.
@@ -106,6 +110,7 @@ type BeeLogger struct {
 	signalChan          chan string
 	wg                  sync.WaitGroup
 	outputs             []*nameLogger
+	filter              func(string) string
 }
 
 type nameLogger struct {
@@ -168,8 +173,13 @@ func (bl *BeeLogger) AddAdapter(adapterName string, config string) error {
 		return fmt.Errorf("logs: unknown adaptername %q (forgotten Register?)", adapterName)
 	}
 
+	config, err := resolveConfig(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logs.BeeLogger.AddAdapter: "+err.Error())
+		return err
+	}
 	lg := log()
-	err := lg.Init(config)
+	err = lg.Init(config)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "logs.BeeLogger.AddAdapter: "+err.Error())
 		return err
@@ -197,6 +207,27 @@ func (bl *BeeLogger) DelAdapter(adapterName string) error {
 	return nil
 }
 
+// resolveConfig resolves the ${VAR}, enc: and file: values in the json config of an adapter,
+// so that passwords need not be written in plain text.
+func resolveConfig(jsonConfig string) (string, error) {
+	if !strings.Contains(jsonConfig, "${") &&
+		!strings.Contains(jsonConfig, config.SecretEncPrefix) &&
+		!strings.Contains(jsonConfig, config.SecretFilePrefix) {
+		return jsonConfig, nil
+	}
+	var m map[string]interface{}
+	d := json.NewDecoder(strings.NewReader(jsonConfig))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return "", err
+	}
+	if _, err := config.ResolveValue(m); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
 func (bl *BeeLogger) writeToLoggers(lm *logMsg) {
 	for _, l := range bl.outputs {
 		err := l.WriteMsg(*lm)
@@ -222,10 +253,21 @@ func (bl *BeeLogger) writeMsg(level int, msg string) {
 		_, filename := path.Split(file)
 		lm.line = "[" + filename + ":" + strconv.FormatInt(int64(line), 10) + "]"
 	}
+	if bl.filter != nil {
+		msg = bl.filter(msg)
+	}
 	lm.msg = msg
 	bl.msgChan <- lm
 }
 
+// SetFilter sets a function to rewrite every message before it is written,
+// such as to mask passwords.
+func (bl *BeeLogger) SetFilter(filter func(msg string) string) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.filter = filter
+}
+
 // SetLevel Set log message level.
 // If message level (such as LevelDebug) is higher than logger level (such as LevelWarning),
 // log providers will not even be sent the message.
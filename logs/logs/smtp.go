@@ -15,12 +15,26 @@
 package logs
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"mime"
 	"net"
 	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/utils"
 )
 
 // SMTPWriter implements LoggerInterface and is used to send emails via given SMTP-server.
@@ -32,8 +46,77 @@ type SMTPWriter struct {
 	FromAddress        string   `json:"fromAddress"`
 	RecipientAddresses []string `json:"sendTos"`
 	Level              int      `json:"level"`
+
+	// Template is the body template of an alert email, DigestTemplate is
+	// the body template of a digest email, both in text/template syntax
+	// (html/template when HTML is true).
+	Template       string `json:"template"`
+	DigestTemplate string `json:"digestTemplate"`
+	HTML           bool   `json:"html"`
+	// DedupInterval is the number of seconds during which repeats of a message
+	// with the same fingerprint are counted instead of being sent again.
+	DedupInterval int `json:"dedupInterval"`
+	// RateLimit is the max number of alert emails sent per minute, 0 means unlimited.
+	// Messages over the limit go to the next digest.
+	RateLimit int `json:"rateLimit"`
+	// DigestInterval is the number of seconds between two digest emails.
+	// Defaults to 300 when DedupInterval or RateLimit is set.
+	DigestInterval int `json:"digestInterval"`
+	// AttachStack sends the full text of a multi-line message (such as a stack trace)
+	// as an attachment, leaving only its first line in the body.
+	AttachStack bool `json:"attachStack"`
+
+	lock        sync.Mutex
+	tpl         smtpTemplate
+	digestTpl   smtpTemplate
+	lastSent    map[string]time.Time
+	digest      map[string]*SMTPDigestEntry
+	digestSince time.Time
+	windowStart time.Time
+	windowSent  int
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// SMTPAlert is the data passed to the alert email template.
+type SMTPAlert struct {
+	When        time.Time
+	Level       string
+	Line        string
+	Msg         string
+	Fingerprint string
+}
+
+// SMTPDigestEntry is a group of repeated messages in a digest email.
+type SMTPDigestEntry struct {
+	SMTPAlert
+	Last  time.Time
+	Count int
 }
 
+// SMTPDigest is the data passed to the digest email template.
+type SMTPDigest struct {
+	Since   time.Time
+	Until   time.Time
+	Total   int
+	Entries []*SMTPDigestEntry
+}
+
+type smtpTemplate interface {
+	Execute(io.Writer, interface{}) error
+}
+
+const (
+	defaultSMTPTemplate = `.{{.When.Format "2006-01-02 15:04:05"}}{{.Line}}{{.Level}} {{.Msg}}`
+
+	defaultSMTPDigestTemplate = `{{.Total}} suppressed messages from {{.Since.Format "2006-01-02 15:04:05"}} to {{.Until.Format "2006-01-02 15:04:05"}}:
+{{range .Entries}}
+[x{{.Count}}] {{.When.Format "2006-01-02 15:04:05"}} ~ {{.Last.Format "2006-01-02 15:04:05"}}{{.Line}}{{.Level}} {{.Msg}}
+{{end}}`
+
+	defaultSMTPDigestInterval = 300
+)
+
 // NewSMTPWriter create smtp writer.
 func newSMTPWriter() Logger {
 	return &SMTPWriter{Level: LevelDebug}
@@ -48,16 +131,55 @@ func newSMTPWriter() Logger {
 //		"subject":"email title",
 //		"fromAddress":"from@example.com",
 //		"sendTos":["email1","email2"],
-//		"level":LevelError
+//		"level":LevelError,
+//		"dedupInterval":60,
+//		"rateLimit":10,
+//		"digestInterval":300,
+//		"template":"{{.Level}} {{.Msg}}",
+//		"attachStack":true
 //	}
 func (s *SMTPWriter) Init(jsonconfig string) error {
+	// initialized before: stop the digest loop and send the pending digest with the old settings
+	s.stopDigestLoop()
+	if s.digestTpl != nil {
+		s.sendDigest()
+	}
 	err := json.Unmarshal([]byte(jsonconfig), s)
 	if err != nil {
 		return err
 	}
+	if len(s.Template) == 0 {
+		s.Template = defaultSMTPTemplate
+	}
+	if len(s.DigestTemplate) == 0 {
+		s.DigestTemplate = defaultSMTPDigestTemplate
+	}
+	if s.tpl, err = s.parseTemplate("smtp", s.Template); err != nil {
+		return err
+	}
+	if s.digestTpl, err = s.parseTemplate("smtpDigest", s.DigestTemplate); err != nil {
+		return err
+	}
+	s.lastSent = make(map[string]time.Time)
+	s.digest = make(map[string]*SMTPDigestEntry)
+	if s.DigestInterval <= 0 && (s.DedupInterval > 0 || s.RateLimit > 0) {
+		s.DigestInterval = defaultSMTPDigestInterval
+	}
+	if s.DigestInterval > 0 {
+		s.stop = make(chan struct{})
+		s.wg.Add(1)
+		go s.digestLoop(time.Duration(s.DigestInterval) * time.Second)
+	}
 	return nil
 }
 
+func (s *SMTPWriter) parseTemplate(name, text string) (smtpTemplate, error) {
+	if s.HTML {
+		return htmltemplate.New(name).Parse(text)
+	}
+	return template.New(name).Parse(text)
+}
+
 func (s *SMTPWriter) getSMTPAuth(host string) smtp.Auth {
 	if len(strings.Trim(s.Username, " ")) == 0 && len(strings.Trim(s.Password, " ")) == 0 {
 		return nil
@@ -75,14 +197,17 @@ func (s *SMTPWriter) sendMail(hostAddressWithPort string, auth smtp.Auth, fromAd
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
 	host, _, _ := net.SplitHostPort(hostAddressWithPort)
-	tlsConn := &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         host,
-	}
-	if err = client.StartTLS(tlsConn); err != nil {
-		return err
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConn := &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         host,
+		}
+		if err = client.StartTLS(tlsConn); err != nil {
+			return err
+		}
 	}
 
 	if auth != nil {
@@ -123,25 +248,196 @@ func (s *SMTPWriter) sendMail(hostAddressWithPort string, auth smtp.Auth, fromAd
 	return nil
 }
 
+// send renders the body with tpl and sends it through utils.Email.
+func (s *SMTPWriter) send(subject string, tpl smtpTemplate, data interface{}, attachment string) error {
+	var body bytes.Buffer
+	if err := tpl.Execute(&body, data); err != nil {
+		return err
+	}
+	email := &utils.Email{
+		From:    s.FromAddress,
+		To:      s.RecipientAddresses,
+		Subject: mime.QEncoding.Encode("utf-8", subject),
+		Headers: textproto.MIMEHeader{},
+	}
+	if s.HTML {
+		email.HTML = body.String()
+	} else {
+		email.Text = body.String()
+	}
+	if len(attachment) > 0 {
+		if _, err := email.Attach(strings.NewReader(attachment), "message.txt", "text/plain; charset=UTF-8"); err != nil {
+			return err
+		}
+	}
+	mailmsg, err := email.Bytes()
+	if err != nil {
+		return err
+	}
+
+	hp := strings.Split(s.Host, ":")
+
+	// Set up authentication information.
+	auth := s.getSMTPAuth(hp[0])
+
+	return s.sendMail(s.Host, auth, s.FromAddress, s.RecipientAddresses, mailmsg)
+}
+
 // WriteMsg write message in smtp writer.
-// it will send an email with subject and only this message.
+// it will send an email with subject and only this message,
+// unless the message is a repeat or the rate limit is reached,
+// in which case it is counted in the next digest email.
 func (s *SMTPWriter) WriteMsg(lm logMsg) error {
 	if lm.level > s.Level {
 		return nil
 	}
+	if s.tpl == nil {
+		// Init was not called with a valid config.
+		return errors.New("logs: smtp writer is not initialized")
+	}
 
-	hp := strings.Split(s.Host, ":")
+	alert := SMTPAlert{
+		When:        lm.when,
+		Level:       lm.prefix,
+		Line:        lm.line,
+		Msg:         lm.msg,
+		Fingerprint: fingerprint(&lm),
+	}
 
-	// Set up authentication information.
-	auth := s.getSMTPAuth(hp[0])
+	s.lock.Lock()
+	if s.suppress(&alert) {
+		s.lock.Unlock()
+		return nil
+	}
+	s.lock.Unlock()
 
-	// Connect to the server, authenticate, set the sender and recipient,
-	// and send the email all in one step.
-	contentType := "Content-Type: text/plain" + "; charset=UTF-8"
-	mailmsg := []byte("To: " + strings.Join(s.RecipientAddresses, ";") + "\r\nFrom: " + s.FromAddress + "<" + s.FromAddress +
-		">\r\nSubject: " + s.Subject + "\r\n" + contentType + "\r\n\r\n" + fmt.Sprintf(".%s", lm.when.Format("2006-01-02 15:04:05")) + lm.line + lm.prefix + " " + lm.msg)
+	var attachment string
+	if s.AttachStack {
+		if i := strings.IndexByte(alert.Msg, '\n'); i >= 0 {
+			attachment = alert.Msg
+			alert.Msg = alert.Msg[:i]
+		}
+	}
+	return s.send(s.Subject, s.tpl, &alert, attachment)
+}
 
-	return s.sendMail(s.Host, auth, s.FromAddress, s.RecipientAddresses, mailmsg)
+// suppress reports whether the alert should go to the digest instead of being sent,
+// and otherwise records it as sent. It must be called with s.lock held.
+func (s *SMTPWriter) suppress(alert *SMTPAlert) bool {
+	if s.DedupInterval > 0 {
+		last, ok := s.lastSent[alert.Fingerprint]
+		if ok && alert.When.Sub(last) < time.Duration(s.DedupInterval)*time.Second {
+			s.addDigest(alert)
+			return true
+		}
+	}
+	if s.RateLimit > 0 {
+		if alert.When.Sub(s.windowStart) >= time.Minute {
+			s.windowStart = alert.When
+			s.windowSent = 0
+		}
+		if s.windowSent >= s.RateLimit {
+			s.addDigest(alert)
+			return true
+		}
+		s.windowSent++
+	}
+	if s.DedupInterval > 0 {
+		s.lastSent[alert.Fingerprint] = alert.When
+	}
+	return false
+}
+
+func (s *SMTPWriter) addDigest(alert *SMTPAlert) {
+	if s.digestSince.IsZero() {
+		s.digestSince = alert.When
+	}
+	if e, ok := s.digest[alert.Fingerprint]; ok {
+		e.Count++
+		e.Last = alert.When
+		return
+	}
+	s.digest[alert.Fingerprint] = &SMTPDigestEntry{
+		SMTPAlert: *alert,
+		Last:      alert.When,
+		Count:     1,
+	}
+}
+
+// sendDigest sends the pending digest, if any.
+func (s *SMTPWriter) sendDigest() error {
+	s.lock.Lock()
+	if len(s.digest) == 0 {
+		s.lock.Unlock()
+		return nil
+	}
+	d := &SMTPDigest{
+		Since:   s.digestSince,
+		Until:   time.Now(),
+		Entries: make([]*SMTPDigestEntry, 0, len(s.digest)),
+	}
+	for _, e := range s.digest {
+		d.Total += e.Count
+		d.Entries = append(d.Entries, e)
+	}
+	s.digest = make(map[string]*SMTPDigestEntry)
+	s.digestSince = time.Time{}
+	// forget old fingerprints so that the map does not grow forever.
+	for k, t := range s.lastSent {
+		if d.Until.Sub(t) >= time.Duration(s.DedupInterval)*time.Second {
+			delete(s.lastSent, k)
+		}
+	}
+	s.lock.Unlock()
+
+	sort.Sort(digestEntries(d.Entries))
+	return s.send(s.Subject+" [digest: "+strconv.Itoa(d.Total)+" messages]", s.digestTpl, d, "")
+}
+
+func (s *SMTPWriter) digestLoop(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sendDigest()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// fingerprint identifies messages that differ only in numbers, such as ids or durations.
+func fingerprint(lm *logMsg) string {
+	h := sha1.New()
+	io.WriteString(h, lm.prefix)
+	io.WriteString(h, lm.line)
+	digit := false
+	for _, r := range lm.msg {
+		if r >= '0' && r <= '9' {
+			if !digit {
+				h.Write([]byte{'#'})
+			}
+			digit = true
+			continue
+		}
+		digit = false
+		io.WriteString(h, string(r))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// digestEntries sorts the digest by count, the most repeated first.
+type digestEntries []*SMTPDigestEntry
+
+func (d digestEntries) Len() int      { return len(d) }
+func (d digestEntries) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d digestEntries) Less(i, j int) bool {
+	if d[i].Count != d[j].Count {
+		return d[i].Count > d[j].Count
+	}
+	return d[i].When.Before(d[j].When)
 }
 
 // Flush implementing method. empty.
@@ -149,9 +445,21 @@ func (s *SMTPWriter) Flush() {
 	return
 }
 
-// Destroy implementing method. empty.
+// stopDigestLoop stops the digest loop if it is running.
+func (s *SMTPWriter) stopDigestLoop() {
+	if s.stop != nil {
+		close(s.stop)
+		s.wg.Wait()
+		s.stop = nil
+	}
+}
+
+// Destroy stops the digest loop and sends the pending digest.
 func (s *SMTPWriter) Destroy() {
-	return
+	s.stopDigestLoop()
+	if s.digestTpl != nil {
+		s.sendDigest()
+	}
 }
 
 func init() {
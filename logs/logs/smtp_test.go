@@ -15,6 +15,10 @@
 package logs
 
 import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,3 +29,134 @@ func TestSmtp(t *testing.T) {
 	log.Critical("sendmail critical")
 	time.Sleep(time.Second * 30)
 }
+
+// smtpStandIn is a minimal SMTP server which collects the DATA of received mails.
+func smtpStandIn(t *testing.T) (addr string, mails chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails = make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				tc := textproto.NewConn(conn)
+				tc.PrintfLine("220 localhost ESMTP")
+				for {
+					line, err := tc.ReadLine()
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+					case "EHLO", "HELO":
+						tc.PrintfLine("250 localhost")
+					case "DATA":
+						tc.PrintfLine("354 go ahead")
+						b, _ := tc.ReadDotBytes()
+						mails <- string(b)
+						tc.PrintfLine("250 ok")
+					case "QUIT":
+						tc.PrintfLine("221 bye")
+						return
+					default:
+						tc.PrintfLine("250 ok")
+					}
+				}
+			}(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String(), mails
+}
+
+func TestSmtpDedupAndDigest(t *testing.T) {
+	addr, mails := smtpStandIn(t)
+	w := newSMTPWriter().(*SMTPWriter)
+	err := w.Init(`{"host":"` + addr + `","subject":"alert","fromAddress":"from@example.com","sendTos":["to@example.com"],` +
+		`"dedupInterval":60,"digestInterval":3600,"template":"ALERT {{.Msg}}","attachStack":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		err = w.WriteMsg(logMsg{level: LevelError, prefix: "[E]", msg: fmt.Sprintf("db timeout after %dms\nstack line", i), when: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mail := <-mails
+	if !strings.Contains(mail, "ALERT db timeout after 0ms") {
+		t.Fatalf("unexpected alert body: %s", mail)
+	}
+	if !strings.Contains(mail, "message.txt") {
+		t.Fatalf("expected the stack to be attached: %s", mail)
+	}
+	select {
+	case mail = <-mails:
+		t.Fatalf("repeated message should not be sent: %s", mail)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	w.Destroy()
+	mail = <-mails
+	if !strings.Contains(mail, "[x4]") || !strings.Contains(mail, "[digest: 4 messages]") {
+		t.Fatalf("unexpected digest: %s", mail)
+	}
+}
+
+func TestSmtpRateLimit(t *testing.T) {
+	addr, mails := smtpStandIn(t)
+	w := newSMTPWriter().(*SMTPWriter)
+	err := w.Init(`{"host":"` + addr + `","subject":"alert","fromAddress":"from@example.com","sendTos":["to@example.com"],"rateLimit":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		w.WriteMsg(logMsg{level: LevelError, prefix: "[E]", msg: fmt.Sprintf("error %c", 'a'+i), when: time.Now()})
+	}
+	if len(mails) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(mails))
+	}
+	w.Destroy()
+	<-mails
+	<-mails
+	mail := <-mails
+	if !strings.Contains(mail, "error c") || !strings.Contains(mail, "error d") {
+		t.Fatalf("unexpected digest: %s", mail)
+	}
+}
+
+func TestSmtpReinit(t *testing.T) {
+	addr, mails := smtpStandIn(t)
+	w := newSMTPWriter().(*SMTPWriter)
+	config := `{"host":"` + addr + `","subject":"alert","fromAddress":"from@example.com","sendTos":["to@example.com"],"dedupInterval":60}`
+	if err := w.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		w.WriteMsg(logMsg{level: LevelError, prefix: "[E]", msg: "repeated", when: time.Now()})
+	}
+	<-mails
+	if err := w.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	if mail := <-mails; !strings.Contains(mail, "[digest: 1 messages]") {
+		t.Fatalf("the pending digest must be sent on re-init: %s", mail)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.Destroy()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Destroy blocks: the digest loop of the first Init is still running")
+	}
+}
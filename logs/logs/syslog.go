@@ -0,0 +1,234 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// syslog facilities, see RFC 5424 section 6.2.1.
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// severities maps lessgo levels to syslog severities, see RFC 5424 section 6.2.1.
+var severities = [...]int{
+	LevelSystem:        6,
+	LevelFatal:         0,
+	LevelEmergency:     0,
+	LevelAlert:         1,
+	LevelCritical:      2,
+	LevelError:         3,
+	LevelWarning:       4,
+	LevelNotice:        5,
+	LevelInformational: 6,
+	LevelDebug:         7,
+}
+
+// Severity returns the syslog severity of a lessgo log level.
+func Severity(level int) int {
+	if level < 0 || level >= len(severities) {
+		return 7
+	}
+	return severities[level]
+}
+
+// syslogWriter implements LoggerInterface.
+// it writes RFC 5424 messages to the system logger through a unix socket, udp or tcp.
+type syslogWriter struct {
+	lock     sync.Mutex
+	conn     net.Conn
+	hostname string
+	pid      string
+	priority int
+	Net      string `json:"net"`
+	Addr     string `json:"addr"`
+	Facility string `json:"facility"`
+	Tag      string `json:"tag"`
+	Level    int    `json:"level"`
+}
+
+// NewSyslog create syslog writer returning as LoggerInterface.
+func NewSyslog() Logger {
+	return &syslogWriter{
+		Net:      "unix",
+		Addr:     "/dev/log",
+		Facility: "user",
+		Level:    LevelDebug,
+	}
+}
+
+// Init syslog writer with json config.
+// config like:
+//
+//	{
+//		"net":"udp",
+//		"addr":"127.0.0.1:514",
+//		"facility":"local0",
+//		"tag":"myapp",
+//		"level":LevelInformational
+//	}
+//
+// net is one of unix, unixgram, udp and tcp. unix tries the datagram socket
+// first, then the stream socket, whose messages end with a newline.
+func (s *syslogWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) > 0 {
+		if err := json.Unmarshal([]byte(jsonConfig), s); err != nil {
+			return err
+		}
+	}
+	facility, ok := facilities[strings.ToLower(s.Facility)]
+	if !ok {
+		return fmt.Errorf("logs: unknown syslog facility %q", s.Facility)
+	}
+	s.priority = facility * 8
+	if len(s.Tag) == 0 {
+		s.Tag = filepath.Base(os.Args[0])
+	}
+	s.hostname, _ = os.Hostname()
+	if len(s.hostname) == 0 {
+		s.hostname = "-"
+	}
+	s.pid = strconv.Itoa(os.Getpid())
+	return nil
+}
+
+// WriteMsg write message in syslog.
+// if connection is down, try to re-connect once.
+func (s *syslogWriter) WriteMsg(lm logMsg) error {
+	if lm.level > s.Level {
+		return nil
+	}
+	b := s.format(&lm)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var err error
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		if _, err = s.conn.Write(frame(s.conn, b)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// format builds a RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (s *syslogWriter) format(lm *logMsg) []byte {
+	msg := lm.line + lm.prefix + " " + lm.msg
+	msg = strings.TrimRight(msg, "\n")
+	b := make([]byte, 0, 64+len(s.hostname)+len(s.Tag)+len(msg))
+	b = append(b, '<')
+	b = strconv.AppendInt(b, int64(s.priority+Severity(lm.level)), 10)
+	b = append(b, ">1 "...)
+	b = lm.when.AppendFormat(b, "2006-01-02T15:04:05.000000Z07:00")
+	b = append(b, ' ')
+	b = append(b, s.hostname...)
+	b = append(b, ' ')
+	b = append(b, s.Tag...)
+	b = append(b, ' ')
+	b = append(b, s.pid...)
+	b = append(b, " - - "...)
+	b = append(b, msg...)
+	return b
+}
+
+// frame delimits the message on a stream connection, the messages of a
+// datagram connection are not framed.
+func frame(conn net.Conn, b []byte) []byte {
+	switch conn.RemoteAddr().Network() {
+	case "tcp", "tcp4", "tcp6":
+		// octet-counting framing, see RFC 6587 section 3.4.1.
+		return append(strconv.AppendInt(nil, int64(len(b)), 10), append([]byte{' '}, b...)...)
+	case "unix":
+		// the local system loggers split a stream socket at the newlines,
+		// see RFC 6587 section 3.4.2.
+		return append(b[:len(b):len(b)], '\n')
+	}
+	return b
+}
+
+func (s *syslogWriter) connect() error {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.Net == "unix" {
+		// the system logger usually listens on a datagram socket.
+		conn, err = net.Dial("unixgram", s.Addr)
+		if err != nil {
+			conn, err = net.Dial("unix", s.Addr)
+		}
+	} else {
+		conn, err = net.Dial(s.Net, s.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Flush implementing method. empty.
+func (s *syslogWriter) Flush() {
+
+}
+
+// Destroy close the connection to the system logger.
+func (s *syslogWriter) Destroy() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func init() {
+	Register("syslog", NewSyslog)
+}
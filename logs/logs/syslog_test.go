@@ -0,0 +1,158 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	log := NewLogger(10)
+	err = log.AddAdapter("syslog", `{"net":"udp","addr":"`+pc.LocalAddr().String()+`","facility":"local0","tag":"lessgo"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Error("udp error")
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	// local0(16)*8 + error(3)
+	if !strings.HasPrefix(msg, "<131>1 ") {
+		t.Fatalf("unexpected priority: %s", msg)
+	}
+	if !strings.Contains(msg, " lessgo ") || !strings.HasSuffix(msg, "[E] udp error") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+	log.Close()
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w := NewSyslog()
+	if err = w.Init(`{"net":"tcp","addr":"` + ln.Addr().String() + `","facility":"daemon"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Destroy()
+	go w.WriteMsg(logMsg{level: LevelWarning, prefix: "[W]", msg: "tcp warning", when: time.Now()})
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, n)
+	if _, err = io.ReadFull(r, frame); err != nil {
+		t.Fatal(err)
+	}
+	// daemon(3)*8 + warning(4)
+	if !strings.HasPrefix(string(frame), "<28>1 ") || !strings.HasSuffix(string(frame), "tcp warning") {
+		t.Fatalf("unexpected frame: %s", frame)
+	}
+}
+
+func TestSyslogUnix(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	pc, err := net.ListenPacket("unixgram", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	w := NewSyslog()
+	if err = w.Init(`{"addr":"` + addr + `"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Destroy()
+	if err = w.WriteMsg(logMsg{level: LevelDebug, prefix: "[D]", msg: "unix debug", when: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<15>1 ") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+func TestSyslogUnixStream(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	// the datagram socket is tried first, then the stream socket
+	w := NewSyslog()
+	if err = w.Init(`{"addr":"` + addr + `"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Destroy()
+	go func() {
+		w.WriteMsg(logMsg{level: LevelInformational, prefix: "[I]", msg: "first", when: time.Now()})
+		w.WriteMsg(logMsg{level: LevelInformational, prefix: "[I]", msg: "second", when: time.Now()})
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"first", "second"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "<14>1 ") || !strings.HasSuffix(line, "[I] "+want+"\n") {
+			t.Fatalf("unexpected message: %q", line)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/henrylee2cn/lessgo/config/yaml/goyaml2"
+)
+
+var frontMatterDelim = []byte("---")
+
+// SplitFrontMatter separates the YAML front matter from the markdown body.
+// The front matter is a block at the very beginning of the input, between
+// two lines of "---":
+//
+//	---
+//	title: Getting Started
+//	layout: doc/layout.tpl
+//	---
+//	# Getting Started
+//
+// meta is nil when there is no front matter.
+func SplitFrontMatter(in []byte) (meta map[string]interface{}, body []byte, err error) {
+	rest := bytes.TrimPrefix(in, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	line, rest := splitLine(rest)
+	if !bytes.Equal(bytes.TrimRight(line, " \t\r"), frontMatterDelim) {
+		return nil, in, nil
+	}
+	start := len(in) - len(rest)
+	for len(rest) > 0 {
+		end := len(in) - len(rest)
+		line, rest = splitLine(rest)
+		if !bytes.Equal(bytes.TrimRight(line, " \t\r"), frontMatterDelim) {
+			continue
+		}
+		meta = make(map[string]interface{})
+		if data := in[start:end]; len(bytes.TrimSpace(data)) > 0 {
+			tree, err := goyaml2.Read(bytes.NewReader(data))
+			if err != nil {
+				return nil, in, fmt.Errorf("markdown: front matter: %v", err)
+			}
+			m, ok := tree.(map[string]interface{})
+			if !ok {
+				return nil, in, fmt.Errorf("markdown: front matter must be a mapping")
+			}
+			meta = m
+		}
+		return meta, rest, nil
+	}
+	// not closed, so it is not front matter
+	return nil, in, nil
+}
+
+// splitLine returns the first line without "\n" and the rest of b.
+func splitLine(b []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
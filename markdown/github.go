@@ -1,6 +1,7 @@
 package markdown
 
 import (
+	"bytes"
 	"io"
 	"text/template"
 )
@@ -8,7 +9,7 @@ import (
 const tpl = `
 <html>
 <head>
-<style>
+<style{{with .nonce}} nonce="{{.}}"{{end}}>
 {{.css}}
 </style>
 </head>
@@ -22,6 +23,8 @@ const tpl = `
 </html>
 `
 
+var pageTemplate = template.Must(template.New("markdown").Parse(tpl))
+
 const (
 	githubCommonHTMLFlags = 0 |
 		HTML_USE_XHTML |
@@ -37,22 +40,71 @@ const (
 		EXTENSION_STRIKETHROUGH |
 		EXTENSION_SPACE_HEADERS |
 		EXTENSION_HEADER_IDS |
+		EXTENSION_AUTO_HEADER_IDS |
 		EXTENSION_BACKSLASH_LINE_BREAK |
 		EXTENSION_DEFINITION_LISTS
 )
 
-func GithubMarkdown(in []byte, out io.Writer, hasCatalog bool) error {
+// GithubOptions are the options of GithubBody.
+type GithubOptions struct {
+	// Generate a table of contents.
+	Catalog bool
+	// Writes the HTML of the fenced code blocks, e.g. Highlight.
+	// Code blocks are only escaped when it is nil.
+	Highlight func(out *bytes.Buffer, code []byte, lang string) bool
+	// Sanitizes the rendered HTML, e.g. UGCPolicy() for user-contributed content.
+	// Raw HTML in the markdown is passed through when it is nil.
+	Sanitize *Policy
+}
+
+// GithubCSS is the stylesheet of the github style page, including HighlightCSS.
+const GithubCSS = css + HighlightCSS
+
+// GithubBody renders the markdown into the HTML of the github style page body.
+// Headers get stable IDs generated from their text, e.g. "getting-started",
+// unless they have an explicit one ("# Title {#id}").
+func GithubBody(in []byte, opts GithubOptions) []byte {
 	flg := githubCommonHTMLFlags
-	if hasCatalog {
+	if opts.Catalog {
 		flg |= HTML_TOC
 	}
-	render := HtmlRenderer(flg, "", css)
+	render := HtmlRendererWithParameters(flg, "", css, HtmlRendererParameters{
+		Highlight: opts.Highlight,
+	})
 	body := MarkdownOptions(in, render, Options{
 		Extensions: githubCommonExtensions,
 	})
+	if opts.Sanitize != nil {
+		body = opts.Sanitize.Sanitize(body)
+	}
+	return body
+}
+
+// GithubPage writes the github style page around the body rendered by GithubBody.
+func GithubPage(out io.Writer, body []byte) error {
+	return GithubPageNonce(out, body, "")
+}
+
+// GithubPageNonce is like GithubPage, but the <style> element has the nonce
+// of the Content-Security-Policy if it is not empty.
+func GithubPageNonce(out io.Writer, body []byte, nonce string) error {
 	m := map[string]interface{}{
-		"css":  css,
-		"body": string(body),
+		"css":   GithubCSS,
+		"body":  string(body),
+		"nonce": nonce,
 	}
-	return template.Must(template.New("markdown").Parse(tpl)).Execute(out, m)
+	return pageTemplate.Execute(out, m)
+}
+
+// GithubMarkdown renders the markdown into a github style page with highlighted code.
+func GithubMarkdown(in []byte, out io.Writer, hasCatalog bool) error {
+	return GithubMarkdownOptions(in, out, GithubOptions{
+		Catalog:   hasCatalog,
+		Highlight: Highlight,
+	})
+}
+
+// GithubMarkdownOptions renders the markdown into a github style page with the options.
+func GithubMarkdownOptions(in []byte, out io.Writer, opts GithubOptions) error {
+	return GithubPage(out, GithubBody(in, opts))
 }
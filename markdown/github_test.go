@@ -0,0 +1,107 @@
+//
+// Unit tests for the github style page
+//
+
+package markdown
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	meta, body, err := SplitFrontMatter([]byte("---\ntitle: Hello\nlayout: doc.tpl\n---\n# Hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["title"] != "Hello" || meta["layout"] != "doc.tpl" {
+		t.Errorf("unexpected meta: %#v", meta)
+	}
+	if string(body) != "# Hello\n" {
+		t.Errorf("unexpected body: %q", body)
+	}
+
+	for _, input := range []string{
+		"# Hello\n---\n",
+		"---\ntitle: not closed\n",
+		"text\n---\ntitle: Hello\n---\n",
+	} {
+		meta, body, err = SplitFrontMatter([]byte(input))
+		if err != nil || meta != nil || string(body) != input {
+			t.Errorf("%q: expected no front matter, got %#v, %q, %v", input, meta, body, err)
+		}
+	}
+
+	meta, body, err = SplitFrontMatter([]byte("---\n---\ntext"))
+	if err != nil || meta == nil || len(meta) != 0 || string(body) != "text" {
+		t.Errorf("expected empty front matter, got %#v, %q, %v", meta, body, err)
+	}
+}
+
+func TestGithubBodyHeaderIDs(t *testing.T) {
+	body := string(GithubBody([]byte("# Getting Started\n\n## Install {#setup}\n\n# Getting Started\n"), GithubOptions{Catalog: true}))
+	for _, want := range []string{
+		`<h1 id="getting-started">`,
+		`<h2 id="setup">`,
+		`<h1 id="getting-started-1">`,
+		`<a href="#getting-started">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var tests = []string{
+		"func main() { return \"a<b\" } // done",
+		`<span class="hl-k">func</span> main() { <span class="hl-k">return</span> <span class="hl-s">&quot;a&lt;b&quot;</span> } <span class="hl-c">// done</span>`,
+
+		"x = None # 42",
+		`x = <span class="hl-l">None</span> <span class="hl-c"># 42</span>`,
+
+		"SELECT 1.5 FROM t2",
+		`<span class="hl-k">SELECT</span> <span class="hl-n">1.5</span> <span class="hl-k">FROM</span> t2`,
+	}
+	langs := []string{"go", "python", "sql"}
+	for i, lang := range langs {
+		var out bytes.Buffer
+		if !Highlight(&out, []byte(tests[2*i]), lang) {
+			t.Fatalf("%s is not supported", lang)
+		}
+		if out.String() != tests[2*i+1] {
+			t.Errorf("%s:\nexpected %s\n     got %s", lang, tests[2*i+1], out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if Highlight(&out, []byte("x"), "brainfuck") || out.Len() > 0 {
+		t.Error("expected unsupported language to be rejected")
+	}
+
+	body := string(GithubBody([]byte("```go\nvar x\n```\n\n```\nvar x\n```\n"), GithubOptions{Highlight: Highlight}))
+	if !strings.Contains(body, `<code class="language-go"><span class="hl-k">var</span> x`) {
+		t.Errorf("expected highlighted code in:\n%s", body)
+	}
+	if !strings.Contains(body, "<pre><code>var x\n</code></pre>") {
+		t.Errorf("expected plain code in:\n%s", body)
+	}
+}
+
+func TestGithubPageNonce(t *testing.T) {
+	var out bytes.Buffer
+	if err := GithubPageNonce(&out, []byte("<p>hi</p>"), "abc+/="); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `<style nonce="abc+/=">`) {
+		t.Errorf("the style element has no nonce:\n%s", out.String())
+	}
+	out.Reset()
+	if err := GithubPage(&out, []byte("<p>hi</p>")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "<style>\n") {
+		t.Errorf("unexpected style element:\n%s", out.String())
+	}
+}
@@ -0,0 +1,212 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Classes of the highlighted tokens, styled by HighlightCSS.
+const (
+	hlKeyword = "hl-k"
+	hlString  = "hl-s"
+	hlComment = "hl-c"
+	hlNumber  = "hl-n"
+	hlLiteral = "hl-l" // true, false, nil and the like
+)
+
+// HighlightCSS styles the code highlighted by Highlight.
+const HighlightCSS = `
+.markdown-body .hl-k { color: #a71d5d; }
+.markdown-body .hl-s { color: #183691; }
+.markdown-body .hl-c { color: #969896; font-style: italic; }
+.markdown-body .hl-n { color: #0086b3; }
+.markdown-body .hl-l { color: #0086b3; }
+`
+
+type highlightLang struct {
+	keywords     map[string]bool
+	literals     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+}
+
+var highlightLangs = make(map[string]*highlightLang)
+
+func registerHighlightLang(names string, l *highlightLang) {
+	for _, name := range strings.Fields(names) {
+		highlightLangs[name] = l
+	}
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+func init() {
+	registerHighlightLang("go golang", &highlightLang{
+		keywords: wordSet(`break case chan const continue default defer else fallthrough for func go goto
+			if import interface map package range return select struct switch type var`),
+		literals:     wordSet("true false nil iota"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	})
+	registerHighlightLang("js javascript ts typescript json", &highlightLang{
+		keywords: wordSet(`async await break case catch class const continue debugger default delete do
+			else export extends finally for from function if import in instanceof let new of return
+			static super switch this throw try typeof var void while with yield interface type`),
+		literals:     wordSet("true false null undefined NaN Infinity"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	})
+	registerHighlightLang("python py", &highlightLang{
+		keywords: wordSet(`and as assert async await break class continue def del elif else except
+			finally for from global if import in is lambda nonlocal not or pass raise return try while with yield`),
+		literals:     wordSet("True False None"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	})
+	registerHighlightLang("sh bash shell console", &highlightLang{
+		keywords: wordSet(`if then else elif fi case esac for while until do done in function
+			return export local echo cd exit`),
+		literals:     wordSet("true false"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	})
+	registerHighlightLang("sql mysql postgres postgresql sqlite", &highlightLang{
+		keywords: wordSet(`select from where and or not insert into values update set delete create table
+			drop alter add index primary key foreign references join left right inner outer on as
+			group by order having limit offset union all distinct exists in is like between case
+			when then else end default unique SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET
+			DELETE CREATE TABLE DROP ALTER ADD INDEX PRIMARY KEY FOREIGN REFERENCES JOIN LEFT RIGHT
+			INNER OUTER ON AS GROUP BY ORDER HAVING LIMIT OFFSET UNION ALL DISTINCT EXISTS IN IS LIKE
+			BETWEEN CASE WHEN THEN ELSE END DEFAULT UNIQUE`),
+		literals:     wordSet("null true false NULL TRUE FALSE"),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+	})
+	registerHighlightLang("c cpp c++ java cs csharp", &highlightLang{
+		keywords: wordSet(`auto break case catch char class const continue default delete do double else
+			enum extends final finally float for goto if implements import int long namespace new
+			package private protected public return short signed sizeof static struct switch template
+			this throw try typedef union unsigned using virtual void volatile while bool byte string var`),
+		literals:     wordSet("true false null NULL nullptr"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	})
+	registerHighlightLang("yaml yml toml ini", &highlightLang{
+		literals:     wordSet("true false null yes no on off"),
+		lineComments: []string{"#", ";"},
+		quotes:       "\"'",
+	})
+}
+
+// Highlight writes the code with the tokens wrapped in <span class="hl-*">,
+// returns false without writing anything if the language is not supported.
+// Supported languages: go, js/ts/json, python, sh, sql, c/cpp/java/cs, yaml/toml/ini.
+func Highlight(out *bytes.Buffer, code []byte, lang string) bool {
+	l, ok := highlightLangs[strings.ToLower(lang)]
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(code); {
+		if n := l.comment(code[i:]); n > 0 {
+			writeSpan(out, hlComment, code[i:i+n])
+			i += n
+			continue
+		}
+		c := code[i]
+		switch {
+		case strings.IndexByte(l.quotes, c) >= 0:
+			n := quotedLen(code[i:])
+			writeSpan(out, hlString, code[i:i+n])
+			i += n
+		case isdigit(c) && (i == 0 || !isWordByte(code[i-1])):
+			n := 1
+			for n < len(code[i:]) && (isWordByte(code[i+n]) || code[i+n] == '.') {
+				n++
+			}
+			writeSpan(out, hlNumber, code[i:i+n])
+			i += n
+		case isWordByte(c):
+			n := 1
+			for n < len(code[i:]) && isWordByte(code[i+n]) {
+				n++
+			}
+			word := string(code[i : i+n])
+			switch {
+			case l.keywords[word]:
+				writeSpan(out, hlKeyword, code[i:i+n])
+			case l.literals[word]:
+				writeSpan(out, hlLiteral, code[i:i+n])
+			default:
+				attrEscape(out, code[i:i+n])
+			}
+			i += n
+		default:
+			attrEscape(out, code[i:i+1])
+			i++
+		}
+	}
+	return true
+}
+
+// comment returns the length of the comment at the beginning of code, or 0.
+func (l *highlightLang) comment(code []byte) int {
+	for _, prefix := range l.lineComments {
+		if bytes.HasPrefix(code, []byte(prefix)) {
+			if n := bytes.IndexByte(code, '\n'); n >= 0 {
+				return n
+			}
+			return len(code)
+		}
+	}
+	if start := l.blockComment[0]; len(start) > 0 && bytes.HasPrefix(code, []byte(start)) {
+		if n := bytes.Index(code[len(start):], []byte(l.blockComment[1])); n >= 0 {
+			return len(start) + n + len(l.blockComment[1])
+		}
+		return len(code)
+	}
+	return 0
+}
+
+// quotedLen returns the length of the quoted string at the beginning of code,
+// which ends with the same quote, the end of the line or the end of the code.
+func quotedLen(code []byte) int {
+	quote := code[0]
+	for n := 1; n < len(code); n++ {
+		switch code[n] {
+		case '\\':
+			if quote != '`' {
+				n++
+			}
+		case '\n':
+			if quote != '`' {
+				return n
+			}
+		case quote:
+			return n + 1
+		}
+	}
+	return len(code)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isdigit(c) || c >= 0x80
+}
+
+func writeSpan(out *bytes.Buffer, class string, text []byte) {
+	out.WriteString(`<span class="`)
+	out.WriteString(class)
+	out.WriteString(`">`)
+	attrEscape(out, text)
+	out.WriteString("</span>")
+}
@@ -70,6 +70,9 @@ type HtmlRendererParameters struct {
 	HeaderIDPrefix string
 	// If set, add this text to the back of each Header ID, to ensure uniqueness.
 	HeaderIDSuffix string
+	// If set, it writes the HTML of the fenced code blocks with a language,
+	// e.g. Highlight. It returns false to fall back to the escaped code.
+	Highlight func(out *bytes.Buffer, code []byte, lang string) bool
 }
 
 // Html is a type that implements the Renderer interface for HTML output.
@@ -259,6 +262,7 @@ func (options *Html) BlockCode(out *bytes.Buffer, text []byte, lang string) {
 
 	// parse out the language names/classes
 	count := 0
+	first := ""
 	for _, elt := range strings.Fields(lang) {
 		if elt[0] == '.' {
 			elt = elt[1:]
@@ -267,6 +271,7 @@ func (options *Html) BlockCode(out *bytes.Buffer, text []byte, lang string) {
 			continue
 		}
 		if count == 0 {
+			first = elt
 			out.WriteString("<pre><code class=\"language-")
 		} else {
 			out.WriteByte(' ')
@@ -281,7 +286,9 @@ func (options *Html) BlockCode(out *bytes.Buffer, text []byte, lang string) {
 		out.WriteString("\">")
 	}
 
-	attrEscape(out, text)
+	if count == 0 || options.parameters.Highlight == nil || !options.parameters.Highlight(out, text, first) {
+		attrEscape(out, text)
+	}
 	out.WriteString("</code></pre>\n")
 }
 
@@ -0,0 +1,405 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"html"
+	"sort"
+	"strings"
+)
+
+// Policy is an allowlist of HTML elements, attributes and URL schemes, used
+// to sanitize untrusted HTML such as the markdown of user-contributed content:
+//
+//	p := markdown.NewPolicy().
+//		AllowElements("p", "em", "strong").
+//		AllowAttrs("a", "href", "title").
+//		AllowURLSchemes("http", "https")
+//	safe := p.Sanitize(untrusted)
+//
+// Elements that are not allowed are removed and their text is kept, except
+// the content of script, style and the like, which is removed too.
+// Comments and doctypes are always removed. Unclosed elements are closed.
+// A policy must not be modified while it is in use.
+type Policy struct {
+	elements    map[string]map[string]bool // element => allowed attributes
+	globalAttrs map[string]bool            // attributes allowed on all the allowed elements
+	schemes     map[string]bool
+	nofollow    bool
+}
+
+// NewPolicy creates a policy which allows nothing.
+func NewPolicy() *Policy {
+	return &Policy{
+		elements:    make(map[string]map[string]bool),
+		globalAttrs: make(map[string]bool),
+		schemes:     make(map[string]bool),
+	}
+}
+
+// UGCPolicy returns a policy for user generated content, which allows the
+// HTML generated from markdown (including the classes of highlighted code,
+// header IDs and tables), and links and images with http, https, mailto
+// or relative URLs. Links get rel="nofollow".
+func UGCPolicy() *Policy {
+	return NewPolicy().
+		AllowElements("p", "br", "hr", "div", "span", "nav",
+			"h1", "h2", "h3", "h4", "h5", "h6",
+			"b", "i", "u", "s", "em", "strong", "strike", "del", "ins", "mark", "small", "sub", "sup",
+			"abbr", "cite", "code", "kbd", "pre", "q", "samp", "var", "blockquote",
+			"ul", "ol", "li", "dl", "dt", "dd", "details", "summary",
+			"table", "thead", "tbody", "tfoot", "tr", "th", "td", "caption").
+		AllowAttrs("*", "id", "class", "title").
+		AllowAttrs("a", "href", "name", "rel").
+		AllowAttrs("img", "src", "alt", "width", "height").
+		AllowAttrs("ol", "start").
+		AllowAttrs("th", "align", "colspan", "rowspan").
+		AllowAttrs("td", "align", "colspan", "rowspan").
+		AllowAttrs("abbr", "title").
+		AllowAttrs("blockquote", "cite").
+		AllowAttrs("q", "cite").
+		AllowURLSchemes("http", "https", "mailto").
+		RequireNoFollow()
+}
+
+// AllowElements allows the elements, without attributes.
+func (p *Policy) AllowElements(names ...string) *Policy {
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if p.elements[name] == nil {
+			p.elements[name] = make(map[string]bool)
+		}
+	}
+	return p
+}
+
+// AllowAttrs allows the attributes on the element, and the element itself.
+// The element "*" means all the allowed elements.
+func (p *Policy) AllowAttrs(element string, attrs ...string) *Policy {
+	set := p.globalAttrs
+	if element != "*" {
+		p.AllowElements(element)
+		set = p.elements[strings.ToLower(element)]
+	}
+	for _, attr := range attrs {
+		set[strings.ToLower(attr)] = true
+	}
+	return p
+}
+
+// AllowURLSchemes allows the URL schemes in the URL attributes (href, src...),
+// relative URLs are always allowed.
+func (p *Policy) AllowURLSchemes(schemes ...string) *Policy {
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(scheme)] = true
+	}
+	return p
+}
+
+// RequireNoFollow adds rel="nofollow" to the links.
+func (p *Policy) RequireNoFollow() *Policy {
+	p.nofollow = true
+	return p
+}
+
+// Fingerprint returns a hash of the rules of the policy, equal policies
+// have the same fingerprint, e.g. the ones of two UGCPolicy calls.
+// It can be used in cache keys.
+func (p *Policy) Fingerprint() string {
+	var buf bytes.Buffer
+	writeSet := func(set map[string]bool) {
+		keys := make([]string, 0, len(set))
+		for k := range set {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString(strings.Join(keys, ","))
+	}
+	elements := make([]string, 0, len(p.elements))
+	for name := range p.elements {
+		elements = append(elements, name)
+	}
+	sort.Strings(elements)
+	for _, name := range elements {
+		buf.WriteString(name)
+		buf.WriteByte('[')
+		writeSet(p.elements[name])
+		buf.WriteString("];")
+	}
+	buf.WriteString("*[")
+	writeSet(p.globalAttrs)
+	buf.WriteString("];schemes[")
+	writeSet(p.schemes)
+	buf.WriteByte(']')
+	if p.nofollow {
+		buf.WriteString(";nofollow")
+	}
+	sum := sha1.Sum(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// SanitizeString is like Sanitize but for strings.
+func (p *Policy) SanitizeString(s string) string {
+	return string(p.Sanitize([]byte(s)))
+}
+
+// Sanitize returns the HTML with everything not allowed by the policy removed.
+func (p *Policy) Sanitize(in []byte) []byte {
+	var (
+		out   bytes.Buffer
+		stack []string // the open elements
+	)
+	out.Grow(len(in))
+	for i := 0; i < len(in); {
+		lt := bytes.IndexByte(in[i:], '<')
+		if lt < 0 {
+			out.Write(in[i:])
+			break
+		}
+		out.Write(in[i : i+lt])
+		i += lt
+
+		tag, n := parseTag(in[i:])
+		if n == 0 {
+			// not a tag
+			out.WriteString("&lt;")
+			i++
+			continue
+		}
+		i += n
+		if len(tag.name) == 0 {
+			// comment or doctype
+			continue
+		}
+		attrs, allowed := p.elements[tag.name]
+
+		if tag.end {
+			if !allowed {
+				continue
+			}
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j] == tag.name {
+					for k := len(stack) - 1; k >= j; k-- {
+						out.WriteString("</" + stack[k] + ">")
+					}
+					stack = stack[:j]
+					break
+				}
+			}
+			continue
+		}
+
+		if !allowed {
+			if rawTextElements[tag.name] {
+				i += rawTextLen(in[i:], tag.name)
+			}
+			continue
+		}
+		out.WriteString("<" + tag.name)
+		p.writeAttrs(&out, tag, attrs)
+		if voidElements[tag.name] {
+			if tag.selfClosing {
+				out.WriteString(" />")
+			} else {
+				out.WriteByte('>')
+			}
+			continue
+		}
+		out.WriteByte('>')
+		stack = append(stack, tag.name)
+	}
+	for j := len(stack) - 1; j >= 0; j-- {
+		out.WriteString("</" + stack[j] + ">")
+	}
+	return out.Bytes()
+}
+
+func (p *Policy) writeAttrs(out *bytes.Buffer, tag htmlTag, attrs map[string]bool) {
+	var rel string
+	written := make(map[string]bool, len(tag.attrs))
+	for _, attr := range tag.attrs {
+		if written[attr.name] || !attrs[attr.name] && !p.globalAttrs[attr.name] {
+			continue
+		}
+		written[attr.name] = true
+		if urlAttrs[attr.name] && !p.allowedURL(attr.value) {
+			continue
+		}
+		if attr.name == "rel" && p.nofollow && tag.name == "a" {
+			rel = attr.value
+			continue
+		}
+		writeAttr(out, attr.name, attr.value)
+	}
+	if p.nofollow && tag.name == "a" {
+		if !strings.Contains(" "+rel+" ", " nofollow ") {
+			rel = strings.TrimSpace(rel + " nofollow")
+		}
+		writeAttr(out, "rel", rel)
+	}
+}
+
+func writeAttr(out *bytes.Buffer, name, value string) {
+	out.WriteString(" " + name + "=\"")
+	attrEscape(out, []byte(value))
+	out.WriteByte('"')
+}
+
+// allowedURL reports whether the URL is relative or has an allowed scheme.
+func (p *Policy) allowedURL(u string) bool {
+	// browsers ignore the whitespaces and control characters in the scheme
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	if i := strings.IndexAny(u, ":/?#"); i >= 0 && u[i] == ':' {
+		return p.schemes[strings.ToLower(u[:i])]
+	}
+	return true
+}
+
+var (
+	// elements without end tags
+	voidElements = wordSet("area base br col embed hr img input link meta param source track wbr")
+	// elements whose content is removed along with them
+	rawTextElements = wordSet("script style iframe object noscript noembed noframes template textarea title xmp")
+	// attributes holding URLs
+	urlAttrs = wordSet("href src cite action formaction poster background longdesc")
+)
+
+type htmlTag struct {
+	name        string // lower case, empty for comments and doctypes
+	attrs       []htmlAttr
+	end         bool
+	selfClosing bool
+}
+
+type htmlAttr struct {
+	name  string // lower case
+	value string // unescaped
+}
+
+// parseTag parses the tag, comment or doctype at the beginning of data,
+// which starts with '<', and returns its length or 0 if it is not a tag.
+func parseTag(data []byte) (tag htmlTag, n int) {
+	if len(data) < 2 {
+		return tag, 0
+	}
+	switch c := data[1]; {
+	case bytes.HasPrefix(data, []byte("<!--")):
+		if end := bytes.Index(data[4:], []byte("-->")); end >= 0 {
+			return tag, 4 + end + 3
+		}
+		return tag, len(data)
+	case c == '!' || c == '?':
+		if end := bytes.IndexByte(data, '>'); end >= 0 {
+			return tag, end + 1
+		}
+		return tag, len(data)
+	case c == '/':
+		tag.end = true
+		n = 2
+	case isletter(c):
+		n = 1
+	default:
+		return tag, 0
+	}
+	if n >= len(data) || !isletter(data[n]) {
+		return tag, 0
+	}
+	start := n
+	for n < len(data) && !isspace(data[n]) && data[n] != '/' && data[n] != '>' {
+		n++
+	}
+	tag.name = strings.ToLower(string(data[start:n]))
+
+	for n < len(data) {
+		switch c := data[n]; {
+		case isspace(c):
+			n++
+		case c == '>':
+			return tag, n + 1
+		case c == '/':
+			n++
+			tag.selfClosing = n < len(data) && data[n] == '>'
+		default:
+			attr, l := parseAttr(data[n:])
+			if l == 0 {
+				return htmlTag{}, 0
+			}
+			n += l
+			if !tag.end {
+				tag.attrs = append(tag.attrs, attr)
+			}
+		}
+	}
+	// not closed
+	return htmlTag{}, 0
+}
+
+// parseAttr parses the attribute at the beginning of data and returns its length,
+// or 0 if its value is not closed.
+func parseAttr(data []byte) (attr htmlAttr, n int) {
+	for n < len(data) && !isspace(data[n]) && data[n] != '/' && data[n] != '>' && (data[n] != '=' || n == 0) {
+		n++
+	}
+	attr.name = strings.ToLower(string(data[:n]))
+	i := n
+	for i < len(data) && isspace(data[i]) {
+		i++
+	}
+	if i >= len(data) || data[i] != '=' {
+		return attr, n
+	}
+	i++
+	for i < len(data) && isspace(data[i]) {
+		i++
+	}
+	if i >= len(data) {
+		return attr, 0
+	}
+	if q := data[i]; q == '"' || q == '\'' {
+		end := bytes.IndexByte(data[i+1:], q)
+		if end < 0 {
+			return attr, 0
+		}
+		attr.value = html.UnescapeString(string(data[i+1 : i+1+end]))
+		return attr, i + 1 + end + 1
+	}
+	start := i
+	for i < len(data) && !isspace(data[i]) && data[i] != '>' {
+		i++
+	}
+	attr.value = html.UnescapeString(string(data[start:i]))
+	return attr, i
+}
+
+// rawTextLen returns the length of the content of the raw text element,
+// including its end tag.
+func rawTextLen(data []byte, name string) int {
+	end := []byte("</" + name)
+	for i := 0; ; {
+		j := bytes.IndexByte(data[i:], '<')
+		if j < 0 {
+			return len(data)
+		}
+		i += j
+		if i+len(end) > len(data) {
+			return len(data)
+		}
+		if !bytes.EqualFold(data[i:i+len(end)], end) {
+			i++
+			continue
+		}
+		i += len(end)
+		if i == len(data) || isspace(data[i]) || data[i] == '>' || data[i] == '/' {
+			if k := bytes.IndexByte(data[i:], '>'); k >= 0 {
+				return i + k + 1
+			}
+			return len(data)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+//
+// Unit tests for the HTML sanitizer
+//
+
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	var tests = []string{
+		"<p>hello <b>world</b></p>",
+		"<p>hello <b>world</b></p>",
+
+		"<script>alert(1)</script><p>text</p>",
+		"<p>text</p>",
+
+		"<SCRIPT type=\"text/javascript\">document.write('</p>')</script >after",
+		"after",
+
+		"<p onclick=\"evil()\" class=\"x\">text</p>",
+		"<p class=\"x\">text</p>",
+
+		"<a href=\"javascript:alert(1)\">link</a>",
+		"<a rel=\"nofollow\">link</a>",
+
+		"<a href=\" java\tscript:alert(1)\">link</a>",
+		"<a rel=\"nofollow\">link</a>",
+
+		"<a href=\"&#106;avascript:alert(1)\">link</a>",
+		"<a rel=\"nofollow\">link</a>",
+
+		"<a href=\"/doc?a=1&amp;b=2\" rel=\"footnote\">link</a>",
+		"<a href=\"/doc?a=1&amp;b=2\" rel=\"footnote nofollow\">link</a>",
+
+		"<img src=https://example.com/a.png alt='a \"b\"' onerror=x />",
+		"<img src=\"https://example.com/a.png\" alt=\"a &quot;b&quot;\" />",
+
+		"<iframe src=\"https://example.com\">fallback</iframe><u>text</u>",
+		"<u>text</u>",
+
+		"<!-- comment --><!DOCTYPE html><p>text</p>",
+		"<p>text</p>",
+
+		"<blink>text</blink>",
+		"text",
+
+		"<ul><li>one<li>two</ul>",
+		"<ul><li>one<li>two</li></li></ul>",
+
+		"<div><em>unclosed",
+		"<div><em>unclosed</em></div>",
+
+		"</p>stray</div>",
+		"stray",
+
+		"a < b <p title=\"unclosed>x",
+		"a &lt; b &lt;p title=\"unclosed>x",
+
+		"<br><hr/>",
+		"<br><hr />",
+	}
+	p := UGCPolicy()
+	for i := 0; i+1 < len(tests); i += 2 {
+		if got := p.SanitizeString(tests[i]); got != tests[i+1] {
+			t.Errorf("%q:\nexpected %q\n     got %q", tests[i], tests[i+1], got)
+		}
+	}
+}
+
+func TestSanitizePolicy(t *testing.T) {
+	p := NewPolicy().AllowAttrs("a", "href").AllowURLSchemes("https")
+	got := p.SanitizeString(`<p>x</p><a href="http://a">a</a><a href="HTTPS://b">b</a><a href="c/d:e">c</a>`)
+	want := `x<a>a</a><a href="HTTPS://b">b</a><a href="c/d:e">c</a>`
+	if got != want {
+		t.Errorf("expected %q\n     got %q", want, got)
+	}
+}
+
+func TestPolicyFingerprint(t *testing.T) {
+	if UGCPolicy().Fingerprint() != UGCPolicy().Fingerprint() {
+		t.Error("expected equal fingerprints of equal policies")
+	}
+	a := NewPolicy().AllowAttrs("a", "href")
+	b := NewPolicy().AllowAttrs("a", "href").AllowURLSchemes("https")
+	c := NewPolicy().AllowElements("a").AllowAttrs("*", "href")
+	if a.Fingerprint() == b.Fingerprint() || a.Fingerprint() == c.Fingerprint() {
+		t.Error("expected different fingerprints of different policies")
+	}
+}
+
+func TestGithubBodySanitize(t *testing.T) {
+	in := "# Title\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1)) <span onmouseover=\"x\">hi</span>\n\n```go\nvar x\n```\n"
+	body := string(GithubBody([]byte(in), GithubOptions{Highlight: Highlight, Sanitize: UGCPolicy()}))
+	for _, bad := range []string{"<script", "alert", "onmouseover"} {
+		if strings.Contains(body, bad) {
+			t.Errorf("unexpected %s in:\n%s", bad, body)
+		}
+	}
+	for _, want := range []string{`<h1 id="title">`, `<span>hi</span>`, `<span class="hl-k">var</span>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package pongo2
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FragmentCache is the store of the cache-tag, which caches the rendered
+// output of a template fragment:
+//
+//	{% cache "menu" 300 user.Id locale %}...{% endcache %}
+//
+// The first argument is the key, the second one is the time to live (seconds as
+// an integer or a duration string like "5m"; 0 means no expiration) and all other
+// arguments vary the key, which becomes "menu:<user.Id>:<locale>" (see FragmentKey).
+//
+// The output of the fragment is served to every request with the same key, so
+// it must not contain per-request values such as a CSRF token, a CSP nonce or
+// flash messages. A template using a variable added by AddRequestVariables
+// inside a cache-tag fails to parse; the values rendered by included templates
+// or macros are not checked.
+type FragmentCache interface {
+	Get(key string) (string, bool)
+	Set(key, value string, ttl time.Duration)
+	Delete(keys ...string)
+	// DeletePrefix deletes all the keys with one of the prefixes.
+	DeletePrefix(prefixes ...string)
+}
+
+// DefaultFragmentCache is used by template sets without their own FragmentCache.
+var DefaultFragmentCache FragmentCache = NewMemoryFragmentCache()
+
+// SetFragmentCache sets the store of the cache-tag for this template set.
+func (set *TemplateSet) SetFragmentCache(c FragmentCache) {
+	set.fragmentCacheMutex.Lock()
+	defer set.fragmentCacheMutex.Unlock()
+	set.fragmentCache = c
+}
+
+// FragmentCache returns the store of the cache-tag for this template set.
+func (set *TemplateSet) FragmentCache() FragmentCache {
+	set.fragmentCacheMutex.RLock()
+	defer set.fragmentCacheMutex.RUnlock()
+	if set.fragmentCache == nil {
+		return DefaultFragmentCache
+	}
+	return set.fragmentCache
+}
+
+// AddRequestVariables adds the names of the variables which have a value per
+// request, such as a CSRF token, so that they are rejected inside a cache-tag.
+// It must be called before the templates are parsed.
+func (set *TemplateSet) AddRequestVariables(names ...string) {
+	set.fragmentCacheMutex.Lock()
+	defer set.fragmentCacheMutex.Unlock()
+	if set.requestVariables == nil {
+		set.requestVariables = make(map[string]bool)
+	}
+	for _, name := range names {
+		set.requestVariables[name] = true
+	}
+}
+
+func (set *TemplateSet) isRequestVariable(name string) bool {
+	set.fragmentCacheMutex.RLock()
+	defer set.fragmentCacheMutex.RUnlock()
+	return set.requestVariables[name]
+}
+
+// FragmentKey returns the key of a cache-tag with the vary arguments, e.g.
+// "menu:1:zh-CN" for {% cache "menu" 300 user.Id locale %} and user.Id 1.
+// The ":" and "\" in the key and the arguments are escaped with "\", so that
+// different arguments never make the same key, and the prefix "menu:" matches
+// all the keys of "menu".
+func FragmentKey(key string, vary ...string) string {
+	s := escapeFragmentKey(key)
+	for _, v := range vary {
+		s += ":" + escapeFragmentKey(v)
+	}
+	return s
+}
+
+var fragmentKeyReplacer = strings.NewReplacer(`\`, `\\`, ":", `\:`)
+
+func escapeFragmentKey(s string) string {
+	return fragmentKeyReplacer.Replace(s)
+}
+
+// MemoryFragmentCache is an in-process FragmentCache, expired entries are
+// removed when they are read and swept out as the cache grows.
+type MemoryFragmentCache struct {
+	entries   map[string]fragmentEntry
+	sweepSize int
+	sync.RWMutex
+}
+
+type fragmentEntry struct {
+	value   string
+	expires time.Time // zero means no expiration
+}
+
+func (e fragmentEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+const minSweepSize = 256
+
+// NewMemoryFragmentCache creates an empty MemoryFragmentCache.
+func NewMemoryFragmentCache() *MemoryFragmentCache {
+	return &MemoryFragmentCache{
+		entries:   make(map[string]fragmentEntry),
+		sweepSize: minSweepSize,
+	}
+}
+
+func (m *MemoryFragmentCache) Get(key string) (string, bool) {
+	m.RLock()
+	e, ok := m.entries[key]
+	m.RUnlock()
+	if !ok {
+		return "", false
+	}
+	if e.expired(time.Now()) {
+		m.Lock()
+		if e, ok = m.entries[key]; ok && e.expired(time.Now()) {
+			delete(m.entries, key)
+		}
+		m.Unlock()
+		return "", false
+	}
+	return e.value, true
+}
+
+func (m *MemoryFragmentCache) Set(key, value string, ttl time.Duration) {
+	e := fragmentEntry{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.entries[key] = e
+	if len(m.entries) >= m.sweepSize {
+		now := time.Now()
+		for k, e := range m.entries {
+			if e.expired(now) {
+				delete(m.entries, k)
+			}
+		}
+		m.sweepSize = 2 * len(m.entries)
+		if m.sweepSize < minSweepSize {
+			m.sweepSize = minSweepSize
+		}
+	}
+}
+
+func (m *MemoryFragmentCache) Delete(keys ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+}
+
+func (m *MemoryFragmentCache) DeletePrefix(prefixes ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for k := range m.entries {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				delete(m.entries, k)
+				break
+			}
+		}
+	}
+}
+
+type tagCacheNode struct {
+	position *Token
+	key      IEvaluator
+	ttl      IEvaluator
+	vary     []IEvaluator
+	wrapper  *NodeWrapper
+}
+
+func (node *tagCacheNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	keyValue, err := node.key.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	vary := make([]string, len(node.vary))
+	for i, v := range node.vary {
+		val, err := v.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		vary[i] = val.String()
+	}
+	key := FragmentKey(keyValue.String(), vary...)
+
+	ttlValue, err := node.ttl.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if ttlValue.IsString() {
+		d, perr := time.ParseDuration(ttlValue.String())
+		if perr != nil {
+			return ctx.Error("Invalid cache ttl: "+perr.Error(), node.position)
+		}
+		ttl = d
+	} else {
+		ttl = time.Duration(ttlValue.Integer()) * time.Second
+	}
+
+	store := ctx.template.set.FragmentCache()
+	if s, ok := store.Get(key); ok {
+		writer.WriteString(s)
+		return nil
+	}
+
+	b := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB
+	if err = node.wrapper.Execute(ctx, b); err != nil {
+		return err
+	}
+	store.Set(key, b.String(), ttl)
+	writer.Write(b.Bytes())
+	return nil
+}
+
+func tagCacheParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	cacheNode := &tagCacheNode{
+		position: start,
+	}
+
+	key, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	cacheNode.key = key
+
+	if arguments.Remaining() == 0 {
+		return nil, arguments.Error("Cache-tag needs a key and a ttl.", nil)
+	}
+	ttl, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	cacheNode.ttl = ttl
+
+	for arguments.Remaining() > 0 {
+		vary, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		cacheNode.vary = append(cacheNode.vary, vary)
+	}
+
+	first := doc.idx
+	wrapper, endargs, err := doc.WrapUntilTag("endcache")
+	if err != nil {
+		return nil, err
+	}
+	cacheNode.wrapper = wrapper
+
+	// the per-request variables would be served to the other requests
+	if doc.template != nil {
+		for i := first; i < doc.idx; i++ {
+			t := doc.tokens[i]
+			if t.Typ != TokenIdentifier || !doc.template.set.isRequestVariable(t.Val) {
+				continue
+			}
+			if prev := doc.tokens[i-1]; prev.Typ == TokenSymbol && prev.Val == "." {
+				continue
+			}
+			return nil, doc.Error(fmt.Sprintf("Cache-tag must not contain the per-request variable '%s'.", t.Val), t)
+		}
+	}
+
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return cacheNode, nil
+}
+
+func init() {
+	RegisterTag("cache", tagCacheParser)
+}
@@ -0,0 +1,107 @@
+package pongo2
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCacheTestSet() (*TemplateSet, *MemoryFragmentCache) {
+	set := NewSet("cache-test", nil)
+	store := NewMemoryFragmentCache()
+	set.SetFragmentCache(store)
+	return set, store
+}
+
+func renderString(t *testing.T, tpl *Template, ctx Context) string {
+	s, err := tpl.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCacheTag(t *testing.T) {
+	set, store := newCacheTestSet()
+	tpl, err := set.FromString(`{% cache "menu" 0 id %}{{ n }}{% endcache %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := renderString(t, tpl, Context{"id": 1, "n": "a"}); s != "a" {
+		t.Fatalf("expected a, got %q", s)
+	}
+	// hit
+	if s := renderString(t, tpl, Context{"id": 1, "n": "b"}); s != "a" {
+		t.Fatalf("expected the cached a, got %q", s)
+	}
+	// vary
+	if s := renderString(t, tpl, Context{"id": 2, "n": "b"}); s != "b" {
+		t.Fatalf("expected b, got %q", s)
+	}
+	if v, ok := store.Get(FragmentKey("menu", "2")); !ok || v != "b" {
+		t.Fatalf("unexpected entry %q %v", v, ok)
+	}
+
+	// invalidation
+	store.Delete(FragmentKey("menu", "1"))
+	if s := renderString(t, tpl, Context{"id": 1, "n": "c"}); s != "c" {
+		t.Fatalf("expected c after Delete, got %q", s)
+	}
+	store.DeletePrefix("menu:")
+	if s := renderString(t, tpl, Context{"id": 2, "n": "d"}); s != "d" {
+		t.Fatalf("expected d after DeletePrefix, got %q", s)
+	}
+}
+
+func TestCacheTagExpiry(t *testing.T) {
+	set, _ := newCacheTestSet()
+	tpl, err := set.FromString(`{% cache "clock" ttl %}{{ n }}{% endcache %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderString(t, tpl, Context{"ttl": "20ms", "n": "a"})
+	if s := renderString(t, tpl, Context{"ttl": "20ms", "n": "b"}); s != "a" {
+		t.Fatalf("expected the cached a, got %q", s)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if s := renderString(t, tpl, Context{"ttl": "20ms", "n": "c"}); s != "c" {
+		t.Fatalf("expected c after the expiry, got %q", s)
+	}
+	if _, err = tpl.Execute(Context{"ttl": "soon"}); err == nil {
+		t.Fatal("expected an error for an invalid ttl")
+	}
+}
+
+func TestFragmentKey(t *testing.T) {
+	if FragmentKey("menu", "1") == FragmentKey("menu:1") {
+		t.Fatal("the keys collide")
+	}
+	if k := FragmentKey(`a:b\`, "c"); k != `a\:b\\:c` {
+		t.Fatalf("unexpected key %q", k)
+	}
+
+	set, _ := newCacheTestSet()
+	varied, _ := set.FromString(`{% cache "menu" 0 v %}{{ n }}{% endcache %}`)
+	plain, _ := set.FromString(`{% cache k 0 %}{{ n }}{% endcache %}`)
+	renderString(t, varied, Context{"v": "1", "n": "varied"})
+	if s := renderString(t, plain, Context{"k": "menu:1", "n": "plain"}); s != "plain" {
+		t.Fatalf("expected plain, got %q", s)
+	}
+}
+
+func TestCacheTagRequestVariables(t *testing.T) {
+	set, _ := newCacheTestSet()
+	set.AddRequestVariables("csrf_token")
+	_, err := set.FromString(`{% cache "form" 0 %}<input value="{{ csrf_token }}">{% endcache %}`)
+	if err == nil || !strings.Contains(err.Error(), "csrf_token") {
+		t.Fatalf("expected an error for csrf_token, got %v", err)
+	}
+	for _, s := range []string{
+		`{% cache "form" 0 %}{{ user.csrf_token }}{% endcache %}`,
+		`{% cache "form" 0 %}{{ n }}{% endcache %}{{ csrf_token }}`,
+	} {
+		if _, err = set.FromString(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+}
@@ -51,6 +51,11 @@ type TemplateSet struct {
 	// Template cache (for FromCache())
 	templateCache      map[string]*Template
 	templateCacheMutex sync.Mutex
+
+	// Store of the cache-tag and the variables which must not be used inside it
+	fragmentCache      FragmentCache
+	requestVariables   map[string]bool
+	fragmentCacheMutex sync.RWMutex
 }
 
 // NewSet can be used to create sets with different kind of templates
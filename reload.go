@@ -0,0 +1,224 @@
+package lessgo
+
+import (
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/utils"
+)
+
+// 配置项变更记录
+type ConfigChange struct {
+	Key     string      // 形如"section::key"
+	Old     interface{} // 原值
+	New     interface{} // 配置文件中的新值
+	Applied bool        // 是否已在运行时生效，false表示需重启服务才能生效
+}
+
+// 配置文件自动检查更新的频率
+const configWatchInterval = 3 * time.Second
+
+var (
+	configReloadLock sync.Mutex
+	// 热重载后的全局配置，每次重载均替换为新的实例
+	reloadedConfig atomic.Value
+	// 跨域中间件在路由操作前的中间件子链中的位置，运行时重新开启时插回原处
+	crossDomainIndex = -1
+)
+
+// 返回当前生效的全局配置。
+// 热重载时以新实例整体替换，不修改原实例，因此运行时应通过它读取可热重载的配置项；
+// 未重载过时即为Config。
+func CurrentConfig() *config {
+	if c, ok := reloadedConfig.Load().(*config); ok {
+		return c
+	}
+	return Config
+}
+
+// 可在运行时直接生效的配置项，filecache与i18n的全部配置项也可直接生效；
+// info的配置项运行时不被读取，故同样需重启服务
+var hotConfigKeys = map[string]bool{
+	"system::debug":                    true,
+	"system::crossdomain":              true,
+	"system::maxmemorymb":              true,
+	"log::level":                       true,
+	"log::asyncchan":                   true,
+	"session::sessiongcmaxlifetime":    true,
+	"session::sessioncookielifetime":   true,
+	"session::sessionabsolutelifetime": true,
+	"security::cookiekeys":             true,
+	"security::cookiemaxage":           true,
+}
+
+func isHotConfigKey(fullname string) bool {
+	if strings.HasPrefix(fullname, "filecache::") || strings.HasPrefix(fullname, "i18n::") {
+		return true
+	}
+	return hotConfigKeys[fullname]
+}
+
+// 重新逐层加载配置，并使变更的配置项在运行时生效，
+// 返回全部变更项，其中无法在运行时生效的(如监听地址)需重启服务。
+func ReloadConfig() ([]ConfigChange, error) {
+	configReloadLock.Lock()
+	defer configReloadLock.Unlock()
+
+	newConf := newConfig()
+	if err := newConf.loadLayers(); err != nil {
+		return nil, err
+	}
+
+	prev := CurrentConfig()
+	next := *prev
+	changes := mergeConfig(&next, newConf)
+	next.sources = newConf.sources
+	reloadedConfig.Store(&next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	applyConfig(prev, &next)
+
+	var applied, restart []string
+	for _, c := range changes {
+		if c.Applied {
+			applied = append(applied, c.Key)
+		} else {
+			restart = append(restart, c.Key)
+		}
+	}
+	if len(applied) > 0 {
+		Log.Sys("Config reloaded: %s", strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		Log.Warn("Config changed but requires a restart to take effect: %s", strings.Join(restart, ", "))
+	}
+	return changes, nil
+}
+
+// 比较新旧配置，将可运行时生效的变更项写入dst，并返回全部变更项
+func mergeConfig(dst, src *config) []ConfigChange {
+	var changes []ConfigChange
+	srcSections := src.sections()
+	for n, section := range dst.sections() {
+		name := section.name
+		hot := func(field string) bool { return isHotConfigKey(getfullname(name, field)) }
+		for _, c := range utils.MergeFields(section.ptr, srcSections[n].ptr, hot) {
+			changes = append(changes, ConfigChange{
+				Key:     getfullname(name, c.Field),
+				Old:     c.Old,
+				New:     c.New,
+				Applied: c.Merged,
+			})
+		}
+	}
+	sort.Sort(configChanges(changes))
+	return changes
+}
+
+// 使conf中相对prev变更的配置项生效
+func applyConfig(prev, conf *config) {
+	if prev.Log.AsyncChan != conf.Log.AsyncChan {
+		Log.SetMsgChan(conf.Log.AsyncChan)
+	}
+	if prev.Log.Level != conf.Log.Level || prev.Debug != conf.Debug {
+		Log.SetLevel(conf.Log.Level)
+		app.SetDebug(conf.Debug)
+		if r, ok := app.renderer.(templateEngine); ok {
+			r.SetCaching(!conf.Debug)
+		}
+	}
+	if prev.FileCache != conf.FileCache && app.memoryCache != nil {
+		app.memoryCache.SetLimits(
+			conf.FileCache.SingleFileAllowMB*MB,
+			conf.FileCache.MaxCapMB*MB,
+			time.Duration(conf.FileCache.CacheSecond)*time.Second,
+		)
+	}
+	if prev.I18n.DefaultLocale != conf.I18n.DefaultLocale {
+		I18n.SetDefault(conf.I18n.DefaultLocale)
+	}
+	if prev.MaxMemoryMB != conf.MaxMemoryMB {
+		MaxMemory = conf.MaxMemoryMB * MB
+	}
+	if prev.Session.SessionGCMaxLifetime != conf.Session.SessionGCMaxLifetime ||
+		prev.Session.SessionCookieLifeTime != conf.Session.SessionCookieLifeTime {
+		if sessions := app.Sessions(); sessions != nil {
+			err := sessions.SetLifetime(conf.Session.SessionGCMaxLifetime, conf.Session.SessionCookieLifeTime)
+			if err != nil {
+				Log.Error("Failed to reset session lifetime: %v", err)
+			}
+		}
+	}
+	if prev.Session.SessionAbsoluteLifetime != conf.Session.SessionAbsoluteLifetime {
+		if sessions := app.Sessions(); sessions != nil {
+			sessions.SetAbsoluteLifetime(conf.Session.SessionAbsoluteLifetime)
+		}
+	}
+	if prev.Security != conf.Security {
+		if err := setSecureCookieKeys(conf.Security.CookieKeys); err != nil {
+			Log.Error("Failed to reset secure cookie keys: %v", err)
+		}
+	}
+	if prev.CrossDomain != conf.CrossDomain {
+		setCrossDomain(conf.CrossDomain)
+	}
+}
+
+// 在原位置添加或移除跨域中间件，并重建路由
+func setCrossDomain(on bool) {
+	lessgo.lock.Lock()
+	isCrossDomain := func(v interface{}) bool { return v.(*MiddlewareConfig).Name == CrossDomain.Name }
+	crossDomainIndex = utils.SliceToggle(&lessgo.virtBefore, isCrossDomain, &MiddlewareConfig{Name: CrossDomain.Name}, on, crossDomainIndex)
+	lessgo.lock.Unlock()
+	ReregisterRouter("config reload: system::crossdomain")
+}
+
+// 监控主配置文件、profile配置文件的变动及SIGHUP信号，自动重载配置
+func watchConfig() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	modTime := configModTime()
+	for {
+		select {
+		case <-sig:
+		case <-time.After(configWatchInterval):
+			if t := configModTime(); t.Equal(modTime) {
+				continue
+			}
+		}
+		modTime = configModTime()
+		if _, err := ReloadConfig(); err != nil {
+			Log.Error("Failed to reload config: %v", err)
+		}
+	}
+}
+
+// 主配置文件与profile配置文件中最近的修改时间
+func configModTime() time.Time {
+	var modTime time.Time
+	fname, _ := MainConfigFile()
+	files := []string{fname}
+	if profile := Profile(); len(profile) > 0 {
+		files = append(files, ProfileConfigFile(profile))
+	}
+	for _, fname := range files {
+		info, err := os.Stat(fname)
+		if err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime
+}
+
+type configChanges []ConfigChange
+
+func (c configChanges) Len() int           { return len(c) }
+func (c configChanges) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c configChanges) Less(i, j int) bool { return c[i].Key < c[j].Key }
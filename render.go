@@ -1,9 +1,18 @@
 package lessgo
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"html"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,99 +34,336 @@ type (
 	}
 )
 
+// 系统预编译并监控的模板目录
+var templateDirs = []string{TPL_DIR, BIZ_VIEW_DIR, SYS_VIEW_DIR}
+
+// 模板文件变动的检查频率
+const templateWatchInterval = time.Second
+
 // New creates a new Pongo2Render instance with custom Options.
 func NewPongo2Render(caching bool) *Pongo2Render {
-	return &Pongo2Render{
-		set:        pongo2.NewSet("lessgo", pongo2.DefaultLoader),
+	p := &Pongo2Render{
+		set:        pongo2.NewSet("lessgo", fsTemplateLoader{}),
 		caching:    caching,
 		tplCache:   make(map[string]*Tpl),
 		tplContext: make(pongo2.Context),
 	}
+	p.set.AddRequestVariables(flashesTplVar)
+	return p
+}
+
+// 运行时开启或关闭模板缓存
+func (p *Pongo2Render) SetCaching(caching bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.caching = caching
+	if !caching {
+		p.tplCache = make(map[string]*Tpl)
+	}
 }
 
+// 注册模板变量，过滤器函数注册为pongo2过滤器，pongo2.FragmentCache注册为{% cache %}标签的存储(忽略name)，
+// func(*Context) interface{}类型的函数在每次渲染时以当前请求调用，其返回值作为变量值(如csrf_token)
 func (p *Pongo2Render) TemplateVariable(name string, v interface{}) {
 	switch d := v.(type) {
 	case func(in *pongo2.Value, param *pongo2.Value) (out *pongo2.Value, err *pongo2.Error):
 		pongo2.RegisterFilter(name, d)
 	case pongo2.FilterFunction:
 		pongo2.RegisterFilter(name, d)
+	case pongo2.FragmentCache:
+		p.set.SetFragmentCache(d)
+	case func(*Context) interface{}:
+		// 按请求取值的变量不可用于{% cache %}标签内
+		p.set.AddRequestVariables(name)
+		p.tplContext[name] = d
 	default:
 		p.tplContext[name] = d
 	}
 }
 
+// {% cache %}标签的存储，未注册时为pongo2.DefaultFragmentCache
+func (p *Pongo2Render) FragmentCache() pongo2.FragmentCache {
+	return p.set.FragmentCache()
+}
+
 // Render should render the template to the io.Writer.
 func (p *Pongo2Render) Render(w io.Writer, filename string, data interface{}, c *Context) error {
+	// 以副本渲染，不修改调用方的数据(可能跨请求复用)
 	var data2 = pongo2.Context{}
 
-	if data == nil {
-		data2 = p.tplContext
-
-	} else {
-		switch d := data.(type) {
-		case pongo2.Context:
-			data2 = d
-		case map[string]interface{}:
-			data2 = pongo2.Context(d)
-		default:
-			b, _ := json.Marshal(data)
-			json.Unmarshal(b, &data2)
-		}
+	switch d := data.(type) {
+	case nil:
+	case pongo2.Context:
+		data2.Update(d)
+	case map[string]interface{}:
+		data2.Update(d)
+	default:
+		b, _ := json.Marshal(data)
+		json.Unmarshal(b, &data2)
+	}
 
-		for k, v := range p.tplContext {
-			if _, ok := data2[k]; !ok {
-				data2[k] = v
+	for k, v := range p.tplContext {
+		if _, ok := data2[k]; !ok {
+			// 按请求取值的模板变量
+			if fn, ok := v.(func(*Context) interface{}); ok {
+				if c != nil {
+					data2[k] = fn(c)
+				}
+				continue
 			}
+			data2[k] = v
 		}
 	}
 
-	var template *pongo2.Template
+	// 当前请求的语言，供trans标签使用
+	if _, ok := data2[localeTplVar]; !ok && c != nil {
+		data2[localeTplVar] = c.Locale()
+	}
 
-	if p.caching {
-		template = pongo2.Must(p.FromCache(filename))
-	} else {
-		template = pongo2.Must(p.set.FromFile(filename))
+	// 闪存消息，模板访问时才读取并从session中清除
+	if _, ok := data2[flashesTplVar]; !ok && c != nil {
+		data2[flashesTplVar] = &LazyFlashes{c: c}
+	}
+
+	template, err := p.getTemplate(filename)
+	if err != nil {
+		return err
 	}
 	return template.ExecuteWriter(data2, w)
 }
 
-func (p *Pongo2Render) FromCache(fname string) (*pongo2.Template, error) {
-	//从文件系统缓存中获取文件信息
-	fbytes, finfo, exist := lessgo.App.MemoryCache().GetCacheFile(fname)
-
-	// 文件已不存在
-	if !exist {
-		// 移除模板中缓存
-		p.Lock()
-		_, has := p.tplCache[fname]
-		if has {
-			delete(p.tplCache, fname)
-		}
-		p.Unlock()
-		// 返回错误
-		return nil, errors.New(fname + "is not found.")
+// 获取模板，开启缓存时优先读取缓存，缓存中不存在时编译并缓存
+func (p *Pongo2Render) getTemplate(filename string) (*pongo2.Template, error) {
+	p.RLock()
+	caching := p.caching
+	p.RUnlock()
+	if caching {
+		return p.FromCache(filename)
 	}
+	return p.set.FromFile(filename)
+}
 
-	// 查看模板缓存
+// 从模板缓存中读取，模板的更新由Watch负责
+func (p *Pongo2Render) FromCache(fname string) (*pongo2.Template, error) {
+	fname = path.Clean(fname)
 	p.RLock()
 	tpl, has := p.tplCache[fname]
 	p.RUnlock()
-
-	// 存在模板缓存且文件未更新时，直接读模板缓存
-	if has && p.tplCache[fname].modTime.Equal(finfo.ModTime()) {
+	if has {
 		return tpl.template, nil
 	}
+	return p.compile(fname)
+}
 
-	// 缓存模板不存在或文件已更新时，均新建缓存模板
-	p.Lock()
-	defer p.Unlock()
-
-	// 创建新模板并缓存
-	newtpl, err := p.set.FromBytes(fname, fbytes)
+// 编译模板，开启缓存时存入缓存
+func (p *Pongo2Render) compile(fname string) (*pongo2.Template, error) {
+	info, err := statFS(app.FileSystem(), fname)
 	if err != nil {
 		return nil, err
 	}
-
-	p.tplCache[fname] = &Tpl{template: newtpl, modTime: finfo.ModTime()}
+	newtpl, err := p.set.FromFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	p.Lock()
+	if p.caching {
+		p.tplCache[fname] = &Tpl{template: newtpl, modTime: info.ModTime()}
+	}
+	p.Unlock()
 	return newtpl, nil
 }
+
+// 删除模板片段缓存({% cache %}标签)中的键，键为含变化部分的完整键，如"menu:1:zh-CN"，
+// 变化部分含":"时由pongo2.FragmentKey生成
+func DeleteTemplateCache(keys ...string) {
+	for _, c := range fragmentCaches() {
+		c.Delete(keys...)
+	}
+}
+
+// 删除模板片段缓存中指定前缀的全部键，如"menu:"
+func DeleteTemplateCachePrefix(prefixes ...string) {
+	for _, c := range fragmentCaches() {
+		c.DeletePrefix(prefixes...)
+	}
+}
+
+// 当前渲染器使用的全部模板片段缓存
+func fragmentCaches() []pongo2.FragmentCache {
+	var renders []Renderer
+	switch r := app.renderer.(type) {
+	case *MultiRender:
+		r.RLock()
+		renders = r.renderers()
+		r.RUnlock()
+	default:
+		renders = append(renders, r)
+	}
+	var caches []pongo2.FragmentCache
+	for _, r := range renders {
+		if p, ok := r.(*Pongo2Render); ok {
+			caches = append(caches, p.FragmentCache())
+		}
+	}
+	return caches
+}
+
+// 模板编译错误汇总
+type TemplateErrors []error
+
+func (e TemplateErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return fmt.Sprintf("%d template error(s):\n%s", len(e), strings.Join(s, "\n"))
+}
+
+// 预编译目录下的全部模板(TPL_EXT)，开启缓存时存入缓存，
+// 并返回全部编译错误，而非遇到第一个错误即停止
+func (p *Pongo2Render) Precompile(dirs ...string) error {
+	var errs TemplateErrors
+	for _, fname := range templateFiles([]string{TPL_EXT}, dirs...) {
+		if _, err := p.compile(fname); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 监控目录下模板文件的变动，有变动时重新编译全部已缓存的模板
+// (模板间存在extends、include等依赖，故全部重新编译)，该方法会阻塞
+func (p *Pongo2Render) Watch(interval time.Duration, dirs ...string) {
+	watchTemplates(interval, []string{TPL_EXT}, dirs, p.Reload)
+}
+
+// 监控目录下指定扩展名的模板文件，有变动时调用reload，该函数会阻塞
+func watchTemplates(interval time.Duration, exts, dirs []string, reload func() error) {
+	modTimes := templateModTimes(exts, dirs...)
+	for range time.Tick(interval) {
+		newModTimes := templateModTimes(exts, dirs...)
+		if reflect.DeepEqual(modTimes, newModTimes) {
+			continue
+		}
+		modTimes = newModTimes
+		if err := reload(); err != nil {
+			Log.Error("%v", err)
+		} else {
+			Log.Sys("Templates reloaded.")
+		}
+	}
+}
+
+// 重新编译全部已缓存的模板，编译失败的模板将移出缓存
+func (p *Pongo2Render) Reload() error {
+	p.RLock()
+	fnames := make([]string, 0, len(p.tplCache))
+	for fname := range p.tplCache {
+		fnames = append(fnames, fname)
+	}
+	p.RUnlock()
+	sort.Strings(fnames)
+
+	var errs TemplateErrors
+	for _, fname := range fnames {
+		if _, err := p.compile(fname); err != nil {
+			p.Lock()
+			delete(p.tplCache, fname)
+			p.Unlock()
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 目录下指定扩展名的全部模板文件
+func templateFiles(exts []string, dirs ...string) []string {
+	var fnames []string
+	for _, dir := range dirs {
+		walkFS(app.FileSystem(), dir, func(fname string, info os.FileInfo) {
+			if hasTemplateExt(fname, exts) {
+				fnames = append(fnames, fname)
+			}
+		})
+	}
+	return fnames
+}
+
+func hasTemplateExt(fname string, exts []string) bool {
+	ext := filepath.Ext(fname)
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func templateModTimes(exts []string, dirs ...string) map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, dir := range dirs {
+		walkFS(app.FileSystem(), dir, func(fname string, info os.FileInfo) {
+			if hasTemplateExt(fname, exts) {
+				modTimes[fname] = info.ModTime()
+			}
+		})
+	}
+	return modTimes
+}
+
+// 调试模式下模板出错时的页面，显示出错的模板文件及其上下文代码
+func templateErrorPage(name string, err error) string {
+	var (
+		fname       = name
+		line, col   int
+		msg         = err.Error()
+		contextSize = 5
+	)
+	if e, ok := err.(*pongo2.Error); ok {
+		if len(e.Filename) > 0 && e.Filename != "<string>" {
+			fname = e.Filename
+		}
+		line, col, msg = e.Line, e.Column, e.ErrorMsg
+		if len(e.Sender) > 0 {
+			msg = e.Sender + ": " + msg
+		}
+	} else if m := goTemplateErrorRe.FindStringSubmatch(msg); m != nil {
+		fname, msg = m[1], m[4]
+		line, _ = strconv.Atoi(m[2])
+		col, _ = strconv.Atoi(m[3])
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<html>\n<head><title>Template Error</title></head>\n<body bgcolor=\"white\">\n")
+	fmt.Fprintf(&buf, "<h2>Template Error</h2>\n<p><b>%s</b>", html.EscapeString(fname))
+	if line > 0 {
+		fmt.Fprintf(&buf, " line %d, column %d", line, col)
+	}
+	fmt.Fprintf(&buf, "</p>\n<p><b style=\"color:red;\">[ERROR]</b> %s</p>\n", html.EscapeString(msg))
+	if b, _, err := readFSFile(app.FileSystem(), fname); err == nil && line > 0 {
+		lines := strings.Split(string(b), "\n")
+		buf.WriteString("<pre style=\"background:#f6f8fa;padding:8px;\">")
+		for i := line - contextSize; i <= line+contextSize; i++ {
+			if i < 1 || i > len(lines) {
+				continue
+			}
+			text := fmt.Sprintf("%5d | %s", i, html.EscapeString(lines[i-1]))
+			if i == line {
+				text = "<b style=\"background:#ffdce0;display:block;\">" + text + "</b>"
+			} else {
+				text += "\n"
+			}
+			buf.WriteString(text)
+		}
+		buf.WriteString("</pre>\n")
+	}
+	fmt.Fprintf(&buf, "<hr>\n<center>lessgo/%s</center>\n</body>\n</html>\n", VERSION)
+	return buf.String()
+}
@@ -0,0 +1,433 @@
+package lessgo
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/pongo2"
+)
+
+// GoTemplateRender is a lessgo template renderer using html/template.
+//
+// 布局: 页面首行以{{/* extends "biz_view/layout.gohtml" */}}指定布局(布局亦可继续指定布局)，
+// 布局中以{{block "content" .}}{{end}}定义区块，页面以{{define "content"}}...{{end}}覆盖；
+// 局部模板: 模板目录下以"_"开头的文件，以其路径为名称，如{{template "biz_view/_nav.gohtml" .}}；
+// 模板变量: 通过TemplateVariable注册，函数可直接调用，其它值以无参函数的形式访问，如{{ site_name }}；
+// 按请求取值的变量(如csrf_token、locale、flashes)同样以无参函数的形式访问，如{{ csrf_field }}。
+type GoTemplateRender struct {
+	caching  bool // false=disable caching, true=enable caching
+	tplCache map[string]*template.Template
+	funcs    template.FuncMap
+	reqFuncs map[string]func(*Context) interface{} // 按请求取值的模板变量
+	sync.RWMutex
+}
+
+var (
+	// 页面首行指定布局的注释
+	goTemplateExtendsRe = regexp.MustCompile(`^\s*\{\{/\*\s*extends\s+"([^"]+)"\s*\*/\}\}`)
+	// html/template的错误信息，形如"template: name:line:col: msg"
+	goTemplateErrorRe = regexp.MustCompile(`(?s)^template: (.+?):(\d+):(?:(\d+):)? (.*)$`)
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NewGoTemplateRender creates a new GoTemplateRender instance.
+func NewGoTemplateRender(caching bool) *GoTemplateRender {
+	g := &GoTemplateRender{
+		caching:  caching,
+		tplCache: make(map[string]*template.Template),
+		funcs:    make(template.FuncMap),
+		reqFuncs: make(map[string]func(*Context) interface{}),
+	}
+	// 当前请求的语言
+	g.TemplateVariable(localeTplVar, func(c *Context) interface{} { return c.Locale() })
+	// 闪存消息，模板调用flashes时才读取并从session中清除
+	g.TemplateVariable(flashesTplVar, func(c *Context) interface{} { return c.Flashes() })
+	return g
+}
+
+// 运行时开启或关闭模板缓存
+func (g *GoTemplateRender) SetCaching(caching bool) {
+	g.Lock()
+	defer g.Unlock()
+	g.caching = caching
+	if !caching {
+		g.tplCache = make(map[string]*template.Template)
+	}
+}
+
+// 注册模板变量，pongo2的过滤器函数及模板片段缓存将被忽略，
+// func(*Context) interface{}类型的函数在每次渲染时以当前请求调用(每次渲染至多调用一次)
+func (g *GoTemplateRender) TemplateVariable(name string, v interface{}) {
+	switch d := v.(type) {
+	case func(in *pongo2.Value, param *pongo2.Value) (out *pongo2.Value, err *pongo2.Error), pongo2.FilterFunction, pongo2.FragmentCache:
+		return
+	case func(*Context) interface{}:
+		g.Lock()
+		defer g.Unlock()
+		g.reqFuncs[name] = d
+		// 解析时的占位函数，渲染时替换为以当前请求取值的函数
+		g.funcs[name] = func() interface{} { return nil }
+		g.tplCache = make(map[string]*template.Template)
+		return
+	}
+	fn := v
+	if t := reflect.TypeOf(v); t == nil || t.Kind() != reflect.Func {
+		fn = func() interface{} { return v }
+	} else if t.NumOut() == 0 || t.NumOut() > 2 || t.NumOut() == 2 && t.Out(1) != errorType {
+		Log.Warn("TemplateVariable %q is ignored by html/template: a function must return one value, or a value and an error", name)
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	delete(g.reqFuncs, name)
+	g.funcs[name] = fn
+	// 函数需在解析前注册，故清空缓存
+	g.tplCache = make(map[string]*template.Template)
+}
+
+// Render should render the template to the io.Writer.
+func (g *GoTemplateRender) Render(w io.Writer, filename string, data interface{}, c *Context) error {
+	tpl, root, err := g.getTemplate(path.Clean(filename))
+	if err != nil {
+		return err
+	}
+	// 缓存的模板不直接执行(执行后无法Clone)，以副本绑定当前请求的模板变量
+	if tpl, err = tpl.Clone(); err != nil {
+		return err
+	}
+	return tpl.Funcs(g.requestFuncs(c)).ExecuteTemplate(w, root, data)
+}
+
+// 以当前请求取值的模板变量函数，值在首次使用时取得，同一次渲染中不再重复取值(如闪存消息仅可读取一次)
+func (g *GoTemplateRender) requestFuncs(c *Context) template.FuncMap {
+	g.RLock()
+	defer g.RUnlock()
+	funcs := make(template.FuncMap, len(g.reqFuncs))
+	for name, fn := range g.reqFuncs {
+		var (
+			fn   = fn
+			once sync.Once
+			v    interface{}
+		)
+		funcs[name] = func() interface{} {
+			once.Do(func() {
+				if c != nil {
+					v = fn(c)
+				}
+			})
+			return v
+		}
+	}
+	return funcs
+}
+
+func (g *GoTemplateRender) getTemplate(fname string) (*template.Template, string, error) {
+	g.RLock()
+	tpl, has := g.tplCache[fname]
+	g.RUnlock()
+	if has {
+		return tpl, tpl.Name(), nil
+	}
+	return g.compile(fname)
+}
+
+// 编译页面及其布局与局部模板，返回模板及其根模板(最外层布局)的名称，开启缓存时存入缓存
+func (g *GoTemplateRender) compile(fname string) (*template.Template, string, error) {
+	// 由页面向外依次读取布局
+	var (
+		chain    []string
+		contents = make(map[string]string)
+	)
+	for f := fname; len(f) > 0; {
+		if _, ok := contents[f]; ok {
+			return nil, "", fmt.Errorf("template: %s: circular extends", f)
+		}
+		b, _, err := readFSFile(app.FileSystem(), f)
+		if err != nil {
+			return nil, "", err
+		}
+		chain = append(chain, f)
+		contents[f] = string(b)
+		f = ""
+		if m := goTemplateExtendsRe.FindSubmatch(b); m != nil {
+			f = path.Clean(string(m[1]))
+		}
+	}
+
+	g.RLock()
+	funcs := make(template.FuncMap, len(g.funcs))
+	for k, v := range g.funcs {
+		funcs[k] = v
+	}
+	g.RUnlock()
+
+	root := chain[len(chain)-1]
+	tpl := template.New(root).Funcs(funcs)
+	for _, partial := range goTemplatePartials() {
+		if _, ok := contents[partial]; ok {
+			continue
+		}
+		b, _, err := readFSFile(app.FileSystem(), partial)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = tpl.New(partial).Parse(string(b)); err != nil {
+			return nil, "", err
+		}
+	}
+	// 先解析外层布局，内层的define覆盖外层的block
+	for i := len(chain) - 1; i >= 0; i-- {
+		t := tpl
+		if chain[i] != root {
+			t = tpl.New(chain[i])
+		}
+		if _, err := t.Parse(contents[chain[i]]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	g.Lock()
+	if g.caching {
+		g.tplCache[fname] = tpl
+	}
+	g.Unlock()
+	return tpl, root, nil
+}
+
+// 模板目录下的全部局部模板
+func goTemplatePartials() []string {
+	var partials []string
+	for _, fname := range templateFiles([]string{GOTPL_EXT}, templateDirs...) {
+		if strings.HasPrefix(path.Base(fname), "_") {
+			partials = append(partials, fname)
+		}
+	}
+	return partials
+}
+
+// 预编译目录下的全部页面模板(GOTPL_EXT，局部模板除外)，并返回全部编译错误
+func (g *GoTemplateRender) Precompile(dirs ...string) error {
+	var errs TemplateErrors
+	for _, fname := range templateFiles([]string{GOTPL_EXT}, dirs...) {
+		if strings.HasPrefix(path.Base(fname), "_") {
+			continue
+		}
+		if _, _, err := g.compile(fname); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 监控目录下模板文件的变动，有变动时重新编译全部已缓存的模板，该方法会阻塞
+func (g *GoTemplateRender) Watch(interval time.Duration, dirs ...string) {
+	watchTemplates(interval, []string{GOTPL_EXT}, dirs, g.Reload)
+}
+
+// 重新编译全部已缓存的模板，编译失败的模板将移出缓存
+func (g *GoTemplateRender) Reload() error {
+	g.Lock()
+	fnames := make([]string, 0, len(g.tplCache))
+	for fname := range g.tplCache {
+		fnames = append(fnames, fname)
+	}
+	g.tplCache = make(map[string]*template.Template)
+	g.Unlock()
+	sort.Strings(fnames)
+
+	var errs TemplateErrors
+	for _, fname := range fnames {
+		if _, _, err := g.compile(fname); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// MultiRender 按模板文件的扩展名分派给已注册的渲染器，未注册的扩展名(如".html")交由默认渲染器，
+// 通过TemplateVariable注册的模板变量在全部渲染器间共享。
+type MultiRender struct {
+	engines   map[string]Renderer // 扩展名 => 渲染器
+	exts      []string
+	fallback  Renderer        // 默认渲染器
+	variables []multiVariable // 已注册的模板变量，新注册的渲染器将补注册
+	sync.RWMutex
+}
+
+type multiVariable struct {
+	name string
+	v    interface{}
+}
+
+// 支持缓存开关、预编译与热更新的渲染器
+type templateEngine interface {
+	SetCaching(caching bool)
+	Precompile(dirs ...string) error
+	Reload() error
+	Watch(interval time.Duration, dirs ...string)
+}
+
+// NewMultiRender creates a new MultiRender instance.
+func NewMultiRender() *MultiRender {
+	return &MultiRender{
+		engines: make(map[string]Renderer),
+	}
+}
+
+// 为扩展名(如".tpl")注册渲染器，并补注册已有的模板变量
+func (m *MultiRender) Register(ext string, r Renderer) {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.engines[ext]; !ok {
+		m.exts = append(m.exts, ext)
+	}
+	m.engines[ext] = r
+	for _, v := range m.variables {
+		r.TemplateVariable(v.name, v.v)
+	}
+}
+
+// 设置未注册扩展名的模板所用的默认渲染器，并补注册已有的模板变量(r已为某扩展名注册时除外)
+func (m *MultiRender) SetDefault(r Renderer) {
+	m.Lock()
+	defer m.Unlock()
+	m.fallback = r
+	for _, e := range m.engines {
+		if e == r {
+			return
+		}
+	}
+	for _, v := range m.variables {
+		r.TemplateVariable(v.name, v.v)
+	}
+}
+
+// 扩展名对应的渲染器，未注册时为默认渲染器
+func (m *MultiRender) Engine(ext string) Renderer {
+	m.RLock()
+	defer m.RUnlock()
+	if r, ok := m.engines[ext]; ok {
+		return r
+	}
+	return m.fallback
+}
+
+// 向全部渲染器注册模板变量
+func (m *MultiRender) TemplateVariable(name string, v interface{}) {
+	m.Lock()
+	defer m.Unlock()
+	m.variables = append(m.variables, multiVariable{name, v})
+	for _, r := range m.renderers() {
+		r.TemplateVariable(name, v)
+	}
+}
+
+// Render dispatches to the renderer registered for the extension of filename.
+func (m *MultiRender) Render(w io.Writer, filename string, data interface{}, c *Context) error {
+	r := m.Engine(path.Ext(filename))
+	if r == nil {
+		return fmt.Errorf("no renderer registered for %q", filename)
+	}
+	return r.Render(w, filename, data, c)
+}
+
+// 全部渲染器(含默认渲染器)，同一渲染器注册多个扩展名时只计一次，调用方需持有锁
+func (m *MultiRender) renderers() []Renderer {
+	var renders []Renderer
+	for _, ext := range m.exts {
+		if r := m.engines[ext]; !containsRenderer(renders, r) {
+			renders = append(renders, r)
+		}
+	}
+	if m.fallback != nil && !containsRenderer(renders, m.fallback) {
+		renders = append(renders, m.fallback)
+	}
+	return renders
+}
+
+func containsRenderer(renders []Renderer, r Renderer) bool {
+	for _, e := range renders {
+		if e == r {
+			return true
+		}
+	}
+	return false
+}
+
+// 全部渲染器的引擎
+func (m *MultiRender) templateEngines() []templateEngine {
+	m.RLock()
+	defer m.RUnlock()
+	var engines []templateEngine
+	for _, r := range m.renderers() {
+		if e, ok := r.(templateEngine); ok {
+			engines = append(engines, e)
+		}
+	}
+	return engines
+}
+
+// 运行时开启或关闭全部渲染器的模板缓存
+func (m *MultiRender) SetCaching(caching bool) {
+	for _, e := range m.templateEngines() {
+		e.SetCaching(caching)
+	}
+}
+
+// 预编译全部渲染器的模板，并返回全部编译错误
+func (m *MultiRender) Precompile(dirs ...string) error {
+	var errs TemplateErrors
+	for _, e := range m.templateEngines() {
+		if err := e.Precompile(dirs...); err != nil {
+			errs = append(errs, flattenTemplateErrors(err)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 重新编译全部渲染器已缓存的模板
+func (m *MultiRender) Reload() error {
+	var errs TemplateErrors
+	for _, e := range m.templateEngines() {
+		if err := e.Reload(); err != nil {
+			errs = append(errs, flattenTemplateErrors(err)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 监控目录下全部已注册扩展名的模板文件，该方法会阻塞
+func (m *MultiRender) Watch(interval time.Duration, dirs ...string) {
+	m.RLock()
+	exts := append([]string(nil), m.exts...)
+	m.RUnlock()
+	watchTemplates(interval, exts, dirs, m.Reload)
+}
+
+func flattenTemplateErrors(err error) TemplateErrors {
+	if errs, ok := err.(TemplateErrors); ok {
+		return errs
+	}
+	return TemplateErrors{err}
+}
@@ -0,0 +1,21 @@
+package lessgo
+
+import (
+	"github.com/henrylee2cn/lessgo/markdown"
+	"github.com/henrylee2cn/lessgo/pongo2"
+)
+
+// 模板过滤器sanitize使用的HTML过滤策略，默认为markdown.UGCPolicy()，
+// 允许markdown生成的常用标签及http、https、mailto与相对路径的链接
+var SanitizePolicy = markdown.UGCPolicy()
+
+func init() {
+	pongo2.RegisterFilter("sanitize", filterSanitize)
+}
+
+// 模板过滤器，按SanitizePolicy过滤用户提交的HTML，结果不再被自动转义：
+//
+//	{{ comment.Html|sanitize }}
+func filterSanitize(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	return pongo2.AsSafeValue(SanitizePolicy.SanitizeString(in.String())), nil
+}
@@ -0,0 +1,309 @@
+package lessgo
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CSP中的nonce占位来源，输出时替换为当前请求的'nonce-...'
+	CSPNonceSource = "'nonce'"
+	// 当前请求的CSP nonce在Context中的键
+	cspNonceContextKey = "_csp_nonce"
+	// 模板中CSP nonce的变量名，如<script nonce="{{ csp_nonce }}">
+	CSPNonceTplVar = "csp_nonce"
+	// CSP违规报告的最大字节数
+	cspReportMaxSize = 64 << 10
+)
+
+type (
+	// Content-Security-Policy的指令及其来源，如{"default-src": {"'self'"}, "script-src": {"'self'", CSPNonceSource}}，
+	// 无来源的指令(如upgrade-insecure-requests)仅输出指令名
+	CSP map[string][]string
+
+	// 安全响应头中间件的配置，字符串为空时不设置相应的响应头
+	SecurityHeadersConfig struct {
+		HSTSMaxAge            int    `json:"hstsMaxAge"`            // Strict-Transport-Security的max-age(秒)，仅用于https请求，0表示不设置
+		HSTSIncludeSubDomains bool   `json:"hstsIncludeSubDomains"` // HSTS是否包含子域名
+		HSTSPreload           bool   `json:"hstsPreload"`           // HSTS是否申请加入浏览器预载列表
+		ContentTypeNosniff    bool   `json:"contentTypeNosniff"`    // X-Content-Type-Options: nosniff
+		XSSProtection         string `json:"xssProtection"`         // X-XSS-Protection
+		FrameOptions          string `json:"frameOptions"`          // X-Frame-Options，如DENY、SAMEORIGIN
+		ReferrerPolicy        string `json:"referrerPolicy"`        // Referrer-Policy
+		CSP                   CSP    `json:"csp"`                   // Content-Security-Policy，为空时不设置
+		CSPReportOnly         bool   `json:"cspReportOnly"`         // 仅报告违规而不拦截(Content-Security-Policy-Report-Only)
+		CSPReportURI          string `json:"cspReportURI"`          // 违规报告的地址，如CSPReport操作的路由"/csp-report"
+	}
+)
+
+// 创建CSP
+func NewCSP() CSP {
+	return make(CSP)
+}
+
+// 为指令追加来源
+func (p CSP) Add(directive string, sources ...string) CSP {
+	p[directive] = append(p[directive], sources...)
+	return p
+}
+
+// 设置指令的来源，替换已有来源
+func (p CSP) Set(directive string, sources ...string) CSP {
+	p[directive] = append([]string{}, sources...)
+	return p
+}
+
+// 删除指令
+func (p CSP) Del(directive string) CSP {
+	delete(p, directive)
+	return p
+}
+
+// 是否使用nonce
+func (p CSP) HasNonce() bool {
+	for _, sources := range p {
+		for _, s := range sources {
+			if s == CSPNonceSource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 生成响应头的值，指令按名称排序(default-src居首)，CSPNonceSource替换为nonce
+func (p CSP) Build(nonce string) string {
+	directives := make([]string, 0, len(p))
+	for d := range p {
+		directives = append(directives, d)
+	}
+	sort.Slice(directives, func(i, j int) bool {
+		if directives[i] == "default-src" || directives[j] == "default-src" {
+			return directives[i] == "default-src"
+		}
+		return directives[i] < directives[j]
+	})
+	var buf []string
+	for _, d := range directives {
+		s := d
+		for _, src := range p[d] {
+			if src == CSPNonceSource {
+				if len(nonce) == 0 {
+					continue
+				}
+				src = "'nonce-" + nonce + "'"
+			}
+			s += " " + src
+		}
+		buf = append(buf, s)
+	}
+	return strings.Join(buf, "; ")
+}
+
+func (p CSP) String() string {
+	return p.Build("")
+}
+
+var SecurityHeaders = ApiMiddleware{
+	Name: "设置安全响应头",
+	Desc: "设置HSTS、X-Content-Type-Options、X-XSS-Protection、X-Frame-Options、Referrer-Policy及含每请求nonce的Content-Security-Policy",
+	Config: SecurityHeadersConfig{
+		HSTSMaxAge:            31536000,
+		HSTSIncludeSubDomains: true,
+		ContentTypeNosniff:    true,
+		XSSProtection:         "1; mode=block",
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		CSP: CSP{
+			"default-src": {"'self'"},
+			"script-src":  {"'self'", CSPNonceSource},
+			"style-src":   {"'self'", CSPNonceSource}, // 如markdown页面的内联<style>
+			"object-src":  {"'none'"},
+			"base-uri":    {"'self'"},
+		},
+	},
+	Middleware: func(confObject interface{}) MiddlewareFunc {
+		conf := confObject.(SecurityHeadersConfig)
+		var hsts string
+		if conf.HSTSMaxAge > 0 {
+			hsts = "max-age=" + strconv.Itoa(conf.HSTSMaxAge)
+			if conf.HSTSIncludeSubDomains {
+				hsts += "; includeSubDomains"
+			}
+			if conf.HSTSPreload {
+				hsts += "; preload"
+			}
+		}
+		csp := NewCSP()
+		for d, sources := range conf.CSP {
+			csp.Set(d, sources...)
+		}
+		if len(conf.CSPReportURI) > 0 && len(csp) > 0 {
+			csp.Set("report-uri", conf.CSPReportURI)
+		}
+		cspHeader := HeaderContentSecurityPolicy
+		if conf.CSPReportOnly {
+			cspHeader = HeaderContentSecurityPolicyReportOnly
+		}
+		// 不含nonce时预先生成
+		useNonce := csp.HasNonce()
+		var cspValue string
+		if !useNonce {
+			cspValue = csp.Build("")
+		}
+
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) error {
+				h := c.response.Header()
+				if len(hsts) > 0 && (c.IsTLS() || c.HeaderParam(HeaderXForwardedProto) == "https") {
+					h.Set(HeaderStrictTransportSecurity, hsts)
+				}
+				if conf.ContentTypeNosniff {
+					h.Set(HeaderXContentTypeOptions, "nosniff")
+				}
+				if len(conf.XSSProtection) > 0 {
+					h.Set(HeaderXXSSProtection, conf.XSSProtection)
+				}
+				if len(conf.FrameOptions) > 0 {
+					h.Set(HeaderXFrameOptions, conf.FrameOptions)
+				}
+				if len(conf.ReferrerPolicy) > 0 {
+					h.Set(HeaderReferrerPolicy, conf.ReferrerPolicy)
+				}
+				if useNonce {
+					nonce := newCSPNonce()
+					c.Set(cspNonceContextKey, nonce)
+					h.Set(cspHeader, csp.Build(nonce))
+				} else if len(cspValue) > 0 {
+					h.Set(cspHeader, cspValue)
+				}
+				return next(c)
+			}
+		}
+	},
+}.Reg()
+
+// 收集CSP违规报告并写入日志，需在路由中注册，并将其地址配置为SecurityHeadersConfig.CSPReportURI，
+// 支持report-uri的application/csp-report及Reporting API的application/reports+json格式
+var CSPReport = ApiHandler{
+	Desc:   "CSP违规报告",
+	Method: "POST",
+	Handler: func(c *Context) error {
+		b, err := ioutil.ReadAll(io.LimitReader(c.request.Body, cspReportMaxSize))
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		reports, err := parseCSPReports(b)
+		if err != nil {
+			Log.Warn("Invalid CSP report from %s: %v", c.RealRemoteAddr(), err)
+			return c.NoContent(http.StatusBadRequest)
+		}
+		for _, r := range reports {
+			Log.Warn("CSP violation from %s: %q blocked %q on %q%s",
+				c.RealRemoteAddr(), r.directive, r.blocked, r.document, r.location())
+		}
+		return c.NoContent(http.StatusNoContent)
+	},
+}.Reg()
+
+// 当前请求的CSP nonce，未使用nonce时为空
+func (c *Context) CSPNonce() string {
+	nonce, _ := c.Get(cspNonceContextKey).(string)
+	return nonce
+}
+
+// 一条CSP违规报告
+type cspViolation struct {
+	document  string
+	directive string
+	blocked   string
+	source    string
+	line      interface{}
+}
+
+// 违规代码的位置
+func (v cspViolation) location() string {
+	if len(v.source) == 0 {
+		return ""
+	}
+	if v.line == nil {
+		return " at " + v.source
+	}
+	return fmt.Sprintf(" at %s:%v", v.source, v.line)
+}
+
+// 解析report-uri的{"csp-report": {...}}或Reporting API的[{"type": "csp-violation", "body": {...}}]
+func parseCSPReports(b []byte) ([]cspViolation, error) {
+	var (
+		legacy struct {
+			Report map[string]interface{} `json:"csp-report"`
+		}
+		list []struct {
+			Type string                 `json:"type"`
+			Body map[string]interface{} `json:"body"`
+		}
+	)
+	if err := json.Unmarshal(b, &list); err == nil {
+		var reports []cspViolation
+		for _, r := range list {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, cspViolation{
+				document:  cspReportString(r.Body, "documentURL"),
+				directive: cspReportString(r.Body, "effectiveDirective"),
+				blocked:   cspReportString(r.Body, "blockedURL"),
+				source:    cspReportString(r.Body, "sourceFile"),
+				line:      r.Body["lineNumber"],
+			})
+		}
+		return reports, nil
+	}
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return nil, err
+	}
+	if legacy.Report == nil {
+		return nil, errors.New("no csp-report")
+	}
+	r := legacy.Report
+	directive := cspReportString(r, "effective-directive")
+	if len(directive) == 0 {
+		directive = cspReportString(r, "violated-directive")
+	}
+	return []cspViolation{{
+		document:  cspReportString(r, "document-uri"),
+		directive: directive,
+		blocked:   cspReportString(r, "blocked-uri"),
+		source:    cspReportString(r, "source-file"),
+		line:      r["line-number"],
+	}}, nil
+}
+
+func cspReportString(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("lessgo: failed to generate csp nonce: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// 注册CSP nonce的模板变量
+func registerCSPTemplateVariables(r Renderer) {
+	r.TemplateVariable(CSPNonceTplVar, func(c *Context) interface{} {
+		return c.CSPNonce()
+	})
+}
@@ -36,6 +36,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	couchbase "github.com/couchbase/go-couchbase"
 
@@ -50,12 +51,13 @@ type SessionStore struct {
 	sid         string
 	lock        sync.RWMutex
 	values      map[interface{}]interface{}
+	dirty       bool // the values have been changed since read
 	maxlifetime int64
 }
 
 // Provider couchabse provided
 type Provider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	savePath    string
 	pool        string
 	bucket      string
@@ -67,6 +69,7 @@ func (cs *SessionStore) Set(key, value interface{}) {
 	cs.lock.Lock()
 	defer cs.lock.Unlock()
 	cs.values[key] = value
+	cs.dirty = true
 }
 
 // Get value from couchabse session
@@ -84,6 +87,7 @@ func (cs *SessionStore) Delete(key interface{}) {
 	cs.lock.Lock()
 	defer cs.lock.Unlock()
 	delete(cs.values, key)
+	cs.dirty = true
 }
 
 // Flush Clean all values in couchbase session
@@ -91,6 +95,7 @@ func (cs *SessionStore) Flush() {
 	cs.lock.Lock()
 	defer cs.lock.Unlock()
 	cs.values = make(map[interface{}]interface{})
+	cs.dirty = true
 }
 
 // SessionID Get couchbase session store id
@@ -98,16 +103,25 @@ func (cs *SessionStore) SessionID() string {
 	return cs.sid
 }
 
-// SessionRelease Write couchbase session with Gob string
+// SessionRelease Write couchbase session with the configured serializer,
+// only the expiry is renewed if the values have no changes.
 func (cs *SessionStore) SessionRelease(w http.ResponseWriter) {
 	defer cs.b.Close()
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if !cs.dirty {
+		cs.b.GetAndTouchRaw(cs.sid, int(cs.maxlifetime))
+		return
+	}
 
-	bo, err := session.EncodeGob(cs.values)
+	bo, err := session.EncodeValues(cs.values)
 	if err != nil {
 		return
 	}
 
-	cs.b.Set(cs.sid, int(cs.maxlifetime), bo)
+	if cs.b.Set(cs.sid, int(cs.maxlifetime), bo) == nil {
+		cs.dirty = false
+	}
 }
 
 func (cp *Provider) getBucket() *couchbase.Bucket {
@@ -133,7 +147,7 @@ func (cp *Provider) getBucket() *couchbase.Bucket {
 // savepath like couchbase server REST/JSON URL
 // e.g. http://host:port/, Pool, Bucket
 func (cp *Provider) SessionInit(maxlifetime int64, savePath string) error {
-	cp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&cp.maxlifetime, maxlifetime)
 	configs := strings.Split(savePath, ",")
 	if len(configs) > 0 {
 		cp.savePath = configs[0]
@@ -148,6 +162,11 @@ func (cp *Provider) SessionInit(maxlifetime int64, savePath string) error {
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (cp *Provider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&cp.maxlifetime, maxlifetime)
+}
+
 // SessionRead read couchbase session by sid
 func (cp *Provider) SessionRead(sid string) (session.Store, error) {
 	cp.b = cp.getBucket()
@@ -159,13 +178,14 @@ func (cp *Provider) SessionRead(sid string) (session.Store, error) {
 	if doc == nil {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(doc)
+		kv, err = session.DecodeValues(doc)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	cs := &SessionStore{b: cp.b, sid: sid, values: kv, maxlifetime: cp.maxlifetime}
+	// a new session is saved even if it has no values
+	cs := &SessionStore{b: cp.b, sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&cp.maxlifetime), dirty: doc == nil}
 	return cs, nil
 }
 
@@ -189,13 +209,13 @@ func (cp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 
 	var doc []byte
 	if err := cp.b.Get(oldsid, &doc); err != nil || doc == nil {
-		cp.b.Set(sid, int(cp.maxlifetime), "")
+		cp.b.Set(sid, int(atomic.LoadInt64(&cp.maxlifetime)), "")
 	} else {
 		err := cp.b.Delete(oldsid)
 		if err != nil {
 			return nil, err
 		}
-		_, _ = cp.b.Add(sid, int(cp.maxlifetime), doc)
+		_, _ = cp.b.Add(sid, int(atomic.LoadInt64(&cp.maxlifetime)), doc)
 	}
 
 	err := cp.b.Get(sid, &doc)
@@ -206,13 +226,13 @@ func (cp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 	if doc == nil {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(doc)
+		kv, err = session.DecodeValues(doc)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	cs := &SessionStore{b: cp.b, sid: sid, values: kv, maxlifetime: cp.maxlifetime}
+	cs := &SessionStore{b: cp.b, sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&cp.maxlifetime)}
 	return cs, nil
 }
 
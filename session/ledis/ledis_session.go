@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/henrylee2cn/lessgo/session"
 	"github.com/siddontang/ledisdb/config"
@@ -20,6 +21,7 @@ type SessionStore struct {
 	sid         string
 	lock        sync.RWMutex
 	values      map[interface{}]interface{}
+	dirty       bool // the values have been changed since read
 	maxlifetime int64
 }
 
@@ -28,6 +30,7 @@ func (ls *SessionStore) Set(key, value interface{}) {
 	ls.lock.Lock()
 	defer ls.lock.Unlock()
 	ls.values[key] = value
+	ls.dirty = true
 }
 
 // Get value in ledis session
@@ -45,6 +48,7 @@ func (ls *SessionStore) Delete(key interface{}) {
 	ls.lock.Lock()
 	defer ls.lock.Unlock()
 	delete(ls.values, key)
+	ls.dirty = true
 }
 
 // Flush clear all values in ledis session
@@ -52,6 +56,7 @@ func (ls *SessionStore) Flush() {
 	ls.lock.Lock()
 	defer ls.lock.Unlock()
 	ls.values = make(map[interface{}]interface{})
+	ls.dirty = true
 }
 
 // SessionID get ledis session id
@@ -59,19 +64,26 @@ func (ls *SessionStore) SessionID() string {
 	return ls.sid
 }
 
-// SessionRelease save session values to ledis
+// SessionRelease save session values to ledis,
+// only the expiry is renewed if the values have no changes.
 func (ls *SessionStore) SessionRelease(w http.ResponseWriter) {
-	b, err := session.EncodeGob(ls.values)
-	if err != nil {
-		return
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	if ls.dirty {
+		b, err := session.EncodeValues(ls.values)
+		if err != nil {
+			return
+		}
+		if c.Set([]byte(ls.sid), b) == nil {
+			ls.dirty = false
+		}
 	}
-	c.Set([]byte(ls.sid), b)
 	c.Expire([]byte(ls.sid), ls.maxlifetime)
 }
 
 // Provider ledis session provider
 type Provider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	savePath    string
 	db          int
 }
@@ -81,7 +93,7 @@ type Provider struct {
 // e.g. 127.0.0.1:6379,100,astaxie
 func (lp *Provider) SessionInit(maxlifetime int64, savePath string) error {
 	var err error
-	lp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&lp.maxlifetime, maxlifetime)
 	configs := strings.Split(savePath, ",")
 	if len(configs) == 1 {
 		lp.savePath = configs[0]
@@ -103,6 +115,11 @@ func (lp *Provider) SessionInit(maxlifetime int64, savePath string) error {
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (lp *Provider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&lp.maxlifetime, maxlifetime)
+}
+
 // SessionRead read ledis session by sid
 func (lp *Provider) SessionRead(sid string) (session.Store, error) {
 	kvs, err := c.Get([]byte(sid))
@@ -110,12 +127,13 @@ func (lp *Provider) SessionRead(sid string) (session.Store, error) {
 	if len(kvs) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(kvs)
+		kv, err = session.DecodeValues(kvs)
 		if err != nil {
 			return nil, err
 		}
 	}
-	ls := &SessionStore{sid: sid, values: kv, maxlifetime: lp.maxlifetime}
+	// a new session is saved even if it has no values
+	ls := &SessionStore{sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&lp.maxlifetime), dirty: len(kvs) == 0}
 	return ls, nil
 }
 
@@ -136,23 +154,23 @@ func (lp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 		// ignore error here, since if it return error
 		// the existed value will be 0
 		c.Set([]byte(sid), []byte(""))
-		c.Expire([]byte(sid), lp.maxlifetime)
+		c.Expire([]byte(sid), atomic.LoadInt64(&lp.maxlifetime))
 	} else {
 		data, _ := c.Get([]byte(oldsid))
 		c.Set([]byte(sid), data)
-		c.Expire([]byte(sid), lp.maxlifetime)
+		c.Expire([]byte(sid), atomic.LoadInt64(&lp.maxlifetime))
 	}
 	kvs, err := c.Get([]byte(sid))
 	var kv map[interface{}]interface{}
 	if len(kvs) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob([]byte(kvs))
+		kv, err = session.DecodeValues([]byte(kvs))
 		if err != nil {
 			return nil, err
 		}
 	}
-	ls := &SessionStore{sid: sid, values: kv, maxlifetime: lp.maxlifetime}
+	ls := &SessionStore{sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&lp.maxlifetime)}
 	return ls, nil
 }
 
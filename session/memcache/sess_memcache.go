@@ -36,6 +36,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/henrylee2cn/lessgo/session"
 
@@ -50,6 +51,7 @@ type SessionStore struct {
 	sid         string
 	lock        sync.RWMutex
 	values      map[interface{}]interface{}
+	dirty       bool // the values have been changed since read
 	maxlifetime int64
 }
 
@@ -58,6 +60,7 @@ func (rs *SessionStore) Set(key, value interface{}) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
 	rs.values[key] = value
+	rs.dirty = true
 }
 
 // Get value in memcache session
@@ -75,6 +78,7 @@ func (rs *SessionStore) Delete(key interface{}) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
 	delete(rs.values, key)
+	rs.dirty = true
 }
 
 // Flush clear all values in memcache session
@@ -82,6 +86,7 @@ func (rs *SessionStore) Flush() {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
 	rs.values = make(map[interface{}]interface{})
+	rs.dirty = true
 }
 
 // SessionID get memcache session id
@@ -89,19 +94,28 @@ func (rs *SessionStore) SessionID() string {
 	return rs.sid
 }
 
-// SessionRelease save session values to memcache
+// SessionRelease save session values to memcache,
+// only the expiry is renewed if the values have no changes.
 func (rs *SessionStore) SessionRelease(w http.ResponseWriter) {
-	b, err := session.EncodeGob(rs.values)
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if !rs.dirty {
+		client.Touch(rs.sid, int32(rs.maxlifetime))
+		return
+	}
+	b, err := session.EncodeValues(rs.values)
 	if err != nil {
 		return
 	}
 	item := memcache.Item{Key: rs.sid, Value: b, Expiration: int32(rs.maxlifetime)}
-	client.Set(&item)
+	if client.Set(&item) == nil {
+		rs.dirty = false
+	}
 }
 
 // MemProvider memcache session provider
 type MemProvider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	conninfo    []string
 	poolsize    int
 	password    string
@@ -111,12 +125,17 @@ type MemProvider struct {
 // savepath like
 // e.g. 127.0.0.1:9090
 func (rp *MemProvider) SessionInit(maxlifetime int64, savePath string) error {
-	rp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&rp.maxlifetime, maxlifetime)
 	rp.conninfo = strings.Split(savePath, ";")
 	client = memcache.New(rp.conninfo...)
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (rp *MemProvider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&rp.maxlifetime, maxlifetime)
+}
+
 // SessionRead read memcache session by sid
 func (rp *MemProvider) SessionRead(sid string) (session.Store, error) {
 	if client == nil {
@@ -126,19 +145,19 @@ func (rp *MemProvider) SessionRead(sid string) (session.Store, error) {
 	}
 	item, err := client.Get(sid)
 	if err != nil && err == memcache.ErrCacheMiss {
-		rs := &SessionStore{sid: sid, values: make(map[interface{}]interface{}), maxlifetime: rp.maxlifetime}
+		rs := &SessionStore{sid: sid, values: make(map[interface{}]interface{}), maxlifetime: atomic.LoadInt64(&rp.maxlifetime), dirty: true}
 		return rs, nil
 	}
 	var kv map[interface{}]interface{}
 	if len(item.Value) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(item.Value)
+		kv, err = session.DecodeValues(item.Value)
 		if err != nil {
 			return nil, err
 		}
 	}
-	rs := &SessionStore{sid: sid, values: kv, maxlifetime: rp.maxlifetime}
+	rs := &SessionStore{sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&rp.maxlifetime)}
 	return rs, nil
 }
 
@@ -169,12 +188,12 @@ func (rp *MemProvider) SessionRegenerate(oldsid, sid string) (session.Store, err
 		// the existed value will be 0
 		item.Key = sid
 		item.Value = []byte("")
-		item.Expiration = int32(rp.maxlifetime)
+		item.Expiration = int32(atomic.LoadInt64(&rp.maxlifetime))
 		client.Set(item)
 	} else {
 		client.Delete(oldsid)
 		item.Key = sid
-		item.Expiration = int32(rp.maxlifetime)
+		item.Expiration = int32(atomic.LoadInt64(&rp.maxlifetime))
 		client.Set(item)
 		contain = item.Value
 	}
@@ -184,13 +203,13 @@ func (rp *MemProvider) SessionRegenerate(oldsid, sid string) (session.Store, err
 		kv = make(map[interface{}]interface{})
 	} else {
 		var err error
-		kv, err = session.DecodeGob(contain)
+		kv, err = session.DecodeValues(contain)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	rs := &SessionStore{sid: sid, values: kv, maxlifetime: rp.maxlifetime}
+	rs := &SessionStore{sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&rp.maxlifetime)}
 	return rs, nil
 }
 
@@ -14,6 +14,8 @@
 
 // Package mysql for session provider
 //
+// see also the package sqldb, which works with any database/sql driver and creates the table.
+//
 // depends on github.com/go-sql-driver/mysql:
 //
 // go install github.com/go-sql-driver/mysql
@@ -44,6 +46,7 @@ import (
 	"database/sql"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/henrylee2cn/lessgo/session"
@@ -63,6 +66,7 @@ type SessionStore struct {
 	sid    string
 	lock   sync.RWMutex
 	values map[interface{}]interface{}
+	dirty  bool // the values have been changed since read
 }
 
 // Set value in mysql session.
@@ -71,6 +75,7 @@ func (st *SessionStore) Set(key, value interface{}) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	st.values[key] = value
+	st.dirty = true
 }
 
 // Get value from mysql session
@@ -88,6 +93,7 @@ func (st *SessionStore) Delete(key interface{}) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	delete(st.values, key)
+	st.dirty = true
 }
 
 // Flush clear all values in mysql session
@@ -95,6 +101,7 @@ func (st *SessionStore) Flush() {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	st.values = make(map[interface{}]interface{})
+	st.dirty = true
 }
 
 // SessionID get session id of this mysql session store
@@ -104,19 +111,29 @@ func (st *SessionStore) SessionID() string {
 
 // SessionRelease save mysql session values to database.
 // must call this method to save values to database.
+// only the expiry is updated if the values have no changes.
 func (st *SessionStore) SessionRelease(w http.ResponseWriter) {
 	defer st.c.Close()
-	b, err := session.EncodeGob(st.values)
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if !st.dirty {
+		st.c.Exec("UPDATE "+TableName+" set `session_expiry`=? where session_key=?",
+			time.Now().Unix(), st.sid)
+		return
+	}
+	b, err := session.EncodeValues(st.values)
 	if err != nil {
 		return
 	}
-	st.c.Exec("UPDATE "+TableName+" set `session_data`=?, `session_expiry`=? where session_key=?",
-		b, time.Now().Unix(), st.sid)
+	if _, err = st.c.Exec("UPDATE "+TableName+" set `session_data`=?, `session_expiry`=? where session_key=?",
+		b, time.Now().Unix(), st.sid); err == nil {
+		st.dirty = false
+	}
 }
 
 // Provider mysql session provider
 type Provider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	savePath    string
 }
 
@@ -132,11 +149,16 @@ func (mp *Provider) connectInit() *sql.DB {
 // SessionInit init mysql session.
 // savepath is the connection string of mysql.
 func (mp *Provider) SessionInit(maxlifetime int64, savePath string) error {
-	mp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&mp.maxlifetime, maxlifetime)
 	mp.savePath = savePath
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (mp *Provider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&mp.maxlifetime, maxlifetime)
+}
+
 // SessionRead get mysql session by sid
 func (mp *Provider) SessionRead(sid string) (session.Store, error) {
 	c := mp.connectInit()
@@ -151,7 +173,7 @@ func (mp *Provider) SessionRead(sid string) (session.Store, error) {
 	if len(sessiondata) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(sessiondata)
+		kv, err = session.DecodeValues(sessiondata)
 		if err != nil {
 			return nil, err
 		}
@@ -187,7 +209,7 @@ func (mp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 	if len(sessiondata) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(sessiondata)
+		kv, err = session.DecodeValues(sessiondata)
 		if err != nil {
 			return nil, err
 		}
@@ -207,7 +229,7 @@ func (mp *Provider) SessionDestroy(sid string) error {
 // SessionGC delete expired values in mysql session
 func (mp *Provider) SessionGC() {
 	c := mp.connectInit()
-	c.Exec("DELETE from "+TableName+" where session_expiry < ?", time.Now().Unix()-mp.maxlifetime)
+	c.Exec("DELETE from "+TableName+" where session_expiry < ?", time.Now().Unix()-atomic.LoadInt64(&mp.maxlifetime))
 	c.Close()
 	return
 }
@@ -224,6 +246,39 @@ func (mp *Provider) SessionAll() int {
 	return total
 }
 
+// SessionIterate calls fn for each active mysql session until fn returns false,
+// session_expiry is the last access time.
+func (mp *Provider) SessionIterate(fn func(session.SessionInfo) bool) error {
+	c := mp.connectInit()
+	defer c.Close()
+	rows, err := c.Query("select session_key, session_data, session_expiry from "+TableName+" where session_expiry >= ?",
+		time.Now().Unix()-atomic.LoadInt64(&mp.maxlifetime))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sid         string
+			sessiondata []byte
+			expiry      int64
+		)
+		if err = rows.Scan(&sid, &sessiondata, &expiry); err != nil {
+			return err
+		}
+		kv := make(map[interface{}]interface{})
+		if len(sessiondata) > 0 {
+			if kv, err = session.DecodeValues(sessiondata); err != nil {
+				continue
+			}
+		}
+		if !fn(session.NewSessionInfo(sid, time.Unix(expiry, 0), kv)) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
 func init() {
 	session.Register("mysql", mysqlpder)
 }
@@ -14,6 +14,8 @@
 
 // Package postgres for session provider
 //
+// see also the package sqldb, which works with any database/sql driver and creates the table.
+//
 // depends on github.com/lib/pq:
 //
 // go install github.com/lib/pq
@@ -53,7 +55,9 @@ package postgres
 import (
 	"database/sql"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/henrylee2cn/lessgo/session"
@@ -69,6 +73,7 @@ type SessionStore struct {
 	sid    string
 	lock   sync.RWMutex
 	values map[interface{}]interface{}
+	dirty  bool // the values have been changed since read
 }
 
 // Set value in postgresql session.
@@ -77,6 +82,7 @@ func (st *SessionStore) Set(key, value interface{}) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	st.values[key] = value
+	st.dirty = true
 }
 
 // Get value from postgresql session
@@ -94,6 +100,7 @@ func (st *SessionStore) Delete(key interface{}) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	delete(st.values, key)
+	st.dirty = true
 }
 
 // Flush clear all values in postgresql session
@@ -101,6 +108,7 @@ func (st *SessionStore) Flush() {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	st.values = make(map[interface{}]interface{})
+	st.dirty = true
 }
 
 // SessionID get session id of this postgresql session store
@@ -110,20 +118,29 @@ func (st *SessionStore) SessionID() string {
 
 // SessionRelease save postgresql session values to database.
 // must call this method to save values to database.
+// only the expiry is updated if the values have no changes.
 func (st *SessionStore) SessionRelease(w http.ResponseWriter) {
 	defer st.c.Close()
-	b, err := session.EncodeGob(st.values)
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if !st.dirty {
+		st.c.Exec("UPDATE session set session_expiry=$1 where session_key=$2",
+			time.Now().Format(time.RFC3339), st.sid)
+		return
+	}
+	b, err := session.EncodeValues(st.values)
 	if err != nil {
 		return
 	}
-	st.c.Exec("UPDATE session set session_data=$1, session_expiry=$2 where session_key=$3",
-		b, time.Now().Format(time.RFC3339), st.sid)
-
+	if _, err = st.c.Exec("UPDATE session set session_data=$1, session_expiry=$2 where session_key=$3",
+		b, time.Now().Format(time.RFC3339), st.sid); err == nil {
+		st.dirty = false
+	}
 }
 
 // Provider postgresql session provider
 type Provider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	savePath    string
 }
 
@@ -139,11 +156,16 @@ func (mp *Provider) connectInit() *sql.DB {
 // SessionInit init postgresql session.
 // savepath is the connection string of postgresql.
 func (mp *Provider) SessionInit(maxlifetime int64, savePath string) error {
-	mp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&mp.maxlifetime, maxlifetime)
 	mp.savePath = savePath
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (mp *Provider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&mp.maxlifetime, maxlifetime)
+}
+
 // SessionRead get postgresql session by sid
 func (mp *Provider) SessionRead(sid string) (session.Store, error) {
 	c := mp.connectInit()
@@ -165,7 +187,7 @@ func (mp *Provider) SessionRead(sid string) (session.Store, error) {
 	if len(sessiondata) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(sessiondata)
+		kv, err = session.DecodeValues(sessiondata)
 		if err != nil {
 			return nil, err
 		}
@@ -203,7 +225,7 @@ func (mp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 	if len(sessiondata) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(sessiondata)
+		kv, err = session.DecodeValues(sessiondata)
 		if err != nil {
 			return nil, err
 		}
@@ -223,7 +245,7 @@ func (mp *Provider) SessionDestroy(sid string) error {
 // SessionGC delete expired values in postgresql session
 func (mp *Provider) SessionGC() {
 	c := mp.connectInit()
-	c.Exec("DELETE from session where EXTRACT(EPOCH FROM (current_timestamp - session_expiry)) > $1", mp.maxlifetime)
+	c.Exec("DELETE from session where EXTRACT(EPOCH FROM (current_timestamp - session_expiry)) > $1", atomic.LoadInt64(&mp.maxlifetime))
 	c.Close()
 	return
 }
@@ -240,6 +262,39 @@ func (mp *Provider) SessionAll() int {
 	return total
 }
 
+// SessionIterate calls fn for each active postgresql session until fn returns false,
+// session_expiry is the last access time.
+func (mp *Provider) SessionIterate(fn func(session.SessionInfo) bool) error {
+	c := mp.connectInit()
+	defer c.Close()
+	rows, err := c.Query("select session_key, session_data, session_expiry from session where EXTRACT(EPOCH FROM (current_timestamp - session_expiry)) <= $1",
+		atomic.LoadInt64(&mp.maxlifetime))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sid         string
+			sessiondata []byte
+			expiry      time.Time
+		)
+		if err = rows.Scan(&sid, &sessiondata, &expiry); err != nil {
+			return err
+		}
+		kv := make(map[interface{}]interface{})
+		if len(sessiondata) > 0 {
+			if kv, err = session.DecodeValues(sessiondata); err != nil {
+				continue
+			}
+		}
+		if !fn(session.NewSessionInfo(strings.TrimSpace(sid), expiry, kv)) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
 func init() {
 	session.Register("postgresql", postgresqlpder)
 }
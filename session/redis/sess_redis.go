@@ -37,6 +37,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/henrylee2cn/lessgo/session"
 
@@ -54,6 +55,7 @@ type SessionStore struct {
 	sid         string
 	lock        sync.RWMutex
 	values      map[interface{}]interface{}
+	dirty       bool // the values have been changed since read
 	maxlifetime int64
 }
 
@@ -62,6 +64,7 @@ func (rs *SessionStore) Set(key, value interface{}) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
 	rs.values[key] = value
+	rs.dirty = true
 }
 
 // Get value in redis session
@@ -79,6 +82,7 @@ func (rs *SessionStore) Delete(key interface{}) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
 	delete(rs.values, key)
+	rs.dirty = true
 }
 
 // Flush clear all values in redis session
@@ -86,6 +90,7 @@ func (rs *SessionStore) Flush() {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
 	rs.values = make(map[interface{}]interface{})
+	rs.dirty = true
 }
 
 // SessionID get redis session id
@@ -93,20 +98,29 @@ func (rs *SessionStore) SessionID() string {
 	return rs.sid
 }
 
-// SessionRelease save session values to redis
+// SessionRelease save session values to redis,
+// only the expiry is renewed if the values have no changes.
 func (rs *SessionStore) SessionRelease(w http.ResponseWriter) {
-	b, err := session.EncodeGob(rs.values)
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	c := rs.p.Get()
+	defer c.Close()
+	if !rs.dirty {
+		c.Do("EXPIRE", rs.sid, rs.maxlifetime)
+		return
+	}
+	b, err := session.EncodeValues(rs.values)
 	if err != nil {
 		return
 	}
-	c := rs.p.Get()
-	defer c.Close()
-	c.Do("SETEX", rs.sid, rs.maxlifetime, string(b))
+	if _, err = c.Do("SETEX", rs.sid, rs.maxlifetime, string(b)); err == nil {
+		rs.dirty = false
+	}
 }
 
 // Provider redis session provider
 type Provider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	savePath    string
 	poolsize    int
 	password    string
@@ -118,7 +132,7 @@ type Provider struct {
 // savepath like redis server addr,pool size,password,dbnum
 // e.g. 127.0.0.1:6379,100,astaxie,0
 func (rp *Provider) SessionInit(maxlifetime int64, savePath string) error {
-	rp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&rp.maxlifetime, maxlifetime)
 	configs := strings.Split(savePath, ",")
 	if len(configs) > 0 {
 		rp.savePath = configs[0]
@@ -168,6 +182,11 @@ func (rp *Provider) SessionInit(maxlifetime int64, savePath string) error {
 	return rp.poollist.Get().Err()
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (rp *Provider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&rp.maxlifetime, maxlifetime)
+}
+
 // SessionRead read redis session by sid
 func (rp *Provider) SessionRead(sid string) (session.Store, error) {
 	c := rp.poollist.Get()
@@ -178,13 +197,14 @@ func (rp *Provider) SessionRead(sid string) (session.Store, error) {
 	if len(kvs) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob([]byte(kvs))
+		kv, err = session.DecodeValues([]byte(kvs))
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	rs := &SessionStore{p: rp.poollist, sid: sid, values: kv, maxlifetime: rp.maxlifetime}
+	// a new session is saved even if it has no values
+	rs := &SessionStore{p: rp.poollist, sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&rp.maxlifetime), dirty: len(kvs) == 0}
 	return rs, nil
 }
 
@@ -208,10 +228,10 @@ func (rp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 		// oldsid doesn't exists, set the new sid directly
 		// ignore error here, since if it return error
 		// the existed value will be 0
-		c.Do("SET", sid, "", "EX", rp.maxlifetime)
+		c.Do("SET", sid, "", "EX", atomic.LoadInt64(&rp.maxlifetime))
 	} else {
 		c.Do("RENAME", oldsid, sid)
-		c.Do("EXPIRE", sid, rp.maxlifetime)
+		c.Do("EXPIRE", sid, atomic.LoadInt64(&rp.maxlifetime))
 	}
 
 	kvs, err := redis.String(c.Do("GET", sid))
@@ -219,13 +239,13 @@ func (rp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error)
 	if len(kvs) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob([]byte(kvs))
+		kv, err = session.DecodeValues([]byte(kvs))
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	rs := &SessionStore{p: rp.poollist, sid: sid, values: kv, maxlifetime: rp.maxlifetime}
+	rs := &SessionStore{p: rp.poollist, sid: sid, values: kv, maxlifetime: atomic.LoadInt64(&rp.maxlifetime)}
 	return rs, nil
 }
 
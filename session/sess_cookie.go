@@ -18,9 +18,12 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var cookiepder = &CookieProvider{}
@@ -64,24 +67,45 @@ func (st *CookieSessionStore) Flush() {
 	st.values = make(map[interface{}]interface{})
 }
 
+// Values returns a copy of the values of cookie session.
+func (st *CookieSessionStore) Values() map[interface{}]interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	values := make(map[interface{}]interface{}, len(st.values))
+	for k, v := range st.values {
+		values[k] = v
+	}
+	return values
+}
+
 // SessionID Return id of this cookie session
 func (st *CookieSessionStore) SessionID() string {
 	return st.sid
 }
 
-// SessionRelease Write cookie session to http response cookie
+// SessionRelease Write cookie session to http response cookie.
+// The cookie is not written if the encrypted values exceed MaxCookieSize.
+// It is written even if the values have no changes, to renew the expiry.
 func (st *CookieSessionStore) SessionRelease(w http.ResponseWriter) {
-	str, err := encodeCookie(cookiepder.block,
-		cookiepder.config.SecurityKey,
-		cookiepder.config.SecurityName,
-		st.values)
+	st.lock.RLock()
+	b, err := EncodeValues(st.values)
+	st.lock.RUnlock()
+	if err != nil {
+		SLogger.Println(err)
+		return
+	}
+	name := cookiepder.cookieName()
+	str, err := cookiepder.codec.Encode(name, b)
 	if err != nil {
+		SLogger.Println(err)
 		return
 	}
-	cookie := &http.Cookie{Name: CookieName,
+	cookie := &http.Cookie{Name: name,
 		Value:    url.QueryEscape(str),
-		Path:     "/",
-		HttpOnly: true,
+		Path:     cookieAttrs.Path,
+		Domain:   cookieAttrs.Domain,
+		HttpOnly: cookieAttrs.HttpOnly,
+		SameSite: cookieAttrs.SameSite,
 		Secure:   cookiepder.config.Secure,
 		MaxAge:   cookiepder.config.Maxage}
 	http.SetCookie(w, cookie)
@@ -89,29 +113,35 @@ func (st *CookieSessionStore) SessionRelease(w http.ResponseWriter) {
 }
 
 type cookieConfig struct {
-	SecurityKey  string `json:"securityKey"`
-	BlockKey     string `json:"blockKey"`
-	SecurityName string `json:"securityName"`
-	Secure       bool   `json:"secure"`
-	Maxage       int    `json:"maxage"`
+	Keys         []string `json:"keys"`
+	SecurityKey  string   `json:"securityKey"`
+	BlockKey     string   `json:"blockKey"`
+	SecurityName string   `json:"securityName"`
+	CookieName   string   `json:"cookieName"`
+	Secure       bool     `json:"secure"`
+	Maxage       int      `json:"maxage"`
 }
 
 // CookieProvider Cookie session provider
 type CookieProvider struct {
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	config      *cookieConfig
-	block       cipher.Block
+	codec       *SecureCookie
+	legacy      cipher.Block     // decrypts the cookies of the old format, nil if not configured
+	revoked     map[string]int64 // user => revoked time (UnixNano)
+	revokedLock sync.RWMutex
 }
 
 var CookieName string
 
 // SessionInit Init cookie session provider with max lifetime and config json.
-// maxlifetime is ignored.
+// The values are encrypted with AES-GCM (see SecureCookie) and expire after maxlifetime.
 // json config:
-// 	securityKey - hash string
-// 	blockKey - gob encode hash string. it's saved as aes crypto.
-// 	securityName - recognized name in encoded cookie string
-// 	cookieName - cookie name
+// 	keys - the key ring, newest first. the newest key encrypts, all the keys decrypt.
+// 	securityKey - a key appended to the ring, a random key is used if there is no key at all.
+// 	blockKey, securityName - decrypt the cookies of the old (AES-CTR and HMAC) format,
+// 	  which are encrypted again with the key ring on release.
+// 	cookieName - cookie name, the cookieName of the manager by default
 // 	maxage - cookie max life time.
 func (pder *CookieProvider) SessionInit(maxlifetime int64, config string) error {
 	pder.config = &cookieConfig{}
@@ -119,28 +149,71 @@ func (pder *CookieProvider) SessionInit(maxlifetime int64, config string) error
 	if err != nil {
 		return err
 	}
-	if pder.config.BlockKey == "" {
-		pder.config.BlockKey = string(generateRandomKey(16))
+	keys := pder.config.Keys
+	if pder.config.SecurityKey != "" {
+		keys = append(keys, pder.config.SecurityKey)
 	}
-	if pder.config.SecurityName == "" {
-		pder.config.SecurityName = string(generateRandomKey(20))
+	if len(keys) == 0 {
+		// the sessions are lost when the process restarts
+		keys = []string{string(generateRandomKey(32))}
 	}
-	pder.block, err = aes.NewCipher([]byte(pder.config.BlockKey))
-	if err != nil {
+	if pder.codec, err = NewSecureCookie(maxlifetime, keys...); err != nil {
 		return err
 	}
-	pder.maxlifetime = maxlifetime
+	pder.legacy = nil
+	if pder.config.BlockKey != "" && pder.config.SecurityName != "" {
+		if pder.legacy, err = aes.NewCipher([]byte(pder.config.BlockKey)); err != nil {
+			return err
+		}
+	}
+	atomic.StoreInt64(&pder.maxlifetime, maxlifetime)
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (pder *CookieProvider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&pder.maxlifetime, maxlifetime)
+	if pder.codec != nil {
+		pder.codec.SetMaxAge(maxlifetime)
+	}
+}
+
+// SetKeys replaces the key ring at runtime, newest first.
+func (pder *CookieProvider) SetKeys(keys ...string) error {
+	if pder.codec == nil {
+		return errors.New("session: the cookie provider is not initialized")
+	}
+	return pder.codec.SetKeys(keys...)
+}
+
+// cookieName returns the name of the session cookie.
+func (pder *CookieProvider) cookieName() string {
+	if pder.config.CookieName != "" {
+		return pder.config.CookieName
+	}
+	return CookieName
+}
+
+// decode decrypts the session values, falls back to the old format if configured.
+func (pder *CookieProvider) decode(sid string) (map[interface{}]interface{}, error) {
+	b, err := pder.codec.Decode(pder.cookieName(), sid)
+	if err == nil {
+		return DecodeValues(b)
+	}
+	if err == ErrInvalidCookie && pder.legacy != nil {
+		return decodeCookie(pder.legacy,
+			pder.config.SecurityKey,
+			pder.config.SecurityName,
+			sid, atomic.LoadInt64(&pder.maxlifetime))
+	}
+	return nil, err
+}
+
 // SessionRead Get SessionStore in cooke.
 // decode cooke string to map and put into SessionStore with sid.
 func (pder *CookieProvider) SessionRead(sid string) (Store, error) {
-	maps, _ := decodeCookie(pder.block,
-		pder.config.SecurityKey,
-		pder.config.SecurityName,
-		sid, pder.maxlifetime)
-	if maps == nil {
+	maps, _ := pder.decode(sid)
+	if maps == nil || pder.isRevoked(maps) {
 		maps = make(map[interface{}]interface{})
 	}
 	rs := &CookieSessionStore{sid: sid, values: maps}
@@ -152,9 +225,11 @@ func (pder *CookieProvider) SessionExist(sid string) bool {
 	return true
 }
 
-// SessionRegenerate Implement method, no used.
+// SessionRegenerate keeps the values of the cookie session read from the
+// request, the cookie is encrypted again when it is released. The values set
+// in the current request are carried over by Manager.SessionRegenerateStore.
 func (pder *CookieProvider) SessionRegenerate(oldsid, sid string) (Store, error) {
-	return nil, nil
+	return pder.SessionRead(oldsid)
 }
 
 // SessionDestroy Implement method, no used.
@@ -162,9 +237,42 @@ func (pder *CookieProvider) SessionDestroy(sid string) error {
 	return nil
 }
 
-// SessionGC Implement method, no used.
+// SessionGC forgets the revoked users whose sessions have expired.
 func (pder *CookieProvider) SessionGC() {
-	return
+	expired := time.Now().Add(-time.Duration(atomic.LoadInt64(&pder.maxlifetime)) * time.Second).UnixNano()
+	pder.revokedLock.Lock()
+	defer pder.revokedLock.Unlock()
+	for user, t := range pder.revoked {
+		if t < expired {
+			delete(pder.revoked, user)
+		}
+	}
+}
+
+// SessionRevokeUser rejects the cookie sessions bound to the user before now.
+// The revocations are kept in memory for maxlifetime, so they are lost when
+// the process restarts and are not shared by other processes.
+func (pder *CookieProvider) SessionRevokeUser(user string) error {
+	pder.revokedLock.Lock()
+	defer pder.revokedLock.Unlock()
+	if pder.revoked == nil {
+		pder.revoked = make(map[string]int64)
+	}
+	pder.revoked[user] = time.Now().UnixNano()
+	return nil
+}
+
+// isRevoked reports whether the session values are bound to a revoked user.
+func (pder *CookieProvider) isRevoked(values map[interface{}]interface{}) bool {
+	user, ok := values[UserKey].(string)
+	if !ok {
+		return false
+	}
+	since, _ := values[UserSinceKey].(int64)
+	pder.revokedLock.RLock()
+	defer pder.revokedLock.RUnlock()
+	t, ok := pder.revoked[user]
+	return ok && since <= t
 }
 
 // SessionAll Implement method, return 0.
@@ -15,6 +15,7 @@
 package session
 
 import (
+	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -51,6 +52,44 @@ func TestCookie(t *testing.T) {
 	}
 }
 
+func TestCookieRegenerateStore(t *testing.T) {
+	config := `{"cookieName":"gosessionid","enableSetCookie":false,"gclifetime":3600,"ProviderConfig":"{\"cookieName\":\"gosessionid\",\"securityKey\":\"beegocookiehashkey\"}"}`
+	manager, err := NewManager("cookie", config)
+	if err != nil {
+		t.Fatal("init cookie session err", err)
+	}
+	start := func(cookie string) (Store, *http.Request) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if cookie != "" {
+			r.Header.Set("Cookie", cookie)
+		}
+		sess, err := manager.SessionStart(httptest.NewRecorder(), r)
+		if err != nil {
+			t.Fatal("session start err,", err)
+		}
+		return sess, r
+	}
+
+	sess, _ := start("")
+	sess.Set("username", "astaxie")
+	w := httptest.NewRecorder()
+	sess.SessionRelease(w)
+
+	// login: the user is bound and the session id is rotated in the same request
+	sess, r := start(w.Header().Get("Set-Cookie"))
+	manager.SetUser(sess, "alice")
+	sess.SessionRelease(httptest.NewRecorder())
+	sess = manager.SessionRegenerateStore(httptest.NewRecorder(), r, sess)
+	if sess.Get(UserKey) != "alice" || sess.Get("username") != "astaxie" {
+		t.Fatal("the values are lost when the session id is regenerated")
+	}
+	w = httptest.NewRecorder()
+	sess.SessionRelease(w)
+	if sess, _ = start(w.Header().Get("Set-Cookie")); sess.Get(UserKey) != "alice" {
+		t.Fatal("the regenerated session has no user")
+	}
+}
+
 func TestDestorySessionCookie(t *testing.T) {
 	config := `{"cookieName":"gosessionid","enableSetCookie":true,"gclifetime":3600,"ProviderConfig":"{\"cookieName\":\"gosessionid\",\"securityKey\":\"beegocookiehashkey\"}"}`
 	globalSessions, err := NewManager("cookie", config)
@@ -91,3 +130,86 @@ func TestDestorySessionCookie(t *testing.T) {
 		t.Fatal("after destroy session and reqeust again ,get cookie session id is same.")
 	}
 }
+
+func TestSecureCookie(t *testing.T) {
+	oldKey, newKey := "the-old-secure-cookie-key", "the-new-secure-cookie-key"
+	old, err := NewSecureCookie(3600, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := []byte(strings.Repeat("compressible ", 100))
+	str, err := old.Encode("sid", value)
+	if err != nil {
+		t.Fatal("encode error,", err)
+	}
+	if len(str) >= len(value) {
+		t.Fatal("the value is not compressed")
+	}
+	if b, err := old.Decode("sid", str); err != nil || string(b) != string(value) {
+		t.Fatal("decode error,", err)
+	}
+	if _, err = old.Decode("other", str); err != ErrInvalidCookie {
+		t.Fatal("the value must be bound to the cookie name, got", err)
+	}
+	if _, err = old.Decode("sid", str[:len(str)-2]+"AA"); err != ErrInvalidCookie {
+		t.Fatal("tampered value must be rejected, got", err)
+	}
+
+	// rotation: the new key encrypts, the old key still decrypts
+	ring, _ := NewSecureCookie(3600, newKey, oldKey)
+	if b, err := ring.Decode("sid", str); err != nil || string(b) != string(value) {
+		t.Fatal("decode with the old key error,", err)
+	}
+	str2, _ := ring.Encode("sid", value)
+	if _, err = old.Decode("sid", str2); err != ErrInvalidCookie {
+		t.Fatal("the newest key must encrypt, got", err)
+	}
+	ring.SetKeys(newKey)
+	if _, err = ring.Decode("sid", str); err != ErrInvalidCookie {
+		t.Fatal("the retired key must not decrypt, got", err)
+	}
+
+	if _, err = NewSecureCookie(0, "short"); err == nil {
+		t.Fatal("expected the error of a short key")
+	}
+}
+
+func TestSecureCookieSize(t *testing.T) {
+	s, _ := NewSecureCookie(0, "beegocookiehashkey")
+	random := make([]byte, MaxCookieSize)
+	rand.Read(random)
+	_, err := s.Encode("sid", random)
+	if e, ok := err.(*CookieSizeError); !ok || e.Name != "sid" || e.Size <= MaxCookieSize {
+		t.Fatal("expected a CookieSizeError, got", err)
+	}
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	start := func(config, cookie string) (Store, string) {
+		manager, err := NewManager("cookie", `{"cookieName":"gosessionid","enableSetCookie":false,"gclifetime":3600,"ProviderConfig":`+config+`}`)
+		if err != nil {
+			t.Fatal("init cookie session err", err)
+		}
+		r, _ := http.NewRequest("GET", "/", nil)
+		if cookie != "" {
+			r.Header.Set("Cookie", cookie)
+		}
+		w := httptest.NewRecorder()
+		sess, _ := manager.SessionStart(w, r)
+		return sess, w.Header().Get("Set-Cookie")
+	}
+	sess, _ := start(`"{\"keys\":[\"first-session-key-1\"]}"`, "")
+	sess.Set("username", "astaxie")
+	w := httptest.NewRecorder()
+	sess.SessionRelease(w)
+	cookie := w.Header().Get("Set-Cookie")
+
+	sess, _ = start(`"{\"keys\":[\"second-session-key\",\"first-session-key-1\"]}"`, cookie)
+	if sess.Get("username") != "astaxie" {
+		t.Fatal("the session is lost after the key rotation")
+	}
+	sess, _ = start(`"{\"keys\":[\"second-session-key\"]}"`, cookie)
+	if sess.Get("username") != nil {
+		t.Fatal("the session of a retired key must be dropped")
+	}
+}
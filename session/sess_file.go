@@ -23,6 +23,7 @@ import (
 	"path"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,6 +37,7 @@ type FileSessionStore struct {
 	sid    string
 	lock   sync.RWMutex
 	values map[interface{}]interface{}
+	dirty  bool // the values have been changed since read
 }
 
 // Set value to file session
@@ -43,6 +45,7 @@ func (fs *FileSessionStore) Set(key, value interface{}) {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 	fs.values[key] = value
+	fs.dirty = true
 }
 
 // Get value from file session
@@ -60,6 +63,7 @@ func (fs *FileSessionStore) Delete(key interface{}) {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 	delete(fs.values, key)
+	fs.dirty = true
 }
 
 // Flush Clean all values in file session
@@ -67,6 +71,7 @@ func (fs *FileSessionStore) Flush() {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 	fs.values = make(map[interface{}]interface{})
+	fs.dirty = true
 }
 
 // SessionID Get file session store id
@@ -74,9 +79,15 @@ func (fs *FileSessionStore) SessionID() string {
 	return fs.sid
 }
 
-// SessionRelease Write file session to local file with Gob string
+// SessionRelease Write file session to local file with the configured serializer.
+// Nothing is written if the values have no changes, the file was touched by SessionRead.
 func (fs *FileSessionStore) SessionRelease(w http.ResponseWriter) {
-	b, err := EncodeGob(fs.values)
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	if !fs.dirty {
+		return
+	}
+	b, err := EncodeValues(fs.values)
 	if err != nil {
 		SLogger.Println(err)
 		return
@@ -85,34 +96,42 @@ func (fs *FileSessionStore) SessionRelease(w http.ResponseWriter) {
 	var f *os.File
 	if err == nil {
 		f, err = os.OpenFile(path.Join(filepder.savePath, string(fs.sid[0]), string(fs.sid[1]), fs.sid), os.O_RDWR, 0777)
-		SLogger.Println(err)
 	} else if os.IsNotExist(err) {
 		f, err = os.Create(path.Join(filepder.savePath, string(fs.sid[0]), string(fs.sid[1]), fs.sid))
-		SLogger.Println(err)
 	} else {
 		return
 	}
+	if err != nil {
+		SLogger.Println(err)
+		return
+	}
 	f.Truncate(0)
 	f.Seek(0, 0)
 	f.Write(b)
 	f.Close()
+	fs.dirty = false
 }
 
 // FileProvider File session provider
 type FileProvider struct {
 	lock        sync.RWMutex
-	maxlifetime int64
+	maxlifetime int64 // atomic
 	savePath    string
 }
 
 // SessionInit Init file session provider.
 // savePath sets the session files path.
 func (fp *FileProvider) SessionInit(maxlifetime int64, savePath string) error {
-	fp.maxlifetime = maxlifetime
+	atomic.StoreInt64(&fp.maxlifetime, maxlifetime)
 	fp.savePath = savePath
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (fp *FileProvider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&fp.maxlifetime, maxlifetime)
+}
+
 // SessionRead Read file session by sid.
 // if file is not exist, create it.
 // the file path is generated from sid string.
@@ -142,7 +161,7 @@ func (fp *FileProvider) SessionRead(sid string) (Store, error) {
 	if len(b) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = DecodeGob(b)
+		kv, err = DecodeValues(b)
 		if err != nil {
 			return nil, err
 		}
@@ -178,7 +197,7 @@ func (fp *FileProvider) SessionGC() {
 	filepder.lock.Lock()
 	defer filepder.lock.Unlock()
 
-	gcmaxlifetime = fp.maxlifetime
+	gcmaxlifetime = atomic.LoadInt64(&fp.maxlifetime)
 	filepath.Walk(fp.savePath, gcpath)
 }
 
@@ -196,6 +215,44 @@ func (fp *FileProvider) SessionAll() int {
 	return a.total
 }
 
+// SessionIterate calls fn for each active file session until fn returns false,
+// the modification time of the file is the last access time.
+func (fp *FileProvider) SessionIterate(fn func(SessionInfo) bool) error {
+	var infos []SessionInfo
+	expired := time.Now().Unix() - atomic.LoadInt64(&fp.maxlifetime)
+	filepder.lock.Lock()
+	err := filepath.Walk(fp.savePath, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() || f.ModTime().Unix() < expired {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		kv := make(map[interface{}]interface{})
+		if len(b) > 0 {
+			if kv, err = DecodeValues(b); err != nil {
+				return nil
+			}
+		}
+		infos = append(infos, NewSessionInfo(f.Name(), f.ModTime(), kv))
+		return nil
+	})
+	filepder.lock.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, info := range infos {
+		if !fn(info) {
+			break
+		}
+	}
+	return nil
+}
+
 // SessionRegenerate Generate new sid for file session.
 // it delete old file and create new file named from new sid.
 func (fp *FileProvider) SessionRegenerate(oldsid, sid string) (Store, error) {
@@ -239,7 +296,7 @@ func (fp *FileProvider) SessionRegenerate(oldsid, sid string) (Store, error) {
 	if len(b) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = DecodeGob(b)
+		kv, err = DecodeValues(b)
 		if err != nil {
 			return nil, err
 		}
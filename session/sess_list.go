@@ -0,0 +1,233 @@
+package session
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keys of the session values set by Manager.SetUser.
+const (
+	// UserKey holds the user key of the session (string).
+	UserKey = "_session_user"
+	// UserSinceKey holds the time the user was bound to the session (UnixNano, int64).
+	UserSinceKey = "_session_user_since"
+)
+
+// ErrNotSupported is returned when the provider does not support the operation.
+var ErrNotSupported = errors.New("session: not supported by the provider")
+
+// SessionInfo describes a session.
+type SessionInfo struct {
+	ID       string
+	User     string                      // the user key set by Manager.SetUser, empty if none
+	Accessed time.Time                   // the last access time, zero if unknown
+	Values   map[interface{}]interface{} // a copy of the session values
+}
+
+// NewSessionInfo creates a SessionInfo with a copy of the values.
+func NewSessionInfo(sid string, accessed time.Time, values map[interface{}]interface{}) SessionInfo {
+	info := SessionInfo{
+		ID:       sid,
+		Accessed: accessed,
+		Values:   make(map[interface{}]interface{}, len(values)),
+	}
+	for k, v := range values {
+		info.Values[k] = v
+	}
+	info.User, _ = values[UserKey].(string)
+	return info
+}
+
+// Lister is an optional interface of the providers which can enumerate their sessions.
+type Lister interface {
+	// SessionIterate calls fn for each active session until fn returns false.
+	SessionIterate(fn func(SessionInfo) bool) error
+}
+
+// UserRevoker is an optional interface of the providers which keep no
+// session on the server (e.g. cookie), but can reject all the sessions bound
+// to a user before now.
+type UserRevoker interface {
+	SessionRevokeUser(user string) error
+}
+
+// userIndex is the secondary index from the user keys to the session IDs,
+// it is built from a Lister on the first use and then kept by the Manager.
+type userIndex struct {
+	users map[string]map[string]bool // user => sids
+	sids  map[string]string          // sid => user
+	built bool
+	sync.Mutex
+}
+
+func newUserIndex() *userIndex {
+	return &userIndex{
+		users: make(map[string]map[string]bool),
+		sids:  make(map[string]string),
+	}
+}
+
+// add binds the sid to the user, the caller must hold the lock.
+func (idx *userIndex) add(sid, user string) {
+	idx.remove(sid)
+	if idx.users[user] == nil {
+		idx.users[user] = make(map[string]bool)
+	}
+	idx.users[user][sid] = true
+	idx.sids[sid] = user
+}
+
+// remove unbinds the sid, the caller must hold the lock.
+func (idx *userIndex) remove(sid string) {
+	user, ok := idx.sids[sid]
+	if !ok {
+		return
+	}
+	delete(idx.sids, sid)
+	delete(idx.users[user], sid)
+	if len(idx.users[user]) == 0 {
+		delete(idx.users, user)
+	}
+}
+
+// IterateSessions calls fn for each active session until fn returns false,
+// returns ErrNotSupported if the provider is not a Lister.
+func (manager *Manager) IterateSessions(fn func(SessionInfo) bool) error {
+	lister, ok := manager.provider.(Lister)
+	if !ok {
+		return ErrNotSupported
+	}
+	return lister.SessionIterate(fn)
+}
+
+// ListSessions returns all the active sessions ordered by the last access time, latest first.
+func (manager *Manager) ListSessions() ([]SessionInfo, error) {
+	var list []SessionInfo
+	err := manager.IterateSessions(func(info SessionInfo) bool {
+		list = append(list, info)
+		return true
+	})
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Accessed.After(list[j].Accessed) })
+	return list, err
+}
+
+// InspectSession returns the session with the id, without touching it.
+func (manager *Manager) InspectSession(sid string) (info SessionInfo, found bool, err error) {
+	err = manager.IterateSessions(func(i SessionInfo) bool {
+		if i.ID == sid {
+			info, found = i, true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// SetUser binds the session to the user key (e.g. the user id after login),
+// so that it can be found by UserSessions and revoked by RevokeUser.
+func (manager *Manager) SetUser(store Store, user string) {
+	store.Set(UserKey, user)
+	store.Set(UserSinceKey, time.Now().UnixNano())
+	if _, ok := manager.provider.(Lister); !ok {
+		return
+	}
+	manager.index.Lock()
+	manager.index.add(store.SessionID(), user)
+	manager.index.Unlock()
+}
+
+// UserSessions returns the IDs of the active sessions bound to the user.
+// The index is built from the provider on the first call and kept by the
+// Manager, call RebuildUserIndex when other processes share the provider.
+func (manager *Manager) UserSessions(user string) ([]string, error) {
+	if _, ok := manager.provider.(Lister); !ok {
+		return nil, ErrNotSupported
+	}
+	manager.index.Lock()
+	built := manager.index.built
+	manager.index.Unlock()
+	if !built {
+		if err := manager.RebuildUserIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	manager.index.Lock()
+	defer manager.index.Unlock()
+	sids := make([]string, 0, len(manager.index.users[user]))
+	for sid := range manager.index.users[user] {
+		if manager.provider.SessionExist(sid) {
+			sids = append(sids, sid)
+		} else {
+			manager.index.remove(sid)
+		}
+	}
+	sort.Strings(sids)
+	return sids, nil
+}
+
+// RebuildUserIndex rebuilds the index from the user keys to the session IDs
+// by iterating all the sessions.
+func (manager *Manager) RebuildUserIndex() error {
+	idx := newUserIndex()
+	err := manager.IterateSessions(func(info SessionInfo) bool {
+		if len(info.User) > 0 {
+			idx.add(info.ID, info.User)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	manager.index.Lock()
+	defer manager.index.Unlock()
+	manager.index.users = idx.users
+	manager.index.sids = idx.sids
+	manager.index.built = true
+	return nil
+}
+
+// RevokeUser destroys all the sessions of the user, e.g. to sign the user
+// out on every device, and returns the number of the destroyed sessions.
+// For a UserRevoker (e.g. cookie) the sessions are rejected on their next
+// request and the number is always 0.
+func (manager *Manager) RevokeUser(user string) (int, error) {
+	if revoker, ok := manager.provider.(UserRevoker); ok {
+		return 0, revoker.SessionRevokeUser(user)
+	}
+	sids, err := manager.UserSessions(user)
+	if err != nil {
+		return 0, err
+	}
+	for _, sid := range sids {
+		if err = manager.provider.SessionDestroy(sid); err != nil {
+			return 0, err
+		}
+	}
+	manager.index.Lock()
+	for _, sid := range sids {
+		manager.index.remove(sid)
+	}
+	manager.index.Unlock()
+	return len(sids), nil
+}
+
+// DestroySessionID destroys the session with the id.
+func (manager *Manager) DestroySessionID(sid string) error {
+	manager.index.Lock()
+	manager.index.remove(sid)
+	manager.index.Unlock()
+	return manager.provider.SessionDestroy(sid)
+}
+
+// renameIndexed moves the index entry of the regenerated session.
+func (manager *Manager) renameIndexed(oldsid, sid string) {
+	manager.index.Lock()
+	defer manager.index.Unlock()
+	if user, ok := manager.index.sids[oldsid]; ok {
+		manager.index.add(sid, user)
+		manager.index.remove(oldsid)
+	}
+}
@@ -0,0 +1,132 @@
+package session
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func startSessions(t *testing.T, manager *Manager, n int) []Store {
+	var stores []Store
+	for i := 0; i < n; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		sess, err := manager.SessionStart(w, r)
+		if err != nil {
+			t.Fatal("session start err,", err)
+		}
+		sess.Set("n", i)
+		sess.SessionRelease(w)
+		stores = append(stores, sess)
+	}
+	return stores
+}
+
+func testRevokeUser(t *testing.T, manager *Manager) {
+	stores := startSessions(t, manager, 3)
+	manager.SetUser(stores[0], "alice")
+	manager.SetUser(stores[1], "alice")
+	manager.SetUser(stores[2], "bob")
+	for _, sess := range stores {
+		sess.SessionRelease(httptest.NewRecorder())
+	}
+
+	info, found, err := manager.InspectSession(stores[2].SessionID())
+	if err != nil || !found {
+		t.Fatal("inspect session error,", err)
+	}
+	if info.User != "bob" || info.Values["n"] != 2 {
+		t.Fatalf("unexpected session info %#v", info)
+	}
+
+	list, err := manager.ListSessions()
+	if err != nil || len(list) < 3 {
+		t.Fatalf("list sessions error, got %d, %v", len(list), err)
+	}
+
+	// rebuilt from the provider
+	if err = manager.RebuildUserIndex(); err != nil {
+		t.Fatal("rebuild index error,", err)
+	}
+	sids, err := manager.UserSessions("alice")
+	if err != nil || len(sids) != 2 {
+		t.Fatalf("expected 2 sessions of alice, got %v, %v", sids, err)
+	}
+
+	n, err := manager.RevokeUser("alice")
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 revoked sessions, got %d, %v", n, err)
+	}
+	for i, sess := range stores {
+		if exist := manager.provider.SessionExist(sess.SessionID()); exist != (i == 2) {
+			t.Fatalf("session %d exist: %v", i, exist)
+		}
+	}
+	if sids, _ = manager.UserSessions("bob"); len(sids) != 1 || sids[0] != stores[2].SessionID() {
+		t.Fatalf("expected the session of bob, got %v", sids)
+	}
+}
+
+func TestMemRevokeUser(t *testing.T) {
+	manager, _ := NewManager("memory", `{"cookieName":"gosessionid","gclifetime":10}`)
+	testRevokeUser(t, manager)
+}
+
+func TestFileRevokeUser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manager, err := NewManager("file", `{"cookieName":"gosessionid","gclifetime":10,"ProviderConfig":`+strconv.Quote(dir)+`}`)
+	if err != nil {
+		t.Fatal("init file session err", err)
+	}
+	testRevokeUser(t, manager)
+}
+
+func TestCookieRevokeUser(t *testing.T) {
+	config := `{"cookieName":"gosessionid","enableSetCookie":false,"gclifetime":3600,"ProviderConfig":"{\"securityKey\":\"beegocookiehashkey\"}"}`
+	manager, err := NewManager("cookie", config)
+	if err != nil {
+		t.Fatal("init cookie session err", err)
+	}
+	if _, err = manager.ListSessions(); err != ErrNotSupported {
+		t.Fatal("expected ErrNotSupported, got", err)
+	}
+
+	login := func(user string) string {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		sess, _ := manager.SessionStart(w, r)
+		manager.SetUser(sess, user)
+		sess.SessionRelease(w)
+		return w.Header().Get("Set-Cookie")
+	}
+	user := func(cookie string) interface{} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Cookie", cookie)
+		sess, _ := manager.SessionStart(httptest.NewRecorder(), r)
+		return sess.Get(UserKey)
+	}
+
+	alice, bob := login("alice"), login("bob")
+	if user(alice) != "alice" || user(bob) != "bob" {
+		t.Fatal("get session user error")
+	}
+	if _, err = manager.RevokeUser("alice"); err != nil {
+		t.Fatal("revoke user err,", err)
+	}
+	if u := user(alice); u != nil {
+		t.Fatal("revoked session still has the user", u)
+	}
+	if user(bob) != "bob" {
+		t.Fatal("other users must not be revoked")
+	}
+	if user(login("alice")) != "alice" {
+		t.Fatal("new sessions must not be revoked")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"container/list"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -77,17 +78,22 @@ type MemProvider struct {
 	lock        sync.RWMutex             // locker
 	sessions    map[string]*list.Element // map in memory
 	list        *list.List               // for gc
-	maxlifetime int64
+	maxlifetime int64                    // atomic
 	savePath    string
 }
 
 // SessionInit init memory session
 func (pder *MemProvider) SessionInit(maxlifetime int64, savePath string) error {
-	pder.maxlifetime = maxlifetime
+	atomic.StoreInt64(&pder.maxlifetime, maxlifetime)
 	pder.savePath = savePath
 	return nil
 }
 
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (pder *MemProvider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&pder.maxlifetime, maxlifetime)
+}
+
 // SessionRead get memory session store by sid
 func (pder *MemProvider) SessionRead(sid string) (Store, error) {
 	pder.lock.RLock()
@@ -157,7 +163,7 @@ func (pder *MemProvider) SessionGC() {
 		if element == nil {
 			break
 		}
-		if (element.Value.(*MemSessionStore).timeAccessed.Unix() + pder.maxlifetime) < time.Now().Unix() {
+		if (element.Value.(*MemSessionStore).timeAccessed.Unix() + atomic.LoadInt64(&pder.maxlifetime)) < time.Now().Unix() {
 			pder.lock.RUnlock()
 			pder.lock.Lock()
 			pder.list.Remove(element)
@@ -176,6 +182,36 @@ func (pder *MemProvider) SessionAll() int {
 	return pder.list.Len()
 }
 
+// SessionIterate calls fn for each active memory session until fn returns false,
+// the latest accessed first.
+func (pder *MemProvider) SessionIterate(fn func(SessionInfo) bool) error {
+	type item struct {
+		store    *MemSessionStore
+		sid      string
+		accessed time.Time
+	}
+	expired := time.Now().Unix() - atomic.LoadInt64(&pder.maxlifetime)
+	pder.lock.RLock()
+	items := make([]item, 0, pder.list.Len())
+	for element := pder.list.Front(); element != nil; element = element.Next() {
+		st := element.Value.(*MemSessionStore)
+		if st.timeAccessed.Unix() < expired {
+			break
+		}
+		items = append(items, item{st, st.sid, st.timeAccessed})
+	}
+	pder.lock.RUnlock()
+	for _, it := range items {
+		it.store.lock.RLock()
+		info := NewSessionInfo(it.sid, it.accessed, it.store.value)
+		it.store.lock.RUnlock()
+		if !fn(info) {
+			break
+		}
+	}
+	return nil
+}
+
 // SessionUpdate expand time of session store by id in memory session
 func (pder *MemProvider) SessionUpdate(sid string) error {
 	pder.lock.Lock()
@@ -0,0 +1,383 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// MsgpackSerializer serializes the session values with MessagePack
+// (https://msgpack.org), a compact format readable by many languages.
+//
+// nil, bool, the integers, the floats, string, []byte, time.Time (the
+// timestamp extension) and the slices and maps of them are supported.
+// The values come back as int64 (uint64 if too large), float64, string,
+// []byte, time.Time, []interface{}, and map[string]interface{} (or
+// map[interface{}]interface{} if any key is not a string).
+type MsgpackSerializer struct{}
+
+// Encode encodes the values to a MessagePack map.
+func (MsgpackSerializer) Encode(values map[interface{}]interface{}) ([]byte, error) {
+	return msgpackAppend(nil, reflect.ValueOf(values))
+}
+
+// Decode decodes the values from a MessagePack map.
+func (MsgpackSerializer) Decode(data []byte) (map[interface{}]interface{}, error) {
+	d := &msgpackDecoder{b: data}
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	if d.i != len(d.b) {
+		return nil, errMsgpack
+	}
+	switch m := v.(type) {
+	case map[interface{}]interface{}:
+		return m, nil
+	case map[string]interface{}:
+		values := make(map[interface{}]interface{}, len(m))
+		for k, v := range m {
+			values[k] = v
+		}
+		return values, nil
+	}
+	return nil, errors.New("session: msgpack data is not a map")
+}
+
+var (
+	errMsgpack = errors.New("session: invalid msgpack data")
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+func msgpackAppend(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, 0xc0), nil
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		// timestamp 96: ext8, length 12, type -1, nsec uint32, sec int64
+		b = append(b, 0xc7, 12, 0xff)
+		b = appendUint32(b, uint32(t.Nanosecond()))
+		return appendUint64(b, uint64(t.Unix())), nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return msgpackAppend(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return appendUint32(append(b, 0xca), math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return appendUint64(append(b, 0xcb), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		s := v.String()
+		n := len(s)
+		switch {
+		case n < 32:
+			b = append(b, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			b = append(b, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			b = appendUint16(append(b, 0xda), uint16(n))
+		default:
+			b = appendUint32(append(b, 0xdb), uint32(n))
+		}
+		return append(b, s...), nil
+	case reflect.Slice, reflect.Array:
+		n := v.Len()
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			switch {
+			case n <= math.MaxUint8:
+				b = append(b, 0xc4, byte(n))
+			case n <= math.MaxUint16:
+				b = appendUint16(append(b, 0xc5), uint16(n))
+			default:
+				b = appendUint32(append(b, 0xc6), uint32(n))
+			}
+			for i := 0; i < n; i++ {
+				b = append(b, byte(v.Index(i).Uint()))
+			}
+			return b, nil
+		}
+		b = appendLen(b, n, 0x90, 0xdc)
+		var err error
+		for i := 0; i < n; i++ {
+			if b, err = msgpackAppend(b, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		b = appendLen(b, v.Len(), 0x80, 0xde)
+		var err error
+		for _, k := range v.MapKeys() {
+			if b, err = msgpackAppend(b, k); err != nil {
+				return nil, err
+			}
+			if b, err = msgpackAppend(b, v.MapIndex(k)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("session: msgpack serializer does not support %s", v.Type())
+}
+
+// appendLen appends the length of an array (0x90, 0xdc) or a map (0x80, 0xde).
+func appendLen(b []byte, n int, fix, code16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, code16), uint16(n))
+	}
+	return appendUint32(append(b, code16+1), uint32(n))
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(i))
+	}
+	return appendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u < 128:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(u))
+	}
+	return appendUint64(append(b, 0xcf), u)
+}
+
+func appendUint16(b []byte, u uint16) []byte {
+	return append(b, byte(u>>8), byte(u))
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(u>>32)), uint32(u))
+}
+
+type msgpackDecoder struct {
+	b []byte
+	i int
+}
+
+// next returns the next n bytes.
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.i < n {
+		return nil, errMsgpack
+	}
+	p := d.b[d.i : d.i+n]
+	d.i += n
+	return p, nil
+}
+
+// uint reads a big endian unsigned integer of n bytes.
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	p, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range p {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	p, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := p[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.decodeMap(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.decodeArray(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		p, err = d.next(int(c & 0x1f))
+		return string(p), err
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		if p, err = d.next(int(n)); err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), p...), nil
+	case 0xc7, 0xc8, 0xc9, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(c)
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extension
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		p, err = d.next(int(n))
+		return string(p), err
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	}
+	return nil, errMsgpack
+}
+
+func (d *msgpackDecoder) decodeArray(n int) (interface{}, error) {
+	// every element takes one byte at least
+	if n > len(d.b)-d.i {
+		return nil, errMsgpack
+	}
+	a := make([]interface{}, n)
+	var err error
+	for i := range a {
+		if a[i], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int) (interface{}, error) {
+	if n > (len(d.b)-d.i)/2 {
+		return nil, errMsgpack
+	}
+	m := make(map[interface{}]interface{}, n)
+	strKeys := true
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, errMsgpack
+		}
+		if _, ok := k.(string); !ok {
+			strKeys = false
+		}
+		m[k] = v
+	}
+	if !strKeys {
+		return m, nil
+	}
+	sm := make(map[string]interface{}, n)
+	for k, v := range m {
+		sm[k.(string)] = v
+	}
+	return sm, nil
+}
+
+// decodeExt decodes the timestamp extension, the other extensions are not supported.
+func (d *msgpackDecoder) decodeExt(c byte) (interface{}, error) {
+	var n int
+	switch c {
+	case 0xc7, 0xc8, 0xc9:
+		size, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		n = int(size)
+	default:
+		n = 1 << (c - 0xd4)
+	}
+	p, err := d.next(n + 1)
+	if err != nil {
+		return nil, err
+	}
+	if int8(p[0]) != -1 {
+		return nil, fmt.Errorf("session: msgpack extension type %d is not supported", int8(p[0]))
+	}
+	p = p[1:]
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(p)), 0), nil
+	case 8:
+		u := binary.BigEndian.Uint64(p)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(p[4:])), int64(binary.BigEndian.Uint32(p))), nil
+	}
+	return nil, errMsgpack
+}
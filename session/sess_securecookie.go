@@ -0,0 +1,200 @@
+package session
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// MaxCookieSize is the size limit of a cookie (name and value) in the browsers.
+const MaxCookieSize = 4096
+
+const (
+	// the payloads shorter than this are not compressed
+	compressMinSize = 128
+	// the limit of a decompressed payload, against the compression bombs
+	decompressMaxSize = 1 << 20
+	// MinCookieKeyLen is the minimum length of a secure cookie key.
+	MinCookieKeyLen = 16
+	// the flag of a compressed payload
+	flagCompressed byte = 1
+)
+
+var (
+	// ErrInvalidCookie is returned when the cookie value can not be decrypted
+	// by any key of the ring, or it has been tampered with.
+	ErrInvalidCookie = errors.New("session: invalid secure cookie")
+	// ErrExpiredCookie is returned when the cookie value is older than the max age.
+	ErrExpiredCookie = errors.New("session: expired secure cookie")
+)
+
+// CookieSizeError is returned when an encoded cookie exceeds MaxCookieSize.
+type CookieSizeError struct {
+	Name string
+	Size int
+}
+
+func (e *CookieSizeError) Error() string {
+	return fmt.Sprintf("session: the cookie %q is %d bytes after encoding, exceeds the limit of %d bytes", e.Name, e.Size, MaxCookieSize)
+}
+
+// SecureCookie encrypts and authenticates the cookie values with AES-GCM.
+//
+// It holds a key ring, the newest (first) key encrypts, and all the keys
+// decrypt, so that a new key can be put in front of the ring without
+// invalidating the cookies encrypted by the old ones. The cookie name is
+// authenticated together with the value, and the payloads are compressed
+// when it helps.
+type SecureCookie struct {
+	aeads  []cipher.AEAD
+	maxAge int64
+	lock   sync.RWMutex
+}
+
+// NewSecureCookie creates a SecureCookie with the key ring, newest first.
+// maxAge is the seconds a value is valid since it was encoded, 0 for no limit.
+func NewSecureCookie(maxAge int64, keys ...string) (*SecureCookie, error) {
+	s := &SecureCookie{maxAge: maxAge}
+	if err := s.SetKeys(keys...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetKeys replaces the key ring, newest first. Keep the retired keys at the
+// end of the ring until the cookies encrypted by them have expired.
+func (s *SecureCookie) SetKeys(keys ...string) error {
+	if len(keys) == 0 {
+		return errors.New("session: secure cookie needs at least one key")
+	}
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		if len(key) < MinCookieKeyLen {
+			return fmt.Errorf("session: secure cookie key must be at least %d bytes", MinCookieKeyLen)
+		}
+		// derives an AES-256 key from the key of any length
+		sum := sha256.Sum256([]byte(key))
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		aeads = append(aeads, aead)
+	}
+	s.lock.Lock()
+	s.aeads = aeads
+	s.lock.Unlock()
+	return nil
+}
+
+// SetMaxAge sets the seconds a value is valid since it was encoded, 0 for no limit.
+func (s *SecureCookie) SetMaxAge(maxAge int64) {
+	s.lock.Lock()
+	s.maxAge = maxAge
+	s.lock.Unlock()
+}
+
+// Encode encrypts the value of the named cookie with the newest key,
+// returns a *CookieSizeError if the result exceeds MaxCookieSize.
+func (s *SecureCookie) Encode(name string, value []byte) (string, error) {
+	s.lock.RLock()
+	aeads := s.aeads
+	s.lock.RUnlock()
+	if len(aeads) == 0 {
+		return "", errors.New("session: secure cookie has no key")
+	}
+	var flags byte
+	if len(value) >= compressMinSize {
+		if b, err := deflate(value); err == nil && len(b) < len(value) {
+			value, flags = b, flagCompressed
+		}
+	}
+	// plaintext: flags(1) | timestamp(8) | payload
+	plain := make([]byte, 9, 9+len(value))
+	plain[0] = flags
+	binary.BigEndian.PutUint64(plain[1:], uint64(time.Now().Unix()))
+	plain = append(plain, value...)
+
+	aead := aeads[0]
+	nonce := generateRandomKey(aead.NonceSize())
+	b := aead.Seal(nonce, nonce, plain, []byte(name))
+	str := base64.RawURLEncoding.EncodeToString(b)
+	if size := len(name) + 1 + len(str); size > MaxCookieSize {
+		return "", &CookieSizeError{Name: name, Size: size}
+	}
+	return str, nil
+}
+
+// Decode decrypts the value of the named cookie with the keys of the ring.
+func (s *SecureCookie) Decode(name, value string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	s.lock.RLock()
+	aeads, maxAge := s.aeads, s.maxAge
+	s.lock.RUnlock()
+
+	var plain []byte
+	for _, aead := range aeads {
+		n := aead.NonceSize()
+		if len(b) < n {
+			break
+		}
+		if plain, err = aead.Open(nil, b[:n], b[n:], []byte(name)); err == nil {
+			break
+		}
+	}
+	if plain == nil || len(plain) < 9 {
+		return nil, ErrInvalidCookie
+	}
+	created := int64(binary.BigEndian.Uint64(plain[1:9]))
+	if maxAge > 0 && created+maxAge < time.Now().Unix() {
+		return nil, ErrExpiredCookie
+	}
+	if plain[0]&flagCompressed == 0 {
+		return plain[9:], nil
+	}
+	return inflate(plain[9:])
+}
+
+func deflate(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(b); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func inflate(b []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(b))
+	defer r.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(r, decompressMaxSize+1))
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	if len(out) > decompressMaxSize {
+		return nil, errors.New("session: the secure cookie is too large after decompression")
+	}
+	return out, nil
+}
@@ -0,0 +1,178 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Serializer encodes the session values to bytes for the providers, and decodes them back.
+type Serializer interface {
+	Encode(values map[interface{}]interface{}) ([]byte, error)
+	Decode(data []byte) (map[interface{}]interface{}, error)
+}
+
+// GobSerializer serializes the session values with encoding/gob,
+// the custom types must be registered by gob.Register.
+type GobSerializer struct{}
+
+// Encode encodes the values to gob.
+func (GobSerializer) Encode(values map[interface{}]interface{}) ([]byte, error) {
+	return EncodeGob(values)
+}
+
+// Decode decodes the values from gob.
+func (GobSerializer) Decode(data []byte) (map[interface{}]interface{}, error) {
+	return DecodeGob(data)
+}
+
+// JSONSerializer serializes the session values with encoding/json, so that
+// they can be read by other languages. The keys must be strings, and the
+// values come back as the JSON types: integers as int64, other numbers as
+// float64, objects as map[string]interface{} and arrays as []interface{}.
+type JSONSerializer struct{}
+
+// Encode encodes the values to a JSON object.
+func (JSONSerializer) Encode(values map[interface{}]interface{}) ([]byte, error) {
+	m, err := stringKeys(values)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// Decode decodes the values from a JSON object.
+func (JSONSerializer) Decode(data []byte) (map[interface{}]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	values := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		values[k] = jsonNumbers(v)
+	}
+	return values, nil
+}
+
+// stringKeys converts the maps with interface{} keys to the maps with string keys, recursively.
+func stringKeys(values map[interface{}]interface{}) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("session: json serializer needs string keys, got %T", k)
+		}
+		switch x := v.(type) {
+		case map[interface{}]interface{}:
+			sub, err := stringKeys(x)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = sub
+		default:
+			m[key] = v
+		}
+	}
+	return m, nil
+}
+
+// jsonNumbers converts the json.Number to int64 or float64, recursively.
+func jsonNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, sub := range x {
+			x[k] = jsonNumbers(sub)
+		}
+	case []interface{}:
+		for i, sub := range x {
+			x[i] = jsonNumbers(sub)
+		}
+	}
+	return v
+}
+
+var (
+	serializers = map[string]Serializer{
+		"gob":     GobSerializer{},
+		"json":    JSONSerializer{},
+		"msgpack": MsgpackSerializer{},
+	}
+	serializerNames = []string{"gob", "json", "msgpack"}
+	// the serializer set by the "serializer" config of the manager
+	serializer     Serializer = GobSerializer{}
+	serializerName            = "gob"
+	serializerLock sync.RWMutex
+)
+
+// RegisterSerializer makes a serializer available by the name for the "serializer" config.
+func RegisterSerializer(name string, s Serializer) {
+	serializerLock.Lock()
+	defer serializerLock.Unlock()
+	if s == nil {
+		panic("session: Register serializer is nil")
+	}
+	if _, dup := serializers[name]; !dup {
+		serializerNames = append(serializerNames, name)
+	}
+	serializers[name] = s
+}
+
+// setSerializer sets the serializer used by EncodeValues, gob if the name is empty.
+func setSerializer(name string) error {
+	if name == "" {
+		name = "gob"
+	}
+	serializerLock.Lock()
+	defer serializerLock.Unlock()
+	s, ok := serializers[name]
+	if !ok {
+		return fmt.Errorf("session: unknown serializer %q (forgotten import?)", name)
+	}
+	serializer, serializerName = s, name
+	return nil
+}
+
+// EncodeValues encodes the session values with the configured serializer,
+// the providers call it to save the sessions.
+func EncodeValues(values map[interface{}]interface{}) ([]byte, error) {
+	serializerLock.RLock()
+	s := serializer
+	serializerLock.RUnlock()
+	return s.Encode(values)
+}
+
+// DecodeValues decodes the session values with the configured serializer.
+// The data written by the other registered serializers are decoded as well,
+// so the saved sessions survive a change of the serializer.
+func DecodeValues(data []byte) (map[interface{}]interface{}, error) {
+	serializerLock.RLock()
+	s := serializer
+	others := make([]Serializer, 0, len(serializerNames))
+	for _, name := range serializerNames {
+		if name != serializerName {
+			others = append(others, serializers[name])
+		}
+	}
+	serializerLock.RUnlock()
+
+	values, err := s.Decode(data)
+	if err == nil {
+		return values, nil
+	}
+	for _, other := range others {
+		if values, e := other.Decode(data); e == nil {
+			return values, nil
+		}
+	}
+	return nil, err
+}
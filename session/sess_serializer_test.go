@@ -0,0 +1,122 @@
+package session
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSerializers(t *testing.T) {
+	now := time.Unix(1500000000, 123456789)
+	values := map[interface{}]interface{}{
+		"string": "astaxie",
+		"int":    int64(42),
+		"neg":    int64(-100000),
+		"max":    int64(math.MaxInt64),
+		"float":  1.5,
+		"bool":   true,
+		"nil":    nil,
+		"list":   []interface{}{"a", int64(1)},
+		"map":    map[string]interface{}{"x": "y"},
+	}
+	for _, name := range []string{"gob", "json", "msgpack"} {
+		s := serializers[name]
+		b, err := s.Encode(values)
+		if err != nil {
+			t.Fatalf("%s encode error: %v", name, err)
+		}
+		got, err := s.Decode(b)
+		if err != nil {
+			t.Fatalf("%s decode error: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, values) {
+			t.Errorf("%s:\nexpected %#v\n     got %#v", name, values, got)
+		}
+	}
+
+	b, _ := MsgpackSerializer{}.Encode(map[interface{}]interface{}{"t": now, "b": []byte{1, 2}, 1: "one"})
+	got, err := MsgpackSerializer{}.Decode(b)
+	if err != nil {
+		t.Fatal("msgpack decode error,", err)
+	}
+	if !got["t"].(time.Time).Equal(now) || !reflect.DeepEqual(got["b"], []byte{1, 2}) || got[int64(1)] != "one" {
+		t.Fatalf("msgpack decode error, got %#v", got)
+	}
+	if _, err = (JSONSerializer{}).Encode(map[interface{}]interface{}{1: "one"}); err == nil {
+		t.Fatal("expected the error of a key which is not a string")
+	}
+	if _, err = (MsgpackSerializer{}).Decode(b[:len(b)-1]); err == nil {
+		t.Fatal("expected the error of truncated msgpack data")
+	}
+}
+
+func TestDecodeValuesFallback(t *testing.T) {
+	defer setSerializer("gob")
+	values := map[interface{}]interface{}{"username": "astaxie"}
+	gob, _ := EncodeValues(values)
+	if err := setSerializer("json"); err != nil {
+		t.Fatal(err)
+	}
+	// the sessions saved before the serializer is changed
+	if got, err := DecodeValues(gob); err != nil || got["username"] != "astaxie" {
+		t.Fatalf("decode gob data with the json serializer, got %v, %v", got, err)
+	}
+	json, _ := EncodeValues(values)
+	if string(json) != `{"username":"astaxie"}` {
+		t.Fatalf("unexpected json %s", json)
+	}
+	if err := setSerializer("yaml"); err == nil {
+		t.Fatal("expected the error of an unknown serializer")
+	}
+}
+
+func TestFileSkipCleanRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manager, err := NewManager("file", `{"cookieName":"gosessionid","gclifetime":10,"serializer":"json","ProviderConfig":`+strconv.Quote(dir)+`}`)
+	if err != nil {
+		t.Fatal("init file session err", err)
+	}
+	defer setSerializer("gob")
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	sess, _ := manager.SessionStart(w, r)
+	sess.Set("username", "astaxie")
+	sess.SessionRelease(w)
+	sid := sess.SessionID()
+	fname := filepath.Join(dir, sid[:1], sid[1:2], sid)
+	if b, _ := ioutil.ReadFile(fname); string(b) != `{"username":"astaxie"}` {
+		t.Fatalf("unexpected session file %s", b)
+	}
+
+	read := func() Store {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "gosessionid", Value: sid})
+		sess, _ := manager.SessionStart(httptest.NewRecorder(), r)
+		return sess
+	}
+	sess = read()
+	ioutil.WriteFile(fname, []byte(`{"username":"changed"}`), 0666)
+	sess.SessionRelease(httptest.NewRecorder())
+	if b, _ := ioutil.ReadFile(fname); string(b) != `{"username":"changed"}` {
+		t.Fatal("a clean session must not be written")
+	}
+
+	sess = read()
+	sess.Set("username", "astaxie")
+	sess.SessionRelease(httptest.NewRecorder())
+	if b, _ := ioutil.ReadFile(fname); string(b) != `{"username":"astaxie"}` {
+		t.Fatal("a dirty session must be written")
+	}
+}
@@ -0,0 +1,274 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultShardCount is the number of the shards of the sharded memory provider.
+const DefaultShardCount = 64
+
+var shardmempder = &ShardMemProvider{}
+
+// ShardMemSessionStore sharded memory session store.
+type ShardMemSessionStore struct {
+	sid      string
+	accessed int64 // last access time (UnixNano), atomic
+	values   map[interface{}]interface{}
+	lock     sync.RWMutex
+}
+
+// Set value to the session
+func (st *ShardMemSessionStore) Set(key, value interface{}) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.values[key] = value
+}
+
+// Get value from the session by key
+func (st *ShardMemSessionStore) Get(key interface{}) interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.values[key]
+}
+
+// Delete value in the session by key
+func (st *ShardMemSessionStore) Delete(key interface{}) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	delete(st.values, key)
+}
+
+// Flush clear all values in the session
+func (st *ShardMemSessionStore) Flush() {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.values = make(map[interface{}]interface{})
+}
+
+// SessionID get the id of the session
+func (st *ShardMemSessionStore) SessionID() string {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.sid
+}
+
+// SessionRelease Implement method, no used.
+func (st *ShardMemSessionStore) SessionRelease(w http.ResponseWriter) {
+}
+
+func (st *ShardMemSessionStore) touch(now int64) {
+	atomic.StoreInt64(&st.accessed, now)
+}
+
+func (st *ShardMemSessionStore) expired(now, maxlifetime int64) bool {
+	return atomic.LoadInt64(&st.accessed)+maxlifetime*int64(time.Second) < now
+}
+
+type memShard struct {
+	lock     sync.RWMutex
+	sessions map[string]*ShardMemSessionStore
+}
+
+// ShardMemProvider is a memory provider which spreads the sessions over
+// shards with their own locks, registered as "shardmemory".
+//
+// Reading a session takes only the read lock of its shard, the access time
+// is updated atomically. The expired sessions are dropped when they are read,
+// and SessionGC sweeps the shards one by one, so the other shards are never
+// blocked. savePath is the number of the shards, DefaultShardCount if empty.
+type ShardMemProvider struct {
+	shards      []*memShard
+	maxlifetime int64 // atomic
+	initOnce    sync.Once
+}
+
+// SessionInit init the sharded memory provider, the shards are created
+// on the first call and kept by the later calls.
+func (pder *ShardMemProvider) SessionInit(maxlifetime int64, savePath string) error {
+	n := DefaultShardCount
+	if savePath != "" {
+		var err error
+		if n, err = strconv.Atoi(savePath); err != nil || n <= 0 {
+			return fmt.Errorf("session: invalid shard count %q", savePath)
+		}
+	}
+	pder.initOnce.Do(func() {
+		// a power of two, so that the shard is picked by a mask
+		size := 1
+		for size < n {
+			size <<= 1
+		}
+		pder.shards = make([]*memShard, size)
+		for i := range pder.shards {
+			pder.shards[i] = &memShard{sessions: make(map[string]*ShardMemSessionStore)}
+		}
+	})
+	atomic.StoreInt64(&pder.maxlifetime, maxlifetime)
+	return nil
+}
+
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (pder *ShardMemProvider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&pder.maxlifetime, maxlifetime)
+}
+
+// shard returns the shard of the sid by the FNV-1a hash.
+func (pder *ShardMemProvider) shard(sid string) *memShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(sid); i++ {
+		h ^= uint32(sid[i])
+		h *= 16777619
+	}
+	return pder.shards[h&uint32(len(pder.shards)-1)]
+}
+
+// SessionRead get the session by sid, a new session is created if it does not exist or has expired.
+func (pder *ShardMemProvider) SessionRead(sid string) (Store, error) {
+	now, maxlifetime := time.Now().UnixNano(), atomic.LoadInt64(&pder.maxlifetime)
+	shard := pder.shard(sid)
+	shard.lock.RLock()
+	st, ok := shard.sessions[sid]
+	shard.lock.RUnlock()
+	if ok && !st.expired(now, maxlifetime) {
+		st.touch(now)
+		return st, nil
+	}
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+	// created or touched by another goroutine meanwhile
+	if st, ok = shard.sessions[sid]; ok && !st.expired(now, maxlifetime) {
+		st.touch(now)
+		return st, nil
+	}
+	st = &ShardMemSessionStore{sid: sid, accessed: now, values: make(map[interface{}]interface{})}
+	shard.sessions[sid] = st
+	return st, nil
+}
+
+// SessionExist check the session exists and has not expired.
+func (pder *ShardMemProvider) SessionExist(sid string) bool {
+	shard := pder.shard(sid)
+	shard.lock.RLock()
+	st, ok := shard.sessions[sid]
+	shard.lock.RUnlock()
+	return ok && !st.expired(time.Now().UnixNano(), atomic.LoadInt64(&pder.maxlifetime))
+}
+
+// SessionRegenerate moves the session to the new sid.
+func (pder *ShardMemProvider) SessionRegenerate(oldsid, sid string) (Store, error) {
+	now := time.Now().UnixNano()
+	old := pder.shard(oldsid)
+	old.lock.Lock()
+	st, ok := old.sessions[oldsid]
+	delete(old.sessions, oldsid)
+	old.lock.Unlock()
+	if !ok || st.expired(now, atomic.LoadInt64(&pder.maxlifetime)) {
+		st = &ShardMemSessionStore{values: make(map[interface{}]interface{})}
+	}
+	st.lock.Lock()
+	st.sid = sid
+	st.lock.Unlock()
+	st.touch(now)
+
+	shard := pder.shard(sid)
+	shard.lock.Lock()
+	shard.sessions[sid] = st
+	shard.lock.Unlock()
+	return st, nil
+}
+
+// SessionDestroy delete the session by sid
+func (pder *ShardMemProvider) SessionDestroy(sid string) error {
+	shard := pder.shard(sid)
+	shard.lock.Lock()
+	delete(shard.sessions, sid)
+	shard.lock.Unlock()
+	return nil
+}
+
+// SessionGC sweeps the expired sessions shard by shard. A shard is scanned
+// under its read lock, and write locked only to delete its expired sessions.
+func (pder *ShardMemProvider) SessionGC() {
+	maxlifetime := atomic.LoadInt64(&pder.maxlifetime)
+	var expired []string
+	for _, shard := range pder.shards {
+		now := time.Now().UnixNano()
+		expired = expired[:0]
+		shard.lock.RLock()
+		for sid, st := range shard.sessions {
+			if st.expired(now, maxlifetime) {
+				expired = append(expired, sid)
+			}
+		}
+		shard.lock.RUnlock()
+		if len(expired) == 0 {
+			continue
+		}
+		shard.lock.Lock()
+		for _, sid := range expired {
+			// may be touched meanwhile
+			if st, ok := shard.sessions[sid]; ok && st.expired(now, maxlifetime) {
+				delete(shard.sessions, sid)
+			}
+		}
+		shard.lock.Unlock()
+	}
+}
+
+// SessionAll get the number of the sessions, including the expired ones not swept yet.
+func (pder *ShardMemProvider) SessionAll() int {
+	var n int
+	for _, shard := range pder.shards {
+		shard.lock.RLock()
+		n += len(shard.sessions)
+		shard.lock.RUnlock()
+	}
+	return n
+}
+
+// SessionIterate calls fn for each active session until fn returns false.
+func (pder *ShardMemProvider) SessionIterate(fn func(SessionInfo) bool) error {
+	now, maxlifetime := time.Now().UnixNano(), atomic.LoadInt64(&pder.maxlifetime)
+	var stores []*ShardMemSessionStore
+	for _, shard := range pder.shards {
+		stores = stores[:0]
+		shard.lock.RLock()
+		for _, st := range shard.sessions {
+			if !st.expired(now, maxlifetime) {
+				stores = append(stores, st)
+			}
+		}
+		shard.lock.RUnlock()
+		for _, st := range stores {
+			st.lock.RLock()
+			info := NewSessionInfo(st.sid, time.Unix(0, atomic.LoadInt64(&st.accessed)), st.values)
+			st.lock.RUnlock()
+			if !fn(info) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// SessionUpdate expand time of the session by sid
+func (pder *ShardMemProvider) SessionUpdate(sid string) error {
+	shard := pder.shard(sid)
+	shard.lock.RLock()
+	st, ok := shard.sessions[sid]
+	shard.lock.RUnlock()
+	if ok {
+		st.touch(time.Now().UnixNano())
+	}
+	return nil
+}
+
+func init() {
+	Register("shardmemory", shardmempder)
+}
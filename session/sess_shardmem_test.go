@@ -0,0 +1,125 @@
+package session
+
+import (
+	"container/list"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardMem(t *testing.T) {
+	manager, err := NewManager("shardmemory", `{"cookieName":"gosessionid","gclifetime":10}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	sess, err := manager.SessionStart(w, r)
+	if err != nil {
+		t.Fatal("session start err,", err)
+	}
+	sess.Set("username", "astaxie")
+	sid := sess.SessionID()
+
+	pder := manager.provider.(*ShardMemProvider)
+	if !pder.SessionExist(sid) {
+		t.Fatal("session does not exist")
+	}
+	st, _ := pder.SessionRegenerate(sid, sid+"x")
+	if st.Get("username") != "astaxie" || st.SessionID() != sid+"x" || pder.SessionExist(sid) {
+		t.Fatal("regenerate session error")
+	}
+
+	// lazy expiry
+	atomic.StoreInt64(&st.(*ShardMemSessionStore).accessed, time.Now().Add(-time.Minute).UnixNano())
+	if pder.SessionExist(sid + "x") {
+		t.Fatal("expired session exists")
+	}
+	if st, _ = pder.SessionRead(sid + "x"); st.Get("username") != nil {
+		t.Fatal("expired session is read")
+	}
+
+	atomic.StoreInt64(&st.(*ShardMemSessionStore).accessed, time.Now().Add(-time.Minute).UnixNano())
+	n := pder.SessionAll()
+	pder.SessionGC()
+	if pder.SessionAll() != n-1 {
+		t.Fatal("expired session is not swept")
+	}
+}
+
+func TestShardMemRevokeUser(t *testing.T) {
+	manager, _ := NewManager("shardmemory", `{"cookieName":"gosessionid","gclifetime":10}`)
+	testRevokeUser(t, manager)
+}
+
+const benchSessions = 10000
+
+func benchmarkProviderRead(b *testing.B, pder Provider) {
+	pder.SessionInit(3600, "")
+	sids := make([]string, benchSessions)
+	for i := range sids {
+		sids[i] = strconv.Itoa(i) + "-bench-session-id"
+		pder.SessionRead(sids[i])
+	}
+	var seed int64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt64(&seed, 7919))
+		for pb.Next() {
+			i++
+			st, _ := pder.SessionRead(sids[i%benchSessions])
+			st.Get("username")
+		}
+	})
+}
+
+func BenchmarkMemProviderRead(b *testing.B) {
+	benchmarkProviderRead(b, &MemProvider{list: list.New(), sessions: make(map[string]*list.Element)})
+}
+
+func BenchmarkShardMemProviderRead(b *testing.B) {
+	benchmarkProviderRead(b, &ShardMemProvider{})
+}
+
+func benchmarkProviderReadGC(b *testing.B, pder Provider) {
+	pder.SessionInit(3600, "")
+	sids := make([]string, benchSessions)
+	for i := range sids {
+		sids[i] = strconv.Itoa(i) + "-bench-session-id"
+		pder.SessionRead(sids[i])
+	}
+	stop := make(chan bool)
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				pder.SessionGC()
+			}
+		}
+	}()
+	var seed int64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt64(&seed, 7919))
+		for pb.Next() {
+			i++
+			pder.SessionRead(sids[i%benchSessions])
+		}
+	})
+}
+
+func BenchmarkMemProviderReadGC(b *testing.B) {
+	benchmarkProviderReadGC(b, &MemProvider{list: list.New(), sessions: make(map[string]*list.Element)})
+}
+
+func BenchmarkShardMemProviderReadGC(b *testing.B) {
+	benchmarkProviderReadGC(b, &ShardMemProvider{})
+}
@@ -17,7 +17,12 @@ package session
 import (
 	"crypto/aes"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_gob(t *testing.T) {
@@ -130,3 +135,96 @@ func TestParseConfig(t *testing.T) {
 		t.Fatal("ProviderConfig get securityKey error")
 	}
 }
+
+func TestCookieAttributes(t *testing.T) {
+	manager, err := NewManager("memory", `{"cookieName":"gosessionid","enableSetCookie":true,"gclifetime":10,"cookiePath":"/app","sameSite":"strict"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	if _, err = manager.SessionStart(w, r); err != nil {
+		t.Fatal("session start err,", err)
+	}
+	cookie := w.Header().Get("Set-Cookie")
+	for _, attr := range []string{"Path=/app", "HttpOnly", "SameSite=Strict"} {
+		if !strings.Contains(cookie, attr) {
+			t.Errorf("expected %s in %q", attr, cookie)
+		}
+	}
+
+	if _, err = NewManager("memory", `{"cookieName":"gosessionid","gclifetime":10,"sameSite":"loose"}`); err == nil {
+		t.Fatal("expected the error of an invalid sameSite")
+	}
+	if _, err = NewManager("memory", `{"cookieName":"gosessionid","gclifetime":10,"sameSite":"none"}`); err == nil {
+		t.Fatal("expected the error of sameSite none without secure")
+	}
+	if _, err = NewManager("memory", `{"cookieName":"gosessionid","gclifetime":10,"sameSite":"none","secure":true}`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbsoluteLifetime(t *testing.T) {
+	manager, err := NewManager("memory", `{"cookieName":"gosessionid","enableSetCookie":true,"gclifetime":10,"absoluteLifetime":60}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	sess, _ := manager.SessionStart(w, r)
+	if _, ok := sess.Get(CreatedKey).(int64); !ok {
+		t.Fatal("new session has no creation time")
+	}
+	sid := sess.SessionID()
+
+	start := func() Store {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "gosessionid", Value: sid})
+		sess, _ := manager.SessionStart(httptest.NewRecorder(), r)
+		return sess
+	}
+	if start().SessionID() != sid {
+		t.Fatal("session expired before the absolute lifetime")
+	}
+	sess.Set(CreatedKey, time.Now().Add(-time.Minute*2).Unix())
+	if s := start(); s.SessionID() == sid || manager.provider.SessionExist(sid) {
+		t.Fatal("session outlived the absolute lifetime")
+	}
+}
+
+func TestSetLifetime(t *testing.T) {
+	manager, err := NewManager("memory", `{"cookieName":"gosessionid","enableSetCookie":true,"gclifetime":10}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			r, _ := http.NewRequest("GET", "/", nil)
+			manager.SessionStart(httptest.NewRecorder(), r)
+		}
+	}()
+	if err = manager.SetLifetime(20, 30); err != nil {
+		t.Fatal(err)
+	}
+	manager.SetAbsoluteLifetime(60)
+	<-done
+
+	if n := atomic.LoadInt64(&mempder.maxlifetime); n != 20 {
+		t.Fatalf("expected the max lifetime 20 of the provider, got %d", n)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	if cookie := manager.newCookie("sid", r); cookie.MaxAge != 30 {
+		t.Fatalf("expected the cookie max age 30, got %d", cookie.MaxAge)
+	}
+
+	// the max lifetime configured alone is kept
+	manager, _ = NewManager("memory", `{"cookieName":"gosessionid","gclifetime":10,"maxLifetime":100}`)
+	if err = manager.SetLifetime(20, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&mempder.maxlifetime); n != 100 {
+		t.Fatalf("expected the max lifetime 100 of the provider, got %d", n)
+	}
+}
@@ -46,7 +46,9 @@ func init() {
 // EncodeGob encode the obj to gob
 func EncodeGob(obj map[interface{}]interface{}) ([]byte, error) {
 	for _, v := range obj {
-		gob.Register(v)
+		if v != nil {
+			gob.Register(v)
+		}
 	}
 	buf := bytes.NewBuffer(nil)
 	enc := gob.NewEncoder(buf)
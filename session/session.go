@@ -39,6 +39,8 @@ import (
 	"net/textproto"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -64,6 +66,23 @@ type Provider interface {
 	SessionGC()
 }
 
+// Valuer is implemented by the stores whose values are not kept on the
+// server (e.g. cookie), so that Manager.SessionRegenerateStore can carry the
+// values set in the current request over to the regenerated store.
+type Valuer interface {
+	Values() map[interface{}]interface{} // a copy of the session values
+}
+
+// LifetimeSetter is implemented by the providers whose max lifetime can be
+// changed at runtime, see Manager.SetLifetime.
+type LifetimeSetter interface {
+	SetMaxLifetime(maxlifetime int64)
+}
+
+// CreatedKey holds the creation time of the session (Unix seconds, int64)
+// when the absolute lifetime is set.
+const CreatedKey = "_session_created"
+
 var provides = make(map[string]Provider)
 
 // SLogger a helpful variable to log information about session
@@ -95,12 +114,20 @@ type managerConfig struct {
 	EnableSidInHttpHeader   bool   `json:"enableSidInHttpHeader"`
 	SessionNameInHttpHeader string `json:"sessionNameInHttpHeader"`
 	EnableSidInUrlQuery     bool   `json:"enableSidInUrlQuery"`
+	CookiePath              string `json:"cookiePath"`
+	HttpOnly                bool   `json:"httpOnly"`
+	SameSite                string `json:"sameSite"`         // "lax", "strict", "none" (requires secure) or "" (not set)
+	AbsoluteLifetime        int64  `json:"absoluteLifetime"` // seconds since the creation, 0 means no limit
+	Serializer              string `json:"serializer"`       // "gob", "json", "msgpack" or a registered one
+	sameSite                http.SameSite
 }
 
 // Manager contains Provider and its configuration.
 type Manager struct {
-	provider Provider
-	config   *managerConfig
+	provider     Provider
+	config       *managerConfig
+	index        *userIndex
+	lifetimeLock sync.RWMutex // guards the lifetimes of config changed at runtime
 }
 
 // NewManager Create new Manager with provider name and json config string.
@@ -110,11 +137,16 @@ type Manager struct {
 // 3. memory
 // 4. redis
 // 5. mysql
+// 6. shardmemory
 // json config:
 // 1. is https  default false
 // 2. hashfunc  default sha1
 // 3. hashkey default beegosessionkey
 // 4. maxage default is none
+// 5. cookiePath default "/", httpOnly default true, sameSite default not set
+//    (sameSite "none" requires secure)
+// 6. absoluteLifetime default 0 (no limit)
+// 7. serializer default "gob"
 func NewManager(provideName, config string) (*Manager, error) {
 	provider, ok := provides[provideName]
 	if !ok {
@@ -122,10 +154,24 @@ func NewManager(provideName, config string) (*Manager, error) {
 	}
 	cf := new(managerConfig)
 	cf.EnableSetCookie = true
+	cf.HttpOnly = true
 	err := json.Unmarshal([]byte(config), cf)
 	if err != nil {
 		return nil, err
 	}
+	if cf.CookiePath == "" {
+		cf.CookiePath = "/"
+	}
+	if cf.sameSite, err = parseSameSite(cf.SameSite); err != nil {
+		return nil, err
+	}
+	if cf.sameSite == http.SameSiteNoneMode && !cf.Secure {
+		// browsers drop SameSite=None cookies without the Secure attribute
+		return nil, fmt.Errorf("session: sameSite none requires secure")
+	}
+	if err = setSerializer(cf.Serializer); err != nil {
+		return nil, err
+	}
 	if cf.Maxlifetime == 0 {
 		cf.Maxlifetime = cf.Gclifetime
 	}
@@ -153,10 +199,17 @@ func NewManager(provideName, config string) (*Manager, error) {
 
 	// 设置存储提供者cookie的CookieName
 	CookieName = cf.CookieName
+	cookieAttrs = cookieAttributes{
+		Path:     cf.CookiePath,
+		Domain:   cf.Domain,
+		HttpOnly: cf.HttpOnly,
+		SameSite: cf.sameSite,
+	}
 
 	return &Manager{
-		provider,
-		cf,
+		provider: provider,
+		config:   cf,
+		index:    newUserIndex(),
 	}, nil
 }
 
@@ -204,7 +257,12 @@ func (manager *Manager) SessionStart(w http.ResponseWriter, r *http.Request) (St
 	}
 
 	if sid != "" && manager.provider.SessionExist(sid) {
-		return manager.provider.SessionRead(sid)
+		session, err := manager.provider.SessionRead(sid)
+		if err != nil || !manager.outlived(session) {
+			return session, err
+		}
+		// the absolute lifetime is exceeded, start a new session
+		manager.DestroySessionID(sid)
 	}
 
 	// Generate a new session
@@ -214,18 +272,10 @@ func (manager *Manager) SessionStart(w http.ResponseWriter, r *http.Request) (St
 	}
 
 	session, err := manager.provider.SessionRead(sid)
-	cookie := &http.Cookie{
-		Name:     manager.config.CookieName,
-		Value:    url.QueryEscape(sid),
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   manager.isSecure(r),
-		Domain:   manager.config.Domain,
-	}
-	if manager.config.CookieLifeTime > 0 {
-		cookie.MaxAge = manager.config.CookieLifeTime
-		cookie.Expires = time.Now().Add(time.Duration(manager.config.CookieLifeTime) * time.Second)
+	if err == nil && manager.absoluteLifetime() > 0 {
+		session.Set(CreatedKey, time.Now().Unix())
 	}
+	cookie := manager.newCookie(sid, r)
 	if manager.config.EnableSetCookie {
 		http.SetCookie(w, cookie)
 	}
@@ -252,15 +302,11 @@ func (manager *Manager) SessionDestroy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sid, _ := url.QueryUnescape(cookie.Value)
-	manager.provider.SessionDestroy(sid)
+	manager.DestroySessionID(sid)
 	if manager.config.EnableSetCookie {
-		expiration := time.Now()
-		cookie = &http.Cookie{Name: manager.config.CookieName,
-			Path:     "/",
-			HttpOnly: true,
-			Expires:  expiration,
-			MaxAge:   -1}
-
+		cookie = manager.newCookie("", r)
+		cookie.Expires = time.Now()
+		cookie.MaxAge = -1
 		http.SetCookie(w, cookie)
 	}
 }
@@ -274,7 +320,7 @@ func (manager *Manager) GetSessionStore(sid string) (Store, error) {
 // it can do gc in times after gc lifetime.
 func (manager *Manager) GC() {
 	manager.provider.SessionGC()
-	time.AfterFunc(time.Duration(manager.config.Gclifetime)*time.Second, func() { manager.GC() })
+	time.AfterFunc(time.Duration(manager.gclifetime())*time.Second, func() { manager.GC() })
 }
 
 // SessionRegenerateID Regenerate a session id for this SessionStore who's id is saving in http request.
@@ -288,24 +334,15 @@ func (manager *Manager) SessionRegenerateID(w http.ResponseWriter, r *http.Reque
 	if err != nil || cookie.Value == "" {
 		//delete old cookie
 		session, _ = manager.provider.SessionRead(sid)
-		cookie = &http.Cookie{Name: manager.config.CookieName,
-			Value:    url.QueryEscape(sid),
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   manager.isSecure(r),
-			Domain:   manager.config.Domain,
+		if session != nil && manager.absoluteLifetime() > 0 {
+			session.Set(CreatedKey, time.Now().Unix())
 		}
 	} else {
 		oldsid, _ := url.QueryUnescape(cookie.Value)
 		session, _ = manager.provider.SessionRegenerate(oldsid, sid)
-		cookie.Value = url.QueryEscape(sid)
-		cookie.HttpOnly = true
-		cookie.Path = "/"
-	}
-	if manager.config.CookieLifeTime > 0 {
-		cookie.MaxAge = manager.config.CookieLifeTime
-		cookie.Expires = time.Now().Add(time.Duration(manager.config.CookieLifeTime) * time.Second)
+		manager.renameIndexed(oldsid, sid)
 	}
+	cookie = manager.newCookie(sid, r)
 	if manager.config.EnableSetCookie {
 		http.SetCookie(w, cookie)
 	}
@@ -319,11 +356,139 @@ func (manager *Manager) SessionRegenerateID(w http.ResponseWriter, r *http.Reque
 	return session
 }
 
+// SessionRegenerateStore regenerates the session id like SessionRegenerateID,
+// and keeps the values set to the current store in this request even if the
+// provider reads the regenerated store from the request (e.g. cookie).
+// The current store should be released before.
+func (manager *Manager) SessionRegenerateStore(w http.ResponseWriter, r *http.Request, current Store) Store {
+	session := manager.SessionRegenerateID(w, r)
+	if session == nil || current == nil {
+		return session
+	}
+	if v, ok := current.(Valuer); ok {
+		session.Flush()
+		for key, value := range v.Values() {
+			session.Set(key, value)
+		}
+	}
+	return session
+}
+
 // GetActiveSession Get all active sessions count number.
 func (manager *Manager) GetActiveSession() int {
 	return manager.provider.SessionAll()
 }
 
+// SetLifetime changes the gc lifetime and the cookie lifetime at runtime.
+// The max lifetime follows the gc lifetime unless it is configured alone,
+// an error is returned if the provider does not implement LifetimeSetter.
+func (manager *Manager) SetLifetime(gclifetime int64, cookieLifeTime int) error {
+	manager.lifetimeLock.Lock()
+	old := manager.config.Maxlifetime
+	if old == manager.config.Gclifetime {
+		manager.config.Maxlifetime = gclifetime
+	}
+	manager.config.Gclifetime = gclifetime
+	manager.config.CookieLifeTime = cookieLifeTime
+	maxlifetime := manager.config.Maxlifetime
+	manager.lifetimeLock.Unlock()
+
+	if maxlifetime == old {
+		return nil
+	}
+	setter, ok := manager.provider.(LifetimeSetter)
+	if !ok {
+		return fmt.Errorf("session: the provider %T can not change the max lifetime at runtime", manager.provider)
+	}
+	setter.SetMaxLifetime(maxlifetime)
+	return nil
+}
+
+// SetAbsoluteLifetime changes the absolute lifetime (seconds since the creation
+// of the session, 0 means no limit) at runtime.
+func (manager *Manager) SetAbsoluteLifetime(lifetime int64) {
+	manager.lifetimeLock.Lock()
+	manager.config.AbsoluteLifetime = lifetime
+	manager.lifetimeLock.Unlock()
+}
+
+func (manager *Manager) gclifetime() int64 {
+	manager.lifetimeLock.RLock()
+	defer manager.lifetimeLock.RUnlock()
+	return manager.config.Gclifetime
+}
+
+func (manager *Manager) cookieLifeTime() int {
+	manager.lifetimeLock.RLock()
+	defer manager.lifetimeLock.RUnlock()
+	return manager.config.CookieLifeTime
+}
+
+func (manager *Manager) absoluteLifetime() int64 {
+	manager.lifetimeLock.RLock()
+	defer manager.lifetimeLock.RUnlock()
+	return manager.config.AbsoluteLifetime
+}
+
+// outlived reports whether the session exceeds the absolute lifetime.
+// sessions without the creation time (e.g. created before the limit is set)
+// start counting now.
+func (manager *Manager) outlived(store Store) bool {
+	lifetime := manager.absoluteLifetime()
+	if lifetime <= 0 {
+		return false
+	}
+	created, ok := store.Get(CreatedKey).(int64)
+	if !ok {
+		store.Set(CreatedKey, time.Now().Unix())
+		return false
+	}
+	return created+lifetime < time.Now().Unix()
+}
+
+// newCookie creates the session cookie with the configured attributes.
+func (manager *Manager) newCookie(sid string, r *http.Request) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     manager.config.CookieName,
+		Value:    url.QueryEscape(sid),
+		Path:     manager.config.CookiePath,
+		HttpOnly: manager.config.HttpOnly,
+		Secure:   manager.isSecure(r),
+		Domain:   manager.config.Domain,
+		SameSite: manager.config.sameSite,
+	}
+	if lifetime := manager.cookieLifeTime(); lifetime > 0 {
+		cookie.MaxAge = lifetime
+		cookie.Expires = time.Now().Add(time.Duration(lifetime) * time.Second)
+	}
+	return cookie
+}
+
+// cookieAttributes are the attributes of the session cookie shared with the
+// providers writing their own cookies (e.g. cookie).
+type cookieAttributes struct {
+	Path     string
+	Domain   string
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+var cookieAttrs = cookieAttributes{Path: "/", HttpOnly: true}
+
+func parseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return 0, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("session: invalid sameSite %q, it must be lax, strict or none", s)
+}
+
 // SetSecure Set cookie with https.
 func (manager *Manager) SetSecure(secure bool) {
 	manager.config.Secure = secure
@@ -0,0 +1,143 @@
+package sqldb
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Dialect adapts the provider to the SQL syntax of a database. The table has
+// the columns session_key, session_data and session_expiry (the last access
+// time in Unix seconds), with an index on session_expiry.
+type Dialect interface {
+	// Placeholder returns the bind variable of the nth (1-based) argument.
+	Placeholder(n int) string
+	// CreateTable returns the statements creating the table and the index on
+	// session_expiry if they do not exist.
+	CreateTable(table string) []string
+	// Upsert returns the statement inserting or replacing a session,
+	// its arguments are session_key, session_data and session_expiry.
+	Upsert(table string) string
+	// DeleteExpired returns the statement deleting at most a batch of the
+	// sessions expired before a time, its arguments are the time and the batch size.
+	DeleteExpired(table string) string
+}
+
+// MySQL is the dialect of MySQL and MariaDB.
+type MySQL struct{}
+
+// Placeholder returns "?".
+func (MySQL) Placeholder(n int) string { return "?" }
+
+// CreateTable creates the table with an index on session_expiry.
+func (MySQL) CreateTable(table string) []string {
+	return []string{"CREATE TABLE IF NOT EXISTS " + table + ` (
+	session_key VARCHAR(128) NOT NULL,
+	session_data BLOB,
+	session_expiry BIGINT NOT NULL,
+	PRIMARY KEY (session_key),
+	INDEX ` + table + `_expiry (session_expiry)
+) DEFAULT CHARSET=utf8`}
+}
+
+// Upsert uses ON DUPLICATE KEY UPDATE.
+func (MySQL) Upsert(table string) string {
+	return "INSERT INTO " + table + " (session_key, session_data, session_expiry) VALUES (?, ?, ?)" +
+		" ON DUPLICATE KEY UPDATE session_data = VALUES(session_data), session_expiry = VALUES(session_expiry)"
+}
+
+// DeleteExpired uses DELETE ... LIMIT.
+func (MySQL) DeleteExpired(table string) string {
+	return "DELETE FROM " + table + " WHERE session_expiry < ? LIMIT ?"
+}
+
+// Postgres is the dialect of PostgreSQL.
+type Postgres struct{}
+
+// Placeholder returns "$n".
+func (Postgres) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// CreateTable creates the table with an index on session_expiry.
+func (Postgres) CreateTable(table string) []string {
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + ` (
+	session_key VARCHAR(128) NOT NULL PRIMARY KEY,
+	session_data BYTEA,
+	session_expiry BIGINT NOT NULL
+)`,
+		"CREATE INDEX IF NOT EXISTS " + table + "_expiry ON " + table + " (session_expiry)",
+	}
+}
+
+// Upsert uses ON CONFLICT DO UPDATE (PostgreSQL 9.5+).
+func (Postgres) Upsert(table string) string {
+	return "INSERT INTO " + table + " (session_key, session_data, session_expiry) VALUES ($1, $2, $3)" +
+		" ON CONFLICT (session_key) DO UPDATE SET session_data = EXCLUDED.session_data, session_expiry = EXCLUDED.session_expiry"
+}
+
+// DeleteExpired deletes the keys selected with a LIMIT.
+func (Postgres) DeleteExpired(table string) string {
+	return "DELETE FROM " + table + " WHERE session_key IN (SELECT session_key FROM " + table +
+		" WHERE session_expiry < $1 LIMIT $2)"
+}
+
+// SQLite is the dialect of SQLite (3.24+).
+type SQLite struct{}
+
+// Placeholder returns "?".
+func (SQLite) Placeholder(n int) string { return "?" }
+
+// CreateTable creates the table with an index on session_expiry.
+func (SQLite) CreateTable(table string) []string {
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + ` (
+	session_key TEXT NOT NULL PRIMARY KEY,
+	session_data BLOB,
+	session_expiry INTEGER NOT NULL
+)`,
+		"CREATE INDEX IF NOT EXISTS " + table + "_expiry ON " + table + " (session_expiry)",
+	}
+}
+
+// Upsert uses ON CONFLICT DO UPDATE.
+func (SQLite) Upsert(table string) string {
+	return "INSERT INTO " + table + " (session_key, session_data, session_expiry) VALUES (?, ?, ?)" +
+		" ON CONFLICT (session_key) DO UPDATE SET session_data = excluded.session_data, session_expiry = excluded.session_expiry"
+}
+
+// DeleteExpired deletes the keys selected with a LIMIT.
+func (SQLite) DeleteExpired(table string) string {
+	return "DELETE FROM " + table + " WHERE session_key IN (SELECT session_key FROM " + table +
+		" WHERE session_expiry < ? LIMIT ?)"
+}
+
+var (
+	dialects = map[string]Dialect{
+		"mysql":    MySQL{},
+		"postgres": Postgres{},
+		"pgx":      Postgres{},
+		"sqlite3":  SQLite{},
+		"sqlite":   SQLite{},
+	}
+	dialectsLock sync.RWMutex
+)
+
+// RegisterDialect makes a dialect available by the database/sql driver name.
+func RegisterDialect(driver string, dialect Dialect) {
+	dialectsLock.Lock()
+	defer dialectsLock.Unlock()
+	if dialect == nil {
+		panic("sqldb: Register dialect is nil")
+	}
+	dialects[driver] = dialect
+}
+
+// GetDialect returns the dialect registered for the database/sql driver name.
+func GetDialect(driver string) (Dialect, error) {
+	dialectsLock.RLock()
+	defer dialectsLock.RUnlock()
+	if d, ok := dialects[driver]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("sqldb: no dialect for the driver %q (forgotten RegisterDialect?)", driver)
+}
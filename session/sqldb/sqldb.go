@@ -0,0 +1,379 @@
+// Package sqldb is a session provider for any database/sql driver, with the
+// dialects of MySQL, PostgreSQL and SQLite built in (see RegisterDialect).
+//
+// The table is created automatically:
+//
+//	session_key     the session id, primary key
+//	session_data    the session values encoded by the session serializer
+//	session_expiry  the last access time (Unix seconds), indexed for the GC
+//
+// Usage, the ProviderConfig is "driver:dsn" (import the driver yourself):
+//
+//	import(
+//	  _ "github.com/mattn/go-sqlite3"
+//	  _ "github.com/henrylee2cn/lessgo/session/sqldb"
+//	  "github.com/henrylee2cn/lessgo/session"
+//	)
+//
+//	func init() {
+//		globalSessions, _ = session.NewManager("sql", `{"cookieName":"gosessionid","gclifetime":3600,"ProviderConfig":"sqlite3:./sessions.db"}`)
+//		go globalSessions.GC()
+//	}
+//
+// or with an opened *sql.DB:
+//
+//	session.Register("appdb", sqldb.NewProvider(db, sqldb.Postgres{}))
+package sqldb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/session"
+)
+
+var (
+	// TableName is the table of the sessions, it is used by the providers
+	// initialized after it is changed.
+	TableName = "lessgo_session"
+	// GCBatchSize is the max number of the expired sessions deleted by a statement.
+	GCBatchSize = 1000
+
+	validTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	sqlpder = &Provider{}
+)
+
+// SessionStore sql session store
+type SessionStore struct {
+	p      *Provider
+	sid    string
+	lock   sync.RWMutex
+	values map[interface{}]interface{}
+	dirty  bool // the values have been changed since read
+}
+
+// Set value in the session
+func (st *SessionStore) Set(key, value interface{}) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.values[key] = value
+	st.dirty = true
+}
+
+// Get value from the session
+func (st *SessionStore) Get(key interface{}) interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.values[key]
+}
+
+// Delete value in the session
+func (st *SessionStore) Delete(key interface{}) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	delete(st.values, key)
+	st.dirty = true
+}
+
+// Flush clear all values in the session
+func (st *SessionStore) Flush() {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.values = make(map[interface{}]interface{})
+	st.dirty = true
+}
+
+// SessionID get the session id
+func (st *SessionStore) SessionID() string {
+	return st.sid
+}
+
+// SessionRelease saves the values to the database,
+// only the expiry is updated if the values have no changes.
+func (st *SessionStore) SessionRelease(w http.ResponseWriter) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	q := st.p.queries()
+	if q == nil {
+		return
+	}
+	now := time.Now().Unix()
+	if !st.dirty {
+		if _, err := q.db.Exec(q.touch, now, st.sid); err != nil {
+			session.SLogger.Println(err)
+		}
+		return
+	}
+	b, err := session.EncodeValues(st.values)
+	if err != nil {
+		session.SLogger.Println(err)
+		return
+	}
+	if _, err = q.db.Exec(q.upsert, st.sid, b, now); err != nil {
+		session.SLogger.Println(err)
+		return
+	}
+	st.dirty = false
+}
+
+// queries holds the statements built by the dialect.
+type queries struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	read    string
+	exist   string
+	upsert  string
+	touch   string
+	rename  string
+	destroy string
+	expire  string
+	count   string
+	iterate string
+}
+
+func newQueries(db *sql.DB, dialect Dialect, table string) *queries {
+	p := dialect.Placeholder
+	return &queries{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+		read:    "SELECT session_data, session_expiry FROM " + table + " WHERE session_key = " + p(1),
+		exist:   "SELECT session_expiry FROM " + table + " WHERE session_key = " + p(1),
+		upsert:  dialect.Upsert(table),
+		touch:   "UPDATE " + table + " SET session_expiry = " + p(1) + " WHERE session_key = " + p(2),
+		rename:  "UPDATE " + table + " SET session_key = " + p(1) + ", session_expiry = " + p(2) + " WHERE session_key = " + p(3),
+		destroy: "DELETE FROM " + table + " WHERE session_key = " + p(1),
+		expire:  dialect.DeleteExpired(table),
+		count:   "SELECT COUNT(*) FROM " + table + " WHERE session_expiry >= " + p(1),
+		iterate: "SELECT session_key, session_data, session_expiry FROM " + table + " WHERE session_expiry >= " + p(1),
+	}
+}
+
+// Provider sql session provider
+type Provider struct {
+	maxlifetime int64 // atomic
+	config      string
+	q           *queries
+	lock        sync.RWMutex
+}
+
+// NewProvider creates a provider with an opened database, register it by
+// session.Register. The table (TableName) is created by SessionInit.
+func NewProvider(db *sql.DB, dialect Dialect) *Provider {
+	return &Provider{q: newQueries(db, dialect, TableName)}
+}
+
+// SessionInit opens the database of the config "driver:dsn" and creates the
+// table. The config is ignored by the providers created by NewProvider.
+func (sp *Provider) SessionInit(maxlifetime int64, config string) error {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	atomic.StoreInt64(&sp.maxlifetime, maxlifetime)
+	// initialized before, e.g. the lifetime is changed
+	if sp.q != nil && (sp.config == config || sp.config == "") {
+		return sp.createTable()
+	}
+	i := strings.Index(config, ":")
+	if i <= 0 {
+		return errors.New(`sqldb: the config must be "driver:dsn"`)
+	}
+	driver, dsn := config[:i], config[i+1:]
+	dialect, err := GetDialect(driver)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	if sp.q != nil {
+		sp.q.db.Close()
+	}
+	sp.q = newQueries(db, dialect, TableName)
+	sp.config = config
+	return sp.createTable()
+}
+
+// SetMaxLifetime changes the max lifetime of the sessions at runtime.
+func (sp *Provider) SetMaxLifetime(maxlifetime int64) {
+	atomic.StoreInt64(&sp.maxlifetime, maxlifetime)
+}
+
+// createTable creates the table and the index if they do not exist, the caller must hold the lock.
+func (sp *Provider) createTable() error {
+	if !validTableName.MatchString(sp.q.table) {
+		return fmt.Errorf("sqldb: invalid table name %q", sp.q.table)
+	}
+	for _, ddl := range sp.q.dialect.CreateTable(sp.q.table) {
+		if _, err := sp.q.db.Exec(ddl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sp *Provider) queries() *queries {
+	sp.lock.RLock()
+	defer sp.lock.RUnlock()
+	return sp.q
+}
+
+// expiredBefore returns the Unix time before which the sessions have expired.
+func (sp *Provider) expiredBefore() int64 {
+	sp.lock.RLock()
+	defer sp.lock.RUnlock()
+	return time.Now().Unix() - atomic.LoadInt64(&sp.maxlifetime)
+}
+
+// DB returns the database of the provider, nil before SessionInit.
+func (sp *Provider) DB() *sql.DB {
+	if q := sp.queries(); q != nil {
+		return q.db
+	}
+	return nil
+}
+
+// SessionRead get the session by sid, a new session is returned if it does not
+// exist or has expired, and it is saved on release.
+func (sp *Provider) SessionRead(sid string) (session.Store, error) {
+	q := sp.queries()
+	if q == nil {
+		return nil, errors.New("sqldb: provider is not initialized")
+	}
+	var (
+		data   []byte
+		expiry int64
+	)
+	err := q.db.QueryRow(q.read, sid).Scan(&data, &expiry)
+	switch {
+	case err == sql.ErrNoRows || err == nil && expiry < sp.expiredBefore():
+		return &SessionStore{p: sp, sid: sid, values: make(map[interface{}]interface{}), dirty: true}, nil
+	case err != nil:
+		return nil, err
+	}
+	kv := make(map[interface{}]interface{})
+	if len(data) > 0 {
+		if kv, err = session.DecodeValues(data); err != nil {
+			return nil, err
+		}
+	}
+	return &SessionStore{p: sp, sid: sid, values: kv}, nil
+}
+
+// SessionExist check the session exists and has not expired
+func (sp *Provider) SessionExist(sid string) bool {
+	q := sp.queries()
+	if q == nil {
+		return false
+	}
+	var expiry int64
+	if err := q.db.QueryRow(q.exist, sid).Scan(&expiry); err != nil {
+		return false
+	}
+	return expiry >= sp.expiredBefore()
+}
+
+// SessionRegenerate moves the session to the new sid
+func (sp *Provider) SessionRegenerate(oldsid, sid string) (session.Store, error) {
+	q := sp.queries()
+	if q == nil {
+		return nil, errors.New("sqldb: provider is not initialized")
+	}
+	if _, err := q.db.Exec(q.rename, sid, time.Now().Unix(), oldsid); err != nil {
+		return nil, err
+	}
+	return sp.SessionRead(sid)
+}
+
+// SessionDestroy delete the session by sid
+func (sp *Provider) SessionDestroy(sid string) error {
+	q := sp.queries()
+	if q == nil {
+		return nil
+	}
+	_, err := q.db.Exec(q.destroy, sid)
+	return err
+}
+
+// SessionGC deletes the expired sessions in batches of GCBatchSize with the
+// index on session_expiry, so that the table is never locked for long.
+func (sp *Provider) SessionGC() {
+	q := sp.queries()
+	if q == nil {
+		return
+	}
+	before, batch := sp.expiredBefore(), GCBatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+	for {
+		res, err := q.db.Exec(q.expire, before, batch)
+		if err != nil {
+			session.SLogger.Println(err)
+			return
+		}
+		if n, err := res.RowsAffected(); err != nil || n < int64(batch) {
+			return
+		}
+	}
+}
+
+// SessionAll count the active sessions
+func (sp *Provider) SessionAll() int {
+	q := sp.queries()
+	if q == nil {
+		return 0
+	}
+	var total int
+	if err := q.db.QueryRow(q.count, sp.expiredBefore()).Scan(&total); err != nil {
+		return 0
+	}
+	return total
+}
+
+// SessionIterate calls fn for each active session until fn returns false,
+// session_expiry is the last access time.
+func (sp *Provider) SessionIterate(fn func(session.SessionInfo) bool) error {
+	q := sp.queries()
+	if q == nil {
+		return errors.New("sqldb: provider is not initialized")
+	}
+	rows, err := q.db.Query(q.iterate, sp.expiredBefore())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sid    string
+			data   []byte
+			expiry int64
+		)
+		if err = rows.Scan(&sid, &data, &expiry); err != nil {
+			return err
+		}
+		kv := make(map[interface{}]interface{})
+		if len(data) > 0 {
+			if kv, err = session.DecodeValues(data); err != nil {
+				continue
+			}
+		}
+		if !fn(session.NewSessionInfo(sid, time.Unix(expiry, 0), kv)) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func init() {
+	session.Register("sql", sqlpder)
+}
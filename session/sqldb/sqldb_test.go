@@ -0,0 +1,142 @@
+package sqldb
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/henrylee2cn/lessgo/session"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newManager(t *testing.T) (*session.Manager, *sql.DB, func()) {
+	dir, err := ioutil.TempDir("", "sqldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := "sqlite3:" + filepath.Join(dir, "sessions.db")
+	manager, err := session.NewManager("sql", `{"cookieName":"gosessionid","gclifetime":3600,"ProviderConfig":`+strconv.Quote(config)+`}`)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal("init sql session err,", err)
+	}
+	return manager, sqlpder.DB(), func() {
+		sqlpder.DB().Close()
+		sqlpder.q = nil
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	manager, db, clean := newManager(t)
+	defer clean()
+
+	var index string
+	if err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'index' AND name = ?", TableName+"_expiry").Scan(&index); err != nil {
+		t.Fatal("the index on session_expiry is not created,", err)
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	sess, err := manager.SessionStart(w, r)
+	if err != nil {
+		t.Fatal("session start err,", err)
+	}
+	sess.Set("username", "astaxie")
+	sess.SessionRelease(w)
+	sid := sess.SessionID()
+
+	read := func(sid string) session.Store {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "gosessionid", Value: sid})
+		sess, err := manager.SessionStart(httptest.NewRecorder(), r)
+		if err != nil {
+			t.Fatal("session start err,", err)
+		}
+		return sess
+	}
+	if sess = read(sid); sess.SessionID() != sid || sess.Get("username") != "astaxie" {
+		t.Fatal("read session error")
+	}
+
+	// a clean session only updates the expiry
+	db.Exec("UPDATE "+TableName+" SET session_data = NULL, session_expiry = 0 WHERE session_key = ?", sid)
+	sess.SessionRelease(httptest.NewRecorder())
+	var (
+		data   []byte
+		expiry int64
+	)
+	db.QueryRow("SELECT session_data, session_expiry FROM "+TableName+" WHERE session_key = ?", sid).Scan(&data, &expiry)
+	if data != nil || expiry == 0 {
+		t.Fatalf("clean release must only touch the expiry, got %q, %d", data, expiry)
+	}
+	sess.Set("username", "astaxie")
+	sess.SessionRelease(httptest.NewRecorder())
+
+	st, err := sqlpder.SessionRegenerate(sid, sid+"x")
+	if err != nil || st.Get("username") != "astaxie" || sqlpder.SessionExist(sid) || !sqlpder.SessionExist(sid+"x") {
+		t.Fatal("regenerate session error,", err)
+	}
+
+	list, err := manager.ListSessions()
+	if err != nil || len(list) != 1 || list[0].ID != sid+"x" {
+		t.Fatalf("list sessions error, got %v, %v", list, err)
+	}
+
+	if err = sqlpder.SessionDestroy(sid + "x"); err != nil || sqlpder.SessionExist(sid+"x") {
+		t.Fatal("destroy session error,", err)
+	}
+}
+
+func TestSQLiteGC(t *testing.T) {
+	_, db, clean := newManager(t)
+	defer clean()
+	defer func(n int) { GCBatchSize = n }(GCBatchSize)
+	GCBatchSize = 10
+
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	for i := 0; i < 25; i++ {
+		db.Exec("INSERT INTO "+TableName+" (session_key, session_data, session_expiry) VALUES (?, ?, ?)", "old"+strconv.Itoa(i), "", old)
+	}
+	db.Exec("INSERT INTO "+TableName+" (session_key, session_data, session_expiry) VALUES (?, ?, ?)", "active", "", time.Now().Unix())
+	if n := sqlpder.SessionAll(); n != 1 {
+		t.Fatalf("expected 1 active session, got %d", n)
+	}
+	if sqlpder.SessionExist("old0") {
+		t.Fatal("expired session exists")
+	}
+	sqlpder.SessionGC()
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM " + TableName).Scan(&n)
+	if n != 1 || !sqlpder.SessionExist("active") {
+		t.Fatalf("expected only the active session after gc, got %d rows", n)
+	}
+}
+
+func TestDialects(t *testing.T) {
+	for driver, want := range map[string]string{
+		"mysql":    "INSERT INTO s (session_key, session_data, session_expiry) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE session_data = VALUES(session_data), session_expiry = VALUES(session_expiry)",
+		"postgres": "INSERT INTO s (session_key, session_data, session_expiry) VALUES ($1, $2, $3) ON CONFLICT (session_key) DO UPDATE SET session_data = EXCLUDED.session_data, session_expiry = EXCLUDED.session_expiry",
+	} {
+		d, err := GetDialect(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Upsert("s"); got != want {
+			t.Errorf("%s upsert:\nexpected %s\n     got %s", driver, want, got)
+		}
+	}
+	q := newQueries(nil, Postgres{}, "s")
+	if want := "UPDATE s SET session_key = $1, session_expiry = $2 WHERE session_key = $3"; q.rename != want {
+		t.Errorf("expected %s, got %s", want, q.rename)
+	}
+	if _, err := GetDialect("oracle"); err == nil {
+		t.Fatal("expected the error of an unknown driver")
+	}
+}
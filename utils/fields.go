@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"reflect"
+)
+
+// 结构体中值不同的字段
+type FieldChange struct {
+	Field  string      // 字段名
+	Old    interface{} // dst中的值
+	New    interface{} // src中的值
+	Merged bool        // 是否已将src中的值写入dst
+}
+
+// 比较dst与src(指向同一类型结构体的指针)中string、int、int64及bool类型的字段，
+// 将merge返回true的变更字段写入dst，并按字段顺序返回全部变更字段
+func MergeFields(dst, src interface{}, merge func(field string) bool) []FieldChange {
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	dt := dv.Type()
+	var changes []FieldChange
+	for i := 0; i < dt.NumField(); i++ {
+		df, sf := dv.Field(i), sv.Field(i)
+		if !df.CanSet() {
+			continue
+		}
+		switch df.Kind() {
+		case reflect.String, reflect.Int, reflect.Int64, reflect.Bool:
+		default:
+			continue
+		}
+		if df.Interface() == sf.Interface() {
+			continue
+		}
+		c := FieldChange{
+			Field:  dt.Field(i).Name,
+			Old:    df.Interface(),
+			New:    sf.Interface(),
+			Merged: merge(dt.Field(i).Name),
+		}
+		if c.Merged {
+			df.Set(sf)
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeFields(t *testing.T) {
+	type section struct {
+		Debug   bool
+		Address string
+		MaxMB   int64
+		Keys    []string
+		hidden  int
+	}
+	dst := &section{Debug: false, Address: ":8080", MaxMB: 32, Keys: []string{"a"}, hidden: 1}
+	src := &section{Debug: true, Address: ":9090", MaxMB: 32, Keys: []string{"b"}, hidden: 2}
+	changes := MergeFields(dst, src, func(field string) bool { return field == "Debug" })
+	want := []FieldChange{
+		{Field: "Debug", Old: false, New: true, Merged: true},
+		{Field: "Address", Old: ":8080", New: ":9090"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected %+v, got %+v", want, changes)
+	}
+	if !dst.Debug || dst.Address != ":8080" || dst.Keys[0] != "a" || dst.hidden != 1 {
+		t.Fatalf("unexpected merged struct %+v", dst)
+	}
+	if changes = MergeFields(dst, dst, func(string) bool { return true }); len(changes) != 0 {
+		t.Fatalf("expected no change, got %+v", changes)
+	}
+}
@@ -16,6 +16,7 @@ package utils
 
 import (
 	"math/rand"
+	"reflect"
 	"time"
 )
 
@@ -168,3 +169,29 @@ func SliceShuffle(slice []interface{}) []interface{} {
 	}
 	return slice
 }
+
+// SliceToggle removes the elements of the slice which ptr points to for which
+// match returns true, and inserts elem at their position if on is true. at is
+// the position returned by the previous call, so that an element switched off
+// and on again is put back in place; it is appended if at is out of range.
+// The slice is replaced by a new one, the returned value is the position of elem.
+func SliceToggle(ptr interface{}, match func(v interface{}) bool, elem interface{}, on bool, at int) int {
+	sv := reflect.ValueOf(ptr).Elem()
+	out := reflect.MakeSlice(sv.Type(), 0, sv.Len()+1)
+	for i := 0; i < sv.Len(); i++ {
+		if match(sv.Index(i).Interface()) {
+			at = out.Len()
+			continue
+		}
+		out = reflect.Append(out, sv.Index(i))
+	}
+	if on {
+		if at < 0 || at > out.Len() {
+			at = out.Len()
+		}
+		tail := reflect.AppendSlice(reflect.MakeSlice(sv.Type(), 0, out.Len()-at), out.Slice(at, out.Len()))
+		out = reflect.AppendSlice(reflect.Append(out.Slice(0, at), reflect.ValueOf(elem)), tail)
+	}
+	sv.Set(out)
+	return at
+}
@@ -15,6 +15,7 @@
 package utils
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -27,3 +28,30 @@ func TestInSlice(t *testing.T) {
 		t.Error("should be false")
 	}
 }
+
+func TestSliceToggle(t *testing.T) {
+	list := []string{"log", "cors", "gzip"}
+	isCors := func(v interface{}) bool { return v.(string) == "cors" }
+
+	at := SliceToggle(&list, isCors, "cors", false, -1)
+	if at != 1 || !reflect.DeepEqual(list, []string{"log", "gzip"}) {
+		t.Fatalf("unexpected removal: %v at %d", list, at)
+	}
+	at = SliceToggle(&list, isCors, "cors", true, at)
+	if at != 1 || !reflect.DeepEqual(list, []string{"log", "cors", "gzip"}) {
+		t.Fatalf("not put back in place: %v at %d", list, at)
+	}
+	// switching on again does not duplicate it
+	at = SliceToggle(&list, isCors, "cors", true, at)
+	if at != 1 || !reflect.DeepEqual(list, []string{"log", "cors", "gzip"}) {
+		t.Fatalf("unexpected list: %v at %d", list, at)
+	}
+	// never added before, or the position is out of range: appended
+	if at = SliceToggle(&list, func(interface{}) bool { return false }, "auth", true, -1); at != 3 || list[3] != "auth" {
+		t.Fatalf("not appended: %v at %d", list, at)
+	}
+	short := []string{"log"}
+	if at = SliceToggle(&short, isCors, "cors", true, 5); at != 1 || !reflect.DeepEqual(short, []string{"log", "cors"}) {
+		t.Fatalf("not appended: %v at %d", short, at)
+	}
+}
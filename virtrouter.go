@@ -401,7 +401,7 @@ func (vr *VirtRouter) setParamsAndPath() {
 			Log.Error(err.Error())
 			continue
 		}
-		for _, p := range m.GetApiMiddleware().Params {
+		for _, p := range m.GetApiMiddleware().configParams([]byte(m.GetConfig())) {
 			k := p.In + p.Name
 			had, ok := paramMap[k]
 			if ok {